@@ -11,6 +11,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/nats-io/go-nats"
 	"github.com/oklog/oklog/pkg/group"
 	stdopentracing "github.com/opentracing/opentracing-go"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
@@ -24,6 +25,8 @@ import (
 	"apiserver/pkg/endpoint"
 	"apiserver/pkg/service"
 	"apiserver/pkg/transport"
+	"repository/pkg/natsconn"
+	repotransport "repository/pkg/transport"
 )
 
 func main() {
@@ -34,6 +37,9 @@ func main() {
 		jaegerURL = fs.String("jaeger-addr", "jaeger:5775", "Jaeger server address")
 		repoIP    = fs.String("repoIP", "repo", "repository IP address")
 		repoPort  = fs.String("repoPort", ":8082", "repository Port address")
+		repoTrans = fs.String("repo-transport", "grpc", "How the repository is called: grpc dials repoIP and repoPort, nats sends requests which any replica may serve")
+		natsAddr  = fs.String("nats-addr", nats.DefaultURL, "NATS server address, used with -repo-transport=nats")
+		natsCodec = fs.String("nats-codec", "json", "Messages of requests to the repository over NATS: json or proto")
 	)
 
 	fs.Usage = usageFor(fs, os.Args[0]+" [flags]")
@@ -99,8 +105,50 @@ func main() {
 			Help:      "Request duration in seconds.",
 		}, []string{"method", "success"})
 	}
+	var natsMetrics natsconn.Metrics
+	{
+		// Connection-level metrics.
+		natsMetrics.Connected = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "apiserver",
+			Name:      "nats_connected",
+			Help:      "1 while the apiserver is connected to NATS.",
+		}, []string{})
+		natsMetrics.Disconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "apiserver",
+			Name:      "nats_disconnects",
+			Help:      "Total count of connections to NATS lost.",
+		}, []string{})
+		natsMetrics.Reconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "apiserver",
+			Name:      "nats_reconnects",
+			Help:      "Total count of connections to NATS restored.",
+		}, []string{})
+	}
 	http.DefaultServeMux.Handle("/metrics", promhttp.Handler())
 
+	// The repository is either dialled at its address for every call, or
+	// reached over NATS, where its replicas share the requests.
+	var repository service.Repository
+	switch *repoTrans {
+	case "grpc":
+		repository = service.GRPCRepository(*repoIP+*repoPort, logger)
+	case "nats":
+		codec, err := repotransport.ParseCodec(*natsCodec)
+		if err != nil {
+			logger.Log("nats-codec", *natsCodec, "err", err)
+			os.Exit(1)
+		}
+		natsConn := natsconn.New(*natsAddr, natsconn.Config{Name: "APIServer"}, natsMetrics, logger)
+		defer natsConn.Close()
+		repository = service.NATSRepository(natsConn, codec, logger)
+	default:
+		logger.Log("repo-transport", *repoTrans, "err", "unknown repository transport, use grpc or nats")
+		os.Exit(1)
+	}
+
 	// Build the layers of the service "onion" from the inside out. First, the
 	// business logic service; then, the set of endpoints that wrap the service;
 	// and finally, a series of concrete transport adapters. The adapters, like
@@ -108,7 +156,7 @@ func main() {
 	// the interfaces that the transports expect. Note that we're not binding
 	// them to ports or anything yet; we'll do that next.
 	var (
-		service     = service.New(*repoIP, *repoPort, logger, getAllNodes, newJobs)
+		service     = service.New(repository, logger, getAllNodes, newJobs)
 		endpoints   = endpoint.New(service, logger, duration, tracer)
 		httpHandler = transport.NewHTTPHandler(endpoints, tracer, logger)
 	)
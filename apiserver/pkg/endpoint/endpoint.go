@@ -16,6 +16,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"apiserver/pkg/service"
+	"repository/pkg/availability"
 	repo "repository/pkg/model"
 )
 
@@ -25,6 +26,8 @@ import (
 type EndpointSet struct {
 	GetAllNodesEndpoint endpoint.Endpoint
 	NewJobEndpoint      endpoint.Endpoint
+
+	GetAvailabilityEndpoint endpoint.Endpoint
 }
 
 // New returns a Set that wraps the provided server, and wires in all of the
@@ -51,9 +54,21 @@ func New(svc service.Service, logger log.Logger, duration metrics.Histogram, otT
 		newJobEndpoint = InstrumentingMiddleware(duration.With("method", "NewJob"))(newJobEndpoint)
 	}
 
+	var getAvailabilityEndpoint endpoint.Endpoint
+	{
+		getAvailabilityEndpoint = MakeGetAvailabilityEndpoint(svc)
+		getAvailabilityEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = opentracing.TraceServer(otTracer, "GetAvailability")(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = LoggingMiddleware(log.With(logger, "method", "GetAvailability"))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = InstrumentingMiddleware(duration.With("method", "GetAvailability"))(getAvailabilityEndpoint)
+	}
+
 	return EndpointSet{
 		GetAllNodesEndpoint: getAllNodesEndpoint,
 		NewJobEndpoint:      newJobEndpoint,
+
+		GetAvailabilityEndpoint: getAvailabilityEndpoint,
 	}
 }
 
@@ -120,3 +135,40 @@ func MakeNewJobEndpoint(s service.Service) endpoint.Endpoint {
 		return NewJobResponse{ID: id, Err: err}, nil
 	}
 }
+
+// ========= GetAvailability ===========
+
+// GetAvailability implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) GetAvailability(ctx context.Context, nodeID string, incidents int) ([]availability.Report, error) {
+	resp, err := s.GetAvailabilityEndpoint(ctx, GetAvailabilityRequest{NodeID: nodeID, Incidents: incidents})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(GetAvailabilityResponse)
+	return response.Nodes, response.Err
+}
+
+// GetAvailabilityRequest collects the request parameters for the GetAvailability method.
+type GetAvailabilityRequest struct {
+	NodeID    string `json:"node"`
+	Incidents int    `json:"incidents"`
+}
+
+// GetAvailabilityResponse collects the response values for the GetAvailability method.
+type GetAvailabilityResponse struct {
+	Nodes []availability.Report `json:"nodes"`
+	Err   error                 `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements endpoint.Failer.
+func (r GetAvailabilityResponse) Failed() error { return r.Err }
+
+// MakeGetAvailabilityEndpoint constructs a GetAvailability endpoint wrapping the service.
+func MakeGetAvailabilityEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetAvailabilityRequest)
+		nodes, err := s.GetAvailability(ctx, req.NodeID, req.Incidents)
+		return GetAvailabilityResponse{Nodes: nodes, Err: err}, nil
+	}
+}
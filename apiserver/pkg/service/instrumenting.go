@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"repository/pkg/availability"
 	repo "repository/pkg/model"
 
 	"github.com/go-kit/kit/metrics"
@@ -40,3 +41,7 @@ func (mw instrumentingMiddleware) NewJob(ctx context.Context) (string, error) {
 	mw.newJobs.Add(1)
 	return id, err
 }
+
+func (mw instrumentingMiddleware) GetAvailability(ctx context.Context, nodeID string, incidents int) ([]availability.Report, error) {
+	return mw.next.GetAvailability(ctx, nodeID, incidents)
+}
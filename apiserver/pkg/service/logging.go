@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"repository/pkg/availability"
 	repo "repository/pkg/model"
 
 	"github.com/go-kit/kit/log"
@@ -33,3 +34,10 @@ func (mw loggingMiddleware) NewJob(ctx context.Context) (ID string, err error) {
 	}()
 	return mw.next.NewJob(ctx)
 }
+
+func (mw loggingMiddleware) GetAvailability(ctx context.Context, nodeID string, incidents int) (reports []availability.Report, err error) {
+	defer func() {
+		mw.logger.Log("method", "getAvailability", "node", nodeID, "incidents", incidents, "len(reports)", len(reports), "err", err)
+	}()
+	return mw.next.GetAvailability(ctx, nodeID, incidents)
+}
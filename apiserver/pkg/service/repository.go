@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+
+	"repository/pkg/natsconn"
+	reposervice "repository/pkg/service"
+	repotransport "repository/pkg/transport"
+)
+
+// Repository returns a client of the repository for a call, release is called
+// once the call is done.
+type Repository func(ctx context.Context) (svc reposervice.Service, release func(), err error)
+
+// GRPCRepository dials the repository at addr over gRPC for every call.
+func GRPCRepository(addr string, logger log.Logger) Repository {
+	return func(ctx context.Context) (reposervice.Service, func(), error) {
+		logger.Log("transport", "gRPC", "connecting to ", addr)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+		if err != nil {
+			logger.Log("transport", "gRPC", "err", err)
+			return nil, nil, ErrAPIServerUnevailable
+		}
+
+		otTracer := stdopentracing.GlobalTracer()
+		return repotransport.NewGRPCClient(conn, otTracer, logger), func() { conn.Close() }, nil
+	}
+}
+
+// NATSRepository calls the repository over NATS request/reply in messages of
+// the codec. Replicas of the repository serve the requests in a queue group,
+// each request goes to one of them, so no address of the repository is
+// needed.
+func NATSRepository(conn *natsconn.Manager, codec repotransport.Codec, logger log.Logger) Repository {
+	var (
+		mtx    sync.Mutex
+		nc     *nats.Conn
+		client reposervice.Service
+	)
+	return func(ctx context.Context) (reposervice.Service, func(), error) {
+		current := conn.Conn()
+		if current == nil || conn.Check() != nil {
+			return nil, nil, ErrAPIServerUnevailable
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		// The client is kept while the connection is, its circuitbreakers
+		// span calls. It serves every page of the UI, which polls the nodes
+		// every second, so it isn't rate limited: the repository limits its
+		// endpoints itself.
+		if current != nc {
+			nc = current
+			client = repotransport.NewNATSClientWithRate(nc, codec, rate.Inf, stdopentracing.GlobalTracer(), logger)
+		}
+		return client, func() {}, nil
+	}
+}
@@ -7,26 +7,24 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
-	stdopentracing "github.com/opentracing/opentracing-go"
-	"google.golang.org/grpc"
 
+	"repository/pkg/availability"
 	repo "repository/pkg/model"
-	repotransport "repository/pkg/transport"
-
 )
 
 // Service describes a service that represents apiserver.
 type Service interface {
 	GetAllNodes(ctx context.Context) ([]repo.Node, error)
 	NewJob(ctx context.Context) (string, error)
+	GetAvailability(ctx context.Context, nodeID string, incidents int) ([]availability.Report, error)
 }
 
-
 // New returns a basic Service with all of the expected middlewares wired in.
-func New(IP string, port string, logger log.Logger, getAllNodes, newJobs metrics.Counter) Service {
+// The repository is called through clients returned by repository.
+func New(repository Repository, logger log.Logger, getAllNodes, newJobs metrics.Counter) Service {
 	var svc Service
 	{
-		svc = APIServer{IP, port, logger}
+		svc = APIServer{repository, logger}
 		svc = LoggingMiddleware(logger)(svc)
 		svc = InstrumentingMiddleware(getAllNodes, newJobs)(svc)
 	}
@@ -38,29 +36,22 @@ var (
 	ErrAPIServerUnevailable = errors.New("can't connect to a repository")
 )
 
-
 // APIServer implements Service interface
 type APIServer struct {
-	IP     string
-	Port   string
+	repo   Repository
 	logger log.Logger
 }
 
 // GetAllNodes returs all available nodes with their jobs
 func (api APIServer) GetAllNodes(ctx context.Context) ([]repo.Node, error) {
-	grpcAddr := api.IP + api.Port
-	api.logger.Log("method", "GetAllNodes", "connecting to ", grpcAddr)
-
 	ctx, close := context.WithTimeout(ctx, time.Second)
 	defer close()
-	conn, err := grpc.DialContext(ctx, grpcAddr, grpc.WithInsecure(), grpc.WithBlock())
+	svc, release, err := api.repo(ctx)
 	if err != nil {
 		api.logger.Log("method", "GetAllNodes", "err", err)
+		return nil, err
 	}
-	defer conn.Close()
-
-	otTracer := stdopentracing.GlobalTracer() // no-op
-	svc := repotransport.NewGRPCClient(conn, otTracer, api.logger)
+	defer release()
 
 	nodes, err := svc.GetAllNodes(ctx)
 	api.logger.Log("method", "GetAllNodes", "nodes", nodes)
@@ -70,22 +61,39 @@ func (api APIServer) GetAllNodes(ctx context.Context) ([]repo.Node, error) {
 
 // NewJob starts new job on a free node
 func (api APIServer) NewJob(ctx context.Context) (string, error) {
-	grpcAddr := api.IP + api.Port
-	api.logger.Log("method", "NewJob", "connecting to ", grpcAddr)
-
 	ctx, close := context.WithTimeout(ctx, time.Second)
 	defer close()
-	conn, err := grpc.DialContext(ctx, grpcAddr, grpc.WithInsecure(), grpc.WithBlock())
+	svc, release, err := api.repo(ctx)
 	if err != nil {
 		api.logger.Log("method", "NewJob", "err", err)
+		return "", err
 	}
-	defer conn.Close()
-
-	otTracer := stdopentracing.GlobalTracer() // no-op
-	svc := repotransport.NewGRPCClient(conn, otTracer, api.logger)
+	defer release()
 
 	jID, err := svc.NewJob(ctx)
 	api.logger.Log("method", "NewJob", "job ID", jID)
 
 	return jID, err
 }
+
+// GetAvailability returns uptime, MTBF and the last incidents of the node,
+// or of all nodes if nodeID is empty.
+func (api APIServer) GetAvailability(ctx context.Context, nodeID string, incidents int) ([]availability.Report, error) {
+	ctx, close := context.WithTimeout(ctx, time.Second)
+	defer close()
+	svc, release, err := api.repo(ctx)
+	if err != nil {
+		api.logger.Log("method", "GetAvailability", "err", err)
+		return nil, err
+	}
+	defer release()
+
+	if nodeID == "" {
+		return svc.GetAvailability(ctx, incidents)
+	}
+	report, err := svc.GetNodeAvailability(ctx, nodeID, incidents)
+	if err != nil {
+		return nil, err
+	}
+	return []availability.Report{report}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	stdopentracing "github.com/opentracing/opentracing-go"
 
@@ -19,6 +20,9 @@ import (
 	"apiserver/pkg/service"
 )
 
+// ErrBadRequest is returned for requests with invalid parameters.
+var ErrBadRequest = errors.New("bad request")
+
 // NewHTTPHandler returns an HTTP handler that makes a set of endpoints
 // available on predefined paths.
 func NewHTTPHandler(endpoints endpoint.EndpointSet, otTracer stdopentracing.Tracer, logger log.Logger) http.Handler {
@@ -41,6 +45,12 @@ func NewHTTPHandler(endpoints endpoint.EndpointSet, otTracer stdopentracing.Trac
 		encodeHTTPGenericResponse,
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "NewJob", logger)))...,
 	))
+	m.Handle("/availability", httptransport.NewServer(
+		endpoints.GetAvailabilityEndpoint,
+		decodeHTTPGetAvailabilityRequest,
+		encodeHTTPGenericResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "GetAvailability", logger)))...,
+	))
 	return accessControl(m)
 }
 
@@ -65,7 +75,7 @@ func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 
 func err2code(err error) int {
 	switch err {
-	case service.ErrAPIServerUnevailable:
+	case service.ErrAPIServerUnevailable, ErrBadRequest:
 		return http.StatusBadRequest
 	}
 	return http.StatusInternalServerError
@@ -154,3 +164,27 @@ func decodeHTTPNewJobResponse(_ context.Context, r *http.Response) (interface{},
 	err := json.NewDecoder(r.Body).Decode(&resp)
 	return resp, err
 }
+
+// ======= GetAvailability ======
+
+// defaultIncidents is how many last incidents of a node are returned when
+// the request doesn't tell.
+const defaultIncidents = 10
+
+// decodeHTTPGetAvailabilityRequest is a transport/http.DecodeRequestFunc that decodes
+// a GetAvailability request from the query: an optional node ID in "node" and
+// a number of last incidents in "incidents". Primarily useful in a server.
+func decodeHTTPGetAvailabilityRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoint.GetAvailabilityRequest{
+		NodeID:    r.URL.Query().Get("node"),
+		Incidents: defaultIncidents,
+	}
+	if s := r.URL.Query().Get("incidents"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, ErrBadRequest
+		}
+		req.Incidents = n
+	}
+	return req, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+
+	"repository/pkg/backup"
+	"repository/pkg/events"
+	"repository/pkg/service"
+	store "repository/pkg/storage/gorm"
+	"repository/pkg/transport"
+)
+
+// maxDumpSize limits the size of a dump sent over the admin RPCs.
+const maxDumpSize = 64 << 20
+
+// backupFlags are shared by the export and import commands. A dump is either
+// taken from a running repository through its admin RPCs, or read from and
+// written to a storage directly, which is handy for moving between backends.
+type backupFlags struct {
+	repoAddr *string
+	storage  *string
+	boltPath *string
+	dialect  *string
+	dsn      *string
+	eventLog *string
+	timeout  *time.Duration
+}
+
+func newBackupFlags(fs *flag.FlagSet) backupFlags {
+	return backupFlags{
+		repoAddr: fs.String("repo-addr", "", "gRPC address of a running repository, the storage flags are used if empty"),
+		storage:  fs.String("storage", "gorm", "Storage of nodes: gorm, bolt or inmem"),
+		boltPath: fs.String("bolt-path", "repository.db", "Path to a bbolt file used by the bolt storage"),
+		dialect:  fs.String("dialect", store.DialectMySQL, "SQL dialect of the database: mysql or postgres"),
+		dsn:      fs.String("dsn", "root:root@tcp(mysql:3306)/repo?charset=utf8&parseTime=True&loc=Local", "Database Source Name"),
+		eventLog: fs.String("event-log", "", "Path to an append-only log of node and job events, history is skipped if empty"),
+		timeout:  fs.Duration("timeout", 30*time.Second, "How long to wait for the repository or the database"),
+	}
+}
+
+// runExport writes a dump of the repository to a file or stdout.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		bf  = newBackupFlags(fs)
+		out = fs.String("out", "-", "Path to the dump, stdout if -")
+	)
+
+	fs.Usage = usageFor(fs, os.Args[0]+" export [flags]")
+	fs.Parse(args)
+
+	logger := log.With(log.NewLogfmtLogger(os.Stderr), "ts", log.DefaultTimestampUTC)
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			logger.Log("export", *out, "err", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var (
+		stats backup.Stats
+		err   error
+	)
+	if *bf.repoAddr != "" {
+		var svc service.Service
+		var closeFn func()
+		svc, closeFn, err = dialRepo(*bf.repoAddr, *bf.timeout, logger)
+		if err == nil {
+			defer closeFn()
+			stats, err = exportRemote(w, svc, *bf.timeout)
+		}
+	} else {
+		var s service.Storage
+		var journal events.Journal
+		var closeFn func()
+		s, journal, closeFn, err = openBackupStorage(bf, logger)
+		if err == nil {
+			defer closeFn()
+			stats, err = backup.Export(w, s, journal, nil)
+		}
+	}
+	if err != nil {
+		logger.Log("export", *out, "err", err)
+		os.Exit(1)
+	}
+
+	logger.Log("export", *out, "nodes", stats.Nodes, "jobs", stats.Jobs, "events", stats.Events, "queued", stats.Queued)
+}
+
+// runImport restores a dump made by the export command.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		bf = newBackupFlags(fs)
+		in = fs.String("in", "-", "Path to the dump, stdin if -")
+	)
+
+	fs.Usage = usageFor(fs, os.Args[0]+" import [flags]")
+	fs.Parse(args)
+
+	logger := log.With(log.NewLogfmtLogger(os.Stderr), "ts", log.DefaultTimestampUTC)
+
+	r := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			logger.Log("import", *in, "err", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var (
+		stats backup.Stats
+		err   error
+	)
+	if *bf.repoAddr != "" {
+		var svc service.Service
+		var closeFn func()
+		svc, closeFn, err = dialRepo(*bf.repoAddr, *bf.timeout, logger)
+		if err == nil {
+			defer closeFn()
+			stats, err = importRemote(r, svc, *bf.timeout)
+		}
+	} else {
+		var s service.Storage
+		var journal events.Journal
+		var closeFn func()
+		s, journal, closeFn, err = openBackupStorage(bf, logger)
+		if err == nil {
+			defer closeFn()
+			stats, err = backup.Import(r, s, journal, nil)
+		}
+	}
+	if err != nil {
+		logger.Log("import", *in, "err", err)
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(stats)
+}
+
+func exportRemote(w io.Writer, svc service.Service, timeout time.Duration) (backup.Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := svc.Export(ctx)
+	if err != nil {
+		return backup.Stats{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return backup.Stats{}, err
+	}
+
+	// The dump is already written, decode it once more only to report what it holds.
+	var stats backup.Stats
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var rec backup.Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return stats, err
+		}
+		switch rec.Kind {
+		case backup.KindNode:
+			stats.Nodes++
+			stats.Jobs += len(rec.Node.Jobs)
+		case backup.KindEvent:
+			stats.Events++
+		case backup.KindQueued:
+			stats.Queued++
+		}
+	}
+}
+
+func importRemote(r io.Reader, svc service.Service, timeout time.Duration) (backup.Stats, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return backup.Stats{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return svc.Import(ctx, data)
+}
+
+// dialRepo connects to a running repository over gRPC.
+func dialRepo(addr string, timeout time.Duration, logger log.Logger) (service.Service, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxDumpSize), grpc.MaxCallSendMsgSize(maxDumpSize)),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	svc := transport.NewGRPCClient(conn, stdopentracing.GlobalTracer(), logger)
+	return svc, func() { conn.Close() }, nil
+}
+
+// openBackupStorage opens a storage and, if configured, an event log directly.
+func openBackupStorage(bf backupFlags, logger log.Logger) (service.Storage, events.Journal, func(), error) {
+	s, sCloser, err := newStorage(*bf.storage, *bf.dialect, *bf.dsn, *bf.boltPath, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The gorm storage connects in the background.
+	if ns, ok := s.(*store.NodeStorage); ok {
+		deadline := time.Now().Add(*bf.timeout)
+		for ns.DB == nil {
+			if time.Now().After(deadline) {
+				sCloser()
+				return nil, nil, nil, fmt.Errorf("no connection to the database")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	if *bf.eventLog == "" {
+		return s, events.Discard, sCloser, nil
+	}
+
+	es, err := events.NewFileStore(*bf.eventLog)
+	if err != nil {
+		sCloser()
+		return nil, nil, nil, err
+	}
+	recorder, err := events.NewRecorder(s, es, logger)
+	if err != nil {
+		es.Close()
+		sCloser()
+		return nil, nil, nil, err
+	}
+	closeFn := func() {
+		es.Close()
+		sCloser()
+	}
+	return recorder, recorder, closeFn, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// runDeadLetters lists the dead letters of a running repository, or replays
+// one of them.
+func runDeadLetters(args []string) {
+	fs := flag.NewFlagSet("dead-letters", flag.ExitOnError)
+	var (
+		repoAddr = fs.String("repo-addr", "localhost:8082", "gRPC address of a running repository")
+		limit    = fs.Int("limit", 0, "How many letters to list, the newest first, all if 0")
+		replay   = fs.String("replay", "", "ID of a letter to replay instead of listing letters")
+		timeout  = fs.Duration("timeout", 10*time.Second, "How long to wait for the repository")
+	)
+
+	fs.Usage = usageFor(fs, os.Args[0]+" dead-letters [flags]")
+	fs.Parse(args)
+
+	logger := log.With(log.NewLogfmtLogger(os.Stderr), "ts", log.DefaultTimestampUTC)
+
+	svc, closeFn, err := dialRepo(*repoAddr, *timeout, logger)
+	if err != nil {
+		logger.Log("repo-addr", *repoAddr, "err", err)
+		os.Exit(1)
+	}
+	defer closeFn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if *replay != "" {
+		if err := svc.ReplayDeadLetter(ctx, *replay); err != nil {
+			logger.Log("replay", *replay, "err", err)
+			os.Exit(1)
+		}
+		logger.Log("replay", *replay, "message", "replayed")
+		return
+	}
+
+	letters, err := svc.ListDeadLetters(ctx, *limit)
+	if err != nil {
+		logger.Log("dead-letters", *repoAddr, "err", err)
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(letters)
+}
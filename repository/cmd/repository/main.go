@@ -19,27 +19,93 @@ import (
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/prometheus"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 
+	repopb "repository/pb"
+	"repository/pkg/availability"
+	"repository/pkg/connpool"
+	"repository/pkg/deadletter"
+	"repository/pkg/dispatch"
+	"repository/pkg/election"
 	"repository/pkg/endpoint"
+	"repository/pkg/events"
+	"repository/pkg/healthcheck"
+	"repository/pkg/natsconn"
+	"repository/pkg/natsserver"
 	"repository/pkg/service"
-	"repository/pkg/transport"
+	boltstore "repository/pkg/storage/bolt"
+	"repository/pkg/storage/faulty"
 	store "repository/pkg/storage/gorm"
-	repopb "repository/pb"
+	"repository/pkg/storage/inmem"
+	"repository/pkg/transport"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "dead-letters":
+			runDeadLetters(os.Args[2:])
+			return
+		}
+	}
+
 	fs := flag.NewFlagSet("reposvc", flag.ExitOnError)
 	var (
 		debugAddr = fs.String("debug-addr", ":8080", "Debug and metrics listen address")
 		grpcAddr  = fs.String("grpc-addr", ":8082", "gRPC listen address")
 		natsAddr  = fs.String("nats-addr", nats.DefaultURL, "NATS server address")
+		natsAdmin = fs.Bool("nats-admin", false, "Serve Export, Import, ListDeadLetters and ReplayDeadLetter over NATS as well, to everyone allowed to publish on repository subjects")
+		embedded  = fs.Bool("embedded-nats", false, "Run a NATS server in the repository for workers and the apiserver, -nats-addr is ignored")
+		embedAddr = fs.String("embedded-nats-addr", ":4222", "Client listen address of the embedded NATS server")
+		embedAdv  = fs.String("embedded-nats-advertise", "", "Address workers and the apiserver reach the embedded NATS server at, defaults to the host name and the port of -embedded-nats-addr")
+		embedDir  = fs.String("embedded-nats-store", "", "Directory of JetStream streams of the embedded NATS server, JetStream is disabled if empty")
+		storageT  = fs.String("storage", "gorm", "Storage of nodes: gorm, bolt or inmem")
+		boltPath  = fs.String("bolt-path", "repository.db", "Path to a bbolt file used by the bolt storage")
+		dialect   = fs.String("dialect", store.DialectMySQL, "SQL dialect of the database: mysql or postgres")
 		dsn       = fs.String("dsn", "root:root@tcp(mysql:3306)/repo?charset=utf8&parseTime=True&loc=Local", "Database Source Name")
 		jaegerURL = fs.String("jaeger-addr", "jaeger:5775", "Jaeger server address")
+		eventLog  = fs.String("event-log", "", "Path to an append-only log of node and job events, disabled if empty")
+		stream    = fs.Bool("event-stream", true, "Publish node and job events on the NATS subjects nodes.<id>.<event> and jobs.<id>.<event>")
+		progress  = fs.Duration("event-progress-interval", 5*time.Second, "Minimal interval between progress events of a job on the event stream")
+		faultLat  = fs.Duration("fault-latency", 0, "Latency added to every storage call, for testing a slow database")
+		faultJit  = fs.Duration("fault-jitter", 0, "Random extra latency of storage calls up to this value")
+		faultRate = fs.Float64("fault-error-rate", 0, "Probability from 0 to 1 that a storage call fails, for testing a flaky database")
+		hInterval = fs.Duration("health-interval", service.DefaultHealthPolicy.Interval, "Interval between health checks of a node")
+		hTimeout  = fs.Duration("health-timeout", service.DefaultHealthPolicy.Timeout, "Timeout of a single health check")
+		unhealthy = fs.Int("unhealthy-threshold", service.DefaultHealthPolicy.UnhealthyThreshold, "Failed checks in a row which make a node unhealthy")
+		healthy   = fs.Int("healthy-threshold", service.DefaultHealthPolicy.HealthyThreshold, "Successful checks in a row which make a node healthy again")
+		removeAft = fs.Duration("remove-after", service.DefaultHealthPolicy.RemoveAfter, "How long a node stays unhealthy before it is removed, and removed before it is deleted")
+		sweepConc = fs.Int("health-concurrency", service.DefaultHealthPolicy.Concurrency, "Maximum number of nodes checked at the same time")
+		sweepTime = fs.Duration("health-sweep-timeout", service.DefaultHealthPolicy.SweepTimeout, "Deadline of a health check sweep over all nodes")
+		keepalive = fs.Duration("worker-keepalive", connpool.DefaultConfig.KeepaliveTime, "How often idle connections to workers are pinged")
+		checkIntv = fs.Duration("health-check-interval", 1*time.Second, "How often dependencies are checked for the gRPC health service")
+		incidents = fs.Int("max-incidents", availability.DefaultMaxIncidents, "How many incidents of a node are kept for availability reports")
+		retention = fs.Duration("availability-retention", availability.DefaultRetention, "How long availability history of deleted nodes is kept")
+		replicaID = fs.String("replica-id", hostname(), "Identity of this replica in leader election, unique among replicas")
+		advertise = fs.String("advertise-addr", "", "gRPC address other replicas forward writes to, defaults to the host name and the port of -grpc-addr")
+		leaseTTL  = fs.Duration("lease-ttl", election.DefaultTTL, "How long the leader lease holds without being renewed")
+		dispatchM = fs.String("dispatch", "grpc", "How new jobs reach workers: grpc calls a chosen node, nats queues them for workers with free capacity")
+		ackTime   = fs.Duration("dispatch-ack-timeout", dispatch.DefaultConfig.AckTimeout, "How long a job dispatched over NATS waits for a worker to take it before it is offered again")
+		attempts  = fs.Int("dispatch-attempts", dispatch.DefaultConfig.MaxAttempts, "How many times a job is offered to workers over NATS before it fails")
+		queueT    = fs.String("dispatch-queue", "memory", "Where jobs dispatched over NATS wait for workers: memory, or jetstream to keep them across restarts")
+		jsStream  = fs.String("jetstream-stream", dispatch.DefaultJetStreamConfig.Stream, "JetStream stream of the jetstream dispatch queue")
+		letterCap = fs.Int("dead-letters", deadletter.DefaultCapacity, "How many failed NATS messages are kept for inspection and replay")
+		jsAckWait = fs.Duration("jetstream-ack-wait", dispatch.DefaultJetStreamConfig.AckWait, "How long a job taken from the jetstream dispatch queue may go without progress before it is handed out again")
 	)
 
 	fs.Usage = usageFor(fs, os.Args[0]+" [flags]")
@@ -112,6 +178,126 @@ func main() {
 			Help:      "Request duration in seconds.",
 		}, []string{"method", "success"})
 	}
+	var poolMetrics connpool.Metrics
+	{
+		// Connections to workers.
+		poolMetrics.Open = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "worker_connections_open",
+			Help:      "Number of pooled connections to workers.",
+		}, []string{})
+		poolMetrics.Dials = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "worker_connections_dialed",
+			Help:      "Total count of new connections to workers.",
+		}, []string{})
+		poolMetrics.Reuses = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "worker_connections_reused",
+			Help:      "Total count of calls to workers over pooled connections.",
+		}, []string{})
+		poolMetrics.Evictions = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "worker_connections_evicted",
+			Help:      "Total count of closed connections to workers.",
+		}, []string{})
+	}
+	var sweepMetrics service.SweepMetrics
+	{
+		// Health check sweeps.
+		sweepMetrics.Duration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "health_sweep_duration_seconds",
+			Help:      "Duration of a health check sweep over all nodes in seconds.",
+			Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{})
+		sweepMetrics.Lag = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "health_sweep_lag_seconds",
+			Help:      "How late the last health check sweep started in seconds.",
+		}, []string{})
+		sweepMetrics.Jobs = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "jobs",
+			Help:      "Jobs of all nodes by state, counted after every health check sweep.",
+		}, []string{"state"})
+	}
+	var leaderGauge metrics.Gauge
+	{
+		// Leader election.
+		leaderGauge = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "leader",
+			Help:      "Whether this replica is the leader: 1 if it is, 0 otherwise.",
+		}, []string{})
+	}
+	var dispatchMetrics dispatch.Metrics
+	{
+		// Jobs dispatched over NATS.
+		dispatchMetrics.Dispatched = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "jobs_dispatched",
+			Help:      "Total count of jobs taken by workers over NATS.",
+		}, []string{})
+		dispatchMetrics.Redelivered = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "jobs_redelivered",
+			Help:      "Total count of jobs offered to workers again because none took them in time.",
+		}, []string{})
+		dispatchMetrics.Failed = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "jobs_dispatch_failed",
+			Help:      "Total count of jobs no worker took over NATS.",
+		}, []string{})
+		dispatchMetrics.Pending = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "jobs_pending_dispatch",
+			Help:      "Number of jobs waiting for a worker to take them over NATS.",
+		}, []string{})
+	}
+	var natsMetrics natsconn.Metrics
+	{
+		// Connection-level metrics.
+		natsMetrics.Connected = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "nats_connected",
+			Help:      "1 while the repository is connected to NATS.",
+		}, []string{})
+		natsMetrics.Disconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "nats_disconnects",
+			Help:      "Total count of connections to NATS lost.",
+		}, []string{})
+		natsMetrics.Reconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "nats_reconnects",
+			Help:      "Total count of connections to NATS restored.",
+		}, []string{})
+	}
+	var deadLetters metrics.Counter
+	{
+		deadLetters = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "repository",
+			Name:      "nats_dead_letters",
+			Help:      "Total count of NATS messages which failed and were sent to the dead-letter subject.",
+		}, []string{"subject", "stage"})
+	}
 	http.DefaultServeMux.Handle("/metrics", promhttp.Handler())
 
 	// Build the layers of the service "onion" from the inside out. First, the
@@ -120,17 +306,198 @@ func main() {
 	// the HTTP handler or the gRPC server, are the bridge between Go kit and
 	// the interfaces that the transports expect. Note that we're not binding
 	// them to ports or anything yet; we'll do that next.
+	storage, sCloser, err := newStorage(*storageT, *dialect, *dsn, *boltPath, logger)
+	if err != nil {
+		logger.Log("storage", *storageT, "err", err)
+		os.Exit(1)
+	}
+	defer sCloser()
+
+	// The storage is checked before it gets wrapped, injected faults are not
+	// a reason to stop serving.
+	checks := healthcheck.Checks{}
+	if c, ok := storage.(service.Checker); ok {
+		checks["storage"] = c.Check
+	}
+
+	// Replicas sharing a database elect a leader through a lease row in it.
+	// Other storages can't be shared, the only replica leads on its own.
+	leases, ok := storage.(election.LeaseStore)
+	if !ok {
+		leases = election.NewMemoryLeases()
+	}
+	if *advertise == "" {
+		*advertise = advertiseAddr(*grpcAddr)
+	}
+	elector := election.New(leases, election.DefaultLease, election.Holder{ID: *replicaID, Addr: *advertise}, *leaseTTL, leaderGauge, logger)
+
+	if *faultLat > 0 || *faultJit > 0 || *faultRate > 0 {
+		logger.Log("storage", *storageT, "fault-latency", *faultLat, "fault-jitter", *faultJit, "fault-error-rate", *faultRate)
+		storage = faulty.New(storage, faulty.Config{
+			Latency:   *faultLat,
+			Jitter:    *faultJit,
+			ErrorRate: *faultRate,
+		})
+	}
+
+	// An embedded NATS server is shut down after everything connected to it
+	// from the repository, workers reconnect once it is back.
+	if *embedded {
+		if *queueT == "jetstream" && *embedDir == "" {
+			logger.Log("embedded-nats", true, "err", "the jetstream dispatch queue needs -embedded-nats-store")
+			os.Exit(1)
+		}
+		if *embedAdv == "" {
+			*embedAdv = advertiseAddr(*embedAddr)
+		}
+		ns, err := natsserver.Start(natsserver.Config{
+			Name:      "repository-" + *replicaID,
+			Addr:      *embedAddr,
+			Advertise: *embedAdv,
+			StoreDir:  *embedDir,
+		}, logger)
+		if err != nil {
+			logger.Log("embedded-nats", *embedAddr, "err", err)
+			os.Exit(1)
+		}
+		defer ns.Shutdown()
+		*natsAddr = ns.ClientURL()
+	}
+
+	// The NATS connection is shared by the subscribers of the endpoints and
+	// the event stream, it is established in the background. Events published
+	// while it reconnects are buffered.
+	natsConn := natsconn.New(*natsAddr, natsconn.Config{Name: "Repository"}, natsMetrics, logger)
+	defer natsConn.Close()
+	natsHandler := transport.NewNATSHandler(natsConn, logger)
+
+	// Failed NATS messages go to the dead-letter subject, every replica
+	// keeps the last of them.
+	letters, err := deadletter.New(natsConn, *letterCap, *replicaID, deadLetters, logger)
+	if err != nil {
+		logger.Log("dead-letters", *letterCap, "err", err)
+		os.Exit(1)
+	}
+
+	// Every change of nodes and jobs goes through the recorder to the event log,
+	// which can be replayed later with the replay command, and to the event
+	// stream on NATS.
+	var journal = events.Discard
+	if *eventLog != "" || *stream {
+		var es events.Store = events.NewNopStore()
+		if *eventLog != "" {
+			file, err := events.NewFileStore(*eventLog)
+			if err != nil {
+				logger.Log("events", *eventLog, "err", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			es = file
+		}
+		if *stream {
+			es, err = events.NewStream(es, natsHandler, *progress, logger)
+			if err != nil {
+				logger.Log("events", "stream", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		recorder, err := events.NewRecorder(storage, es, logger)
+		if err != nil {
+			logger.Log("events", *eventLog, "err", err)
+			os.Exit(1)
+		}
+		storage, journal = recorder, recorder
+	}
+
+	// Availability history lives in memory, with an event log it survives
+	// restarts: registrations, health states and failed checks are replayed.
+	history := availability.New(*incidents, *retention)
+	if all, err := journal.All(); err != nil {
+		logger.Log("availability", "replay", "err", err)
+	} else {
+		history.Replay(all)
+	}
+
+	pool := connpool.New(connpool.Config{
+		KeepaliveTime:    *keepalive,
+		KeepaliveTimeout: connpool.DefaultConfig.KeepaliveTimeout,
+	}, poolMetrics, logger)
+	defer pool.Close()
+
+	// In the NATS dispatch mode jobs wait in a queue until a worker with free
+	// capacity takes them, the repository doesn't dial workers to start them.
 	var (
-		storage, sCloser = store.New(*dsn, logger)
-		service          = service.New(storage, logger, registerNodes, getAllNodes, newJobs)
-		endpoints        = endpoint.New(service, logger, duration, tracer)
-		natsSubscribers  = transport.NewNATSSubscribers(endpoints, tracer, logger)
-		grpcServer       = transport.NewGRPCServer(endpoints, tracer, logger)
+		dispatcher *dispatch.Dispatcher
+		jobQueue   service.Dispatcher // nil unless jobs are dispatched over NATS
 	)
-	defer sCloser()
+	switch *dispatchM {
+	case "grpc":
+	case "nats":
+		var queue dispatch.Queue // in memory
+		switch *queueT {
+		case "memory":
+		case "jetstream":
+			// Jobs are stored in a JetStream stream before NewJob returns and
+			// leave it once a worker took them.
+			js, err := dispatch.NewJetStreamQueue(natsConn, dispatch.JetStreamConfig{
+				Stream:  *jsStream,
+				AckWait: *jsAckWait,
+			}, logger)
+			if err != nil {
+				logger.Log("dispatch-queue", *queueT, "err", err)
+				os.Exit(1)
+			}
+			queue = js
+		default:
+			logger.Log("dispatch-queue", *queueT, "err", "unknown dispatch queue, use memory or jetstream")
+			os.Exit(1)
+		}
+		dispatcher = dispatch.New(natsHandler, queue, journal, dispatch.Config{
+			AckTimeout:  *ackTime,
+			MaxAttempts: *attempts,
+		}, dispatchMetrics, logger)
+		jobQueue = dispatcher
+	default:
+		logger.Log("dispatch", *dispatchM, "err", "unknown dispatch mode, use grpc or nats")
+		os.Exit(1)
+	}
 
-	natsCloser := transport.NewNATSHandler(*natsAddr, natsSubscribers, logger)
-	defer natsCloser()
+	var (
+		health = service.HealthPolicy{
+			Interval:           *hInterval,
+			Timeout:            *hTimeout,
+			UnhealthyThreshold: *unhealthy,
+			HealthyThreshold:   *healthy,
+			RemoveAfter:        *removeAft,
+			Concurrency:        *sweepConc,
+			SweepTimeout:       *sweepTime,
+		}
+		config = service.Config{
+			Journal:    journal,
+			Health:     health,
+			Pool:       pool,
+			Dispatcher: jobQueue,
+			History:    history,
+			Letters:    letters,
+			Leader:     elector,
+			Sweep:      sweepMetrics,
+		}
+		service         = service.New(storage, config, logger, registerNodes, getAllNodes, newJobs)
+		forward         = election.Forward(elector, logger, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxDumpSize), grpc.MaxCallSendMsgSize(maxDumpSize)))
+		endpoints       = endpoint.New(forward(service), logger, duration, tracer)
+		natsSubscribers = transport.NewNATSSubscribers(endpoints, letters, *natsAdmin, tracer, logger)
+		grpcServer      = transport.NewGRPCServer(endpoints, tracer, logger)
+	)
+
+	natsHandler.Handle(natsSubscribers)
+
+	// grpc.health.v1.Health reports the repository NOT_SERVING while its
+	// database or NATS is unavailable.
+	checks["nats"] = natsHandler.Check
+	healthServer := grpchealth.NewServer()
+	healthWatcher := healthcheck.Watch(healthServer, []string{"pb.repo.Repo"}, checks, *checkIntv, logger)
+	defer healthWatcher.Stop()
 
 	// Now we're to the part of the func main where we want to start actually
 	// running things, like servers bound to listeners to receive connections.
@@ -172,13 +539,43 @@ func main() {
 			logger.Log("transport", "gRPC", "addr", *grpcAddr)
 			// we add the Go Kit gRPC Interceptor to our gRPC service as it is used by
 			// the here demonstrated zipkin tracing middleware.
-			baseServer := grpc.NewServer(grpc.UnaryInterceptor(grpctransport.Interceptor))
+			// Dumps sent by the export and import admin RPCs can be large.
+			baseServer := grpc.NewServer(
+				grpc.UnaryInterceptor(grpctransport.Interceptor),
+				grpc.MaxRecvMsgSize(maxDumpSize),
+				grpc.MaxSendMsgSize(maxDumpSize),
+			)
 			repopb.RegisterRepoServer(baseServer, grpcServer)
+			healthpb.RegisterHealthServer(baseServer, healthServer)
 			return baseServer.Serve(grpcListener)
 		}, func(error) {
 			grpcListener.Close()
 		})
 	}
+	{
+		// The elector competes for the lease and releases it on shutdown,
+		// another replica takes over without waiting for it to expire.
+		stopElection := make(chan struct{})
+		g.Add(func() error {
+			logger.Log("election", election.DefaultLease, "replica", *replicaID, "addr", *advertise, "ttl", *leaseTTL)
+			elector.Run(stopElection)
+			return nil
+		}, func(error) {
+			close(stopElection)
+		})
+	}
+	if dispatcher != nil {
+		// The dispatcher offers queued jobs to workers, jobs still queued on
+		// shutdown are dropped.
+		stopDispatch := make(chan struct{})
+		g.Add(func() error {
+			logger.Log("dispatch", "nats", "ack-timeout", *ackTime, "attempts", *attempts)
+			dispatcher.Run(stopDispatch)
+			return nil
+		}, func(error) {
+			close(stopDispatch)
+		})
+	}
 	{
 		// This function just sits and waits for ctrl-C.
 		cancelInterrupt := make(chan struct{})
@@ -198,6 +595,38 @@ func main() {
 	logger.Log("exit", g.Run())
 }
 
+// newStorage creates a storage of nodes by its name.
+func newStorage(name, dialect, dsn, boltPath string, logger log.Logger) (service.Storage, func(), error) {
+	switch name {
+	case "gorm":
+		return store.New(dialect, dsn, logger)
+	case "bolt":
+		return boltstore.New(boltPath, logger)
+	case "inmem":
+		return inmem.New(), func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage %q", name)
+}
+
+// hostname returns the host name, which identifies a replica unless
+// -replica-id is given.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "repository"
+	}
+	return name
+}
+
+// advertiseAddr makes the address other replicas reach the gRPC listener at.
+func advertiseAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil || host == "" || host == "0.0.0.0" || host == "::" {
+		host = hostname()
+	}
+	return net.JoinHostPort(host, port)
+}
+
 func usageFor(fs *flag.FlagSet, short string) func() {
 	return func() {
 		fmt.Fprintf(os.Stderr, "USAGE\n")
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+)
+
+// replayResult is printed by the replay command.
+type replayResult struct {
+	Seq      uint64               `json:"seq"`
+	Time     time.Time            `json:"time"`
+	Nodes    []repo.Node          `json:"nodes"`
+	Archived []events.ArchivedJob `json:"archived,omitempty"`
+}
+
+// runReplay rebuilds the state of the repository from an event log as it was
+// at a given moment and prints it as JSON.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var (
+		eventLog = fs.String("event-log", "events.log", "Path to an event log written by the repository")
+		at       = fs.String("at", "", "Point in time in RFC3339 format, the latest state if empty")
+		archived = fs.Bool("archived", false, "Print archived jobs too")
+	)
+
+	fs.Usage = usageFor(fs, os.Args[0]+" replay [flags]")
+	fs.Parse(args)
+
+	var until time.Time
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -at: %v\n", err)
+			os.Exit(1)
+		}
+		until = t
+	}
+
+	if _, err := os.Stat(*eventLog); err != nil {
+		fmt.Fprintf(os.Stderr, "can't open the event log: %v\n", err)
+		os.Exit(1)
+	}
+	es, err := events.NewFileStore(*eventLog)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't open the event log: %v\n", err)
+		os.Exit(1)
+	}
+	defer es.Close()
+
+	all, err := es.All()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read the event log: %v\n", err)
+		os.Exit(1)
+	}
+
+	p := events.Replay(all, until)
+	result := replayResult{
+		Seq:   p.Seq(),
+		Time:  p.Time(),
+		Nodes: p.Nodes(),
+	}
+	if *archived {
+		result.Archived = p.Archived()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"repository/pkg/service"
+	boltstore "repository/pkg/storage/bolt"
+	store "repository/pkg/storage/gorm"
+	"repository/pkg/storage/inmem"
+	"repository/pkg/storage/storagecheck"
+)
+
+// storagecheck runs the storage conformance suite against a real database.
+// It can be pointed to any engine speaking the MySQL or PostgreSQL protocol,
+// including embedded stand-ins used in CI.
+func main() {
+	fs := flag.NewFlagSet("storagecheck", flag.ExitOnError)
+	var (
+		storage = fs.String("storage", "gorm", "Storage implementation to check: gorm, bolt or inmem")
+		dialect = fs.String("dialect", store.DialectMySQL, "SQL dialect of the gorm storage: mysql or postgres")
+		dsn     = fs.String("dsn", "root:root@tcp(localhost:3306)/repo?charset=utf8&parseTime=True&loc=Local", "Database Source Name")
+		path    = fs.String("bolt-path", "storagecheck.db", "Path to a bbolt file used by the bolt storage")
+		timeout = fs.Duration("timeout", 30*time.Second, "How long to wait for a connection to the database")
+	)
+
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags]")
+	fs.Parse(os.Args[1:])
+
+	var logger log.Logger
+	{
+		logger = log.NewLogfmtLogger(os.Stderr)
+		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	}
+
+	var s service.Storage
+	switch *storage {
+	case "inmem":
+		s = inmem.New()
+	case "bolt":
+		ns, closeFn, err := boltstore.New(*path, logger)
+		if err != nil {
+			logger.Log("storage", "bolt", "err", err)
+			os.Exit(1)
+		}
+		defer closeFn()
+		s = ns
+	case "gorm":
+		ns, closeFn, err := store.New(*dialect, *dsn, logger)
+		if err != nil {
+			logger.Log("storage", "gorm", "err", err)
+			os.Exit(1)
+		}
+		defer closeFn()
+
+		deadline := time.Now().Add(*timeout)
+		for ns.DB == nil {
+			if time.Now().After(deadline) {
+				logger.Log("storage", "gorm", "err", "no connection to the database")
+				os.Exit(1)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		s = ns
+	default:
+		logger.Log("storage", *storage, "err", "unknown storage")
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, c := range storagecheck.Checks {
+		err := c.Run(s)
+		if err != nil {
+			failed++
+		}
+		logger.Log("check", c.Name, "ok", err == nil, "err", err)
+	}
+	if failed > 0 {
+		logger.Log("storage", *storage, "failed", failed)
+		os.Exit(1)
+	}
+}
+
+func usageFor(fs *flag.FlagSet, short string) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "USAGE\n")
+		fmt.Fprintf(os.Stderr, "  %s\n", short)
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "FLAGS\n")
+		w := tabwriter.NewWriter(os.Stderr, 0, 2, 2, ' ', 0)
+		fs.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "\t-%s %s\t%s\n", f.Name, f.DefValue, f.Usage)
+		})
+		w.Flush()
+		fmt.Fprintf(os.Stderr, "\n")
+	}
+}
@@ -6,11 +6,10 @@ package pb_repo
 import (
 	context "context"
 	fmt "fmt"
-	math "math"
-
 	proto "github.com/golang/protobuf/proto"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	grpc "google.golang.org/grpc"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -29,6 +28,7 @@ type RegisterNodeRequest struct {
 	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	NodeIP               string   `protobuf:"bytes,2,opt,name=nodeIP,proto3" json:"nodeIP,omitempty"`
 	NodePort             string   `protobuf:"bytes,3,opt,name=nodePort,proto3" json:"nodePort,omitempty"`
+	NodeID               string   `protobuf:"bytes,4,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -80,9 +80,17 @@ func (m *RegisterNodeRequest) GetNodePort() string {
 	return ""
 }
 
+func (m *RegisterNodeRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
 type RegisterNodeReply struct {
 	NodeID               string   `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
 	Err                  string   `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	Generation           uint64   `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -127,6 +135,13 @@ func (m *RegisterNodeReply) GetErr() string {
 	return ""
 }
 
+func (m *RegisterNodeReply) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
 // ===========GetAllNodes===========
 type GetAllNodesRequest struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -207,15 +222,18 @@ func (m *GetAllNodesReply) GetErr() string {
 }
 
 type Node struct {
-	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	IP                   string   `protobuf:"bytes,3,opt,name=IP,proto3" json:"IP,omitempty"`
-	Port                 string   `protobuf:"bytes,4,opt,name=port,proto3" json:"port,omitempty"`
-	JobsCount            int32    `protobuf:"varint,5,opt,name=jobsCount,proto3" json:"jobsCount,omitempty"`
-	Jobs                 []*Job   `protobuf:"bytes,6,rep,name=jobs,proto3" json:"jobs,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	ID                   string               `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Name                 string               `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	IP                   string               `protobuf:"bytes,3,opt,name=IP,proto3" json:"IP,omitempty"`
+	Port                 string               `protobuf:"bytes,4,opt,name=port,proto3" json:"port,omitempty"`
+	JobsCount            int32                `protobuf:"varint,5,opt,name=jobsCount,proto3" json:"jobsCount,omitempty"`
+	Jobs                 []*Job               `protobuf:"bytes,6,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	State                string               `protobuf:"bytes,7,opt,name=state,proto3" json:"state,omitempty"`
+	StateSince           *timestamp.Timestamp `protobuf:"bytes,8,opt,name=stateSince,proto3" json:"stateSince,omitempty"`
+	Generation           uint64               `protobuf:"varint,9,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *Node) Reset()         { *m = Node{} }
@@ -285,6 +303,27 @@ func (m *Node) GetJobs() []*Job {
 	return nil
 }
 
+func (m *Node) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Node) GetStateSince() *timestamp.Timestamp {
+	if m != nil {
+		return m.StateSince
+	}
+	return nil
+}
+
+func (m *Node) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
 type Job struct {
 	ID                   string               `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
 	Per                  float32              `protobuf:"fixed32,2,opt,name=per,proto3" json:"per,omitempty"`
@@ -435,179 +474,1674 @@ func (m *NewJobReply) GetErr() string {
 	return ""
 }
 
-func init() {
-	proto.RegisterType((*RegisterNodeRequest)(nil), "pb.repo.RegisterNodeRequest")
-	proto.RegisterType((*RegisterNodeReply)(nil), "pb.repo.RegisterNodeReply")
-	proto.RegisterType((*GetAllNodesRequest)(nil), "pb.repo.GetAllNodesRequest")
-	proto.RegisterType((*GetAllNodesReply)(nil), "pb.repo.GetAllNodesReply")
-	proto.RegisterType((*Node)(nil), "pb.repo.Node")
-	proto.RegisterType((*Job)(nil), "pb.repo.Job")
-	proto.RegisterType((*NewJobRequest)(nil), "pb.repo.NewJobRequest")
-	proto.RegisterType((*NewJobReply)(nil), "pb.repo.NewJobReply")
+// ===========Export===========
+type ExportRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("repo.proto", fileDescriptor_9a6377fc15c39a05) }
-
-var fileDescriptor_9a6377fc15c39a05 = []byte{
-	// 450 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0xcd, 0x6e, 0xd3, 0x40,
-	0x10, 0xc6, 0x3f, 0x09, 0x64, 0xd2, 0x42, 0x18, 0xaa, 0xca, 0x98, 0x4a, 0x44, 0xe6, 0x92, 0x93,
-	0x23, 0x85, 0x4b, 0x85, 0xc4, 0x01, 0x51, 0xa9, 0x4a, 0x0e, 0x55, 0xb4, 0xea, 0x95, 0x43, 0xac,
-	0x4c, 0x83, 0x91, 0xe3, 0x5d, 0xec, 0x8d, 0x50, 0x5f, 0x82, 0xf7, 0xe1, 0x41, 0x78, 0x1f, 0x34,
-	0xeb, 0xcd, 0xc6, 0x51, 0x8c, 0x7a, 0x9b, 0x9f, 0x6f, 0xbe, 0xfd, 0x66, 0xf6, 0x03, 0xa8, 0x48,
-	0xc9, 0x54, 0x55, 0x52, 0x4b, 0x7c, 0xae, 0xb2, 0x94, 0xd3, 0xf8, 0xfd, 0x46, 0xca, 0x4d, 0x41,
-	0x53, 0x53, 0xce, 0x76, 0x0f, 0x53, 0x9d, 0x6f, 0xa9, 0xd6, 0xab, 0xad, 0x6a, 0x90, 0xc9, 0x37,
-	0x78, 0x23, 0x68, 0x93, 0xd7, 0x9a, 0xaa, 0x3b, 0xb9, 0x26, 0x41, 0x3f, 0x77, 0x54, 0x6b, 0x44,
-	0x08, 0xcb, 0xd5, 0x96, 0x22, 0x6f, 0xec, 0x4d, 0x06, 0xc2, 0xc4, 0x78, 0x09, 0xfd, 0x52, 0xae,
-	0x69, 0xbe, 0x8c, 0x7c, 0x53, 0xb5, 0x19, 0xc6, 0xf0, 0x82, 0xa3, 0xa5, 0xac, 0x74, 0x14, 0x98,
-	0x8e, 0xcb, 0x93, 0xcf, 0xf0, 0xfa, 0x98, 0x5e, 0x15, 0x8f, 0x8e, 0xe8, 0xc6, 0xd2, 0xdb, 0x0c,
-	0x47, 0x10, 0x50, 0x55, 0x59, 0x76, 0x0e, 0x93, 0x0b, 0xc0, 0x5b, 0xd2, 0x5f, 0x8a, 0x82, 0x87,
-	0x6b, 0x2b, 0x2e, 0x99, 0xc3, 0xe8, 0xa8, 0xca, 0x9c, 0x1f, 0xa0, 0xc7, 0x2c, 0x75, 0xe4, 0x8d,
-	0x83, 0xc9, 0x70, 0x76, 0x9e, 0xda, 0x0b, 0xa4, 0xe6, 0xd9, 0xa6, 0xd7, 0xf1, 0xc0, 0x6f, 0x0f,
-	0x42, 0x46, 0xe0, 0x4b, 0xf0, 0x9d, 0x1e, 0x7f, 0x7e, 0xe3, 0x0e, 0xe0, 0xb7, 0x0e, 0xc0, 0x98,
-	0xa5, 0x5d, 0xd1, 0x9f, 0x2f, 0x19, 0xa3, 0x78, 0xe9, 0xb0, 0xc1, 0x70, 0x8c, 0x57, 0x30, 0xf8,
-	0x21, 0xb3, 0xfa, 0xab, 0xdc, 0x95, 0x3a, 0xea, 0x8d, 0xbd, 0x49, 0x4f, 0x1c, 0x0a, 0x38, 0x86,
-	0x90, 0x93, 0xa8, 0x6f, 0x44, 0x9e, 0x39, 0x91, 0x0b, 0x99, 0x09, 0xd3, 0x49, 0xfe, 0x78, 0x10,
-	0x2c, 0x64, 0x76, 0xa2, 0x67, 0x04, 0x81, 0xa2, 0x46, 0xba, 0x2f, 0x38, 0xe4, 0xb3, 0xaf, 0x77,
-	0xd5, 0x4a, 0xe7, 0xb2, 0x34, 0x9a, 0x7c, 0xe1, 0x72, 0xbc, 0x86, 0x41, 0xad, 0x57, 0x95, 0xbe,
-	0xcf, 0xb7, 0x64, 0xe4, 0x0d, 0x67, 0x71, 0xda, 0x58, 0x21, 0xdd, 0x5b, 0x21, 0xbd, 0xdf, 0x5b,
-	0x41, 0x1c, 0xc0, 0xf8, 0x09, 0xe0, 0x21, 0x2f, 0xf3, 0xfa, 0xbb, 0x19, 0xed, 0x3d, 0x39, 0xda,
-	0x42, 0x27, 0xaf, 0xe0, 0xfc, 0x8e, 0x7e, 0xf1, 0x2e, 0xf6, 0xa3, 0xa6, 0x30, 0xdc, 0x17, 0xf8,
-	0x8f, 0x3a, 0x76, 0x3a, 0xfe, 0x8e, 0xd9, 0x5f, 0x0f, 0x42, 0x41, 0x4a, 0xe2, 0x02, 0xce, 0xda,
-	0xbe, 0xc1, 0x2b, 0x77, 0xaa, 0x0e, 0xb7, 0xc6, 0xf1, 0x7f, 0xba, 0xaa, 0x78, 0x4c, 0x9e, 0xe1,
-	0x2d, 0x0c, 0x5b, 0x76, 0xc1, 0x77, 0x0e, 0x7c, 0x6a, 0xad, 0xf8, 0x6d, 0x77, 0xb3, 0x21, 0xba,
-	0x86, 0x7e, 0xb3, 0x0e, 0x5e, 0x1e, 0xec, 0xd5, 0x5e, 0x38, 0xbe, 0x38, 0xa9, 0x9b, 0xc9, 0xac,
-	0x6f, 0x2e, 0xf7, 0xf1, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xf9, 0xe4, 0x89, 0xc0, 0xa4, 0x03,
-	0x00, 0x00,
+func (m *ExportRequest) Reset()         { *m = ExportRequest{} }
+func (m *ExportRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportRequest) ProtoMessage()    {}
+func (*ExportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{8}
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *ExportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportRequest.Unmarshal(m, b)
+}
+func (m *ExportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportRequest.Marshal(b, m, deterministic)
+}
+func (m *ExportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportRequest.Merge(m, src)
+}
+func (m *ExportRequest) XXX_Size() int {
+	return xxx_messageInfo_ExportRequest.Size(m)
+}
+func (m *ExportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportRequest.DiscardUnknown(m)
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+var xxx_messageInfo_ExportRequest proto.InternalMessageInfo
 
-// RepoClient is the client API for Repo service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type RepoClient interface {
-	// Register new node
-	RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeReply, error)
-	// GetAllNodes returns all notes with jobs that registared in the Repository
-	GetAllNodes(ctx context.Context, in *GetAllNodesRequest, opts ...grpc.CallOption) (*GetAllNodesReply, error)
-	// NewJob create a new job on a free node. Returns a ID of a new created job
-	NewJob(ctx context.Context, in *NewJobRequest, opts ...grpc.CallOption) (*NewJobReply, error)
+type ExportReply struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Err                  string   `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-type repoClient struct {
-	cc *grpc.ClientConn
+func (m *ExportReply) Reset()         { *m = ExportReply{} }
+func (m *ExportReply) String() string { return proto.CompactTextString(m) }
+func (*ExportReply) ProtoMessage()    {}
+func (*ExportReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{9}
 }
 
-func NewRepoClient(cc *grpc.ClientConn) RepoClient {
-	return &repoClient{cc}
+func (m *ExportReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportReply.Unmarshal(m, b)
+}
+func (m *ExportReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportReply.Marshal(b, m, deterministic)
+}
+func (m *ExportReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportReply.Merge(m, src)
+}
+func (m *ExportReply) XXX_Size() int {
+	return xxx_messageInfo_ExportReply.Size(m)
+}
+func (m *ExportReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportReply.DiscardUnknown(m)
 }
 
-func (c *repoClient) RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeReply, error) {
-	out := new(RegisterNodeReply)
-	err := c.cc.Invoke(ctx, "/pb.repo.Repo/RegisterNode", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_ExportReply proto.InternalMessageInfo
+
+func (m *ExportReply) GetData() []byte {
+	if m != nil {
+		return m.Data
 	}
-	return out, nil
+	return nil
 }
 
-func (c *repoClient) GetAllNodes(ctx context.Context, in *GetAllNodesRequest, opts ...grpc.CallOption) (*GetAllNodesReply, error) {
-	out := new(GetAllNodesReply)
-	err := c.cc.Invoke(ctx, "/pb.repo.Repo/GetAllNodes", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *ExportReply) GetErr() string {
+	if m != nil {
+		return m.Err
 	}
-	return out, nil
+	return ""
 }
 
-func (c *repoClient) NewJob(ctx context.Context, in *NewJobRequest, opts ...grpc.CallOption) (*NewJobReply, error) {
-	out := new(NewJobReply)
-	err := c.cc.Invoke(ctx, "/pb.repo.Repo/NewJob", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+// ===========Import===========
+type ImportRequest struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-// RepoServer is the server API for Repo service.
-type RepoServer interface {
-	// Register new node
-	RegisterNode(context.Context, *RegisterNodeRequest) (*RegisterNodeReply, error)
-	// GetAllNodes returns all notes with jobs that registared in the Repository
-	GetAllNodes(context.Context, *GetAllNodesRequest) (*GetAllNodesReply, error)
-	// NewJob create a new job on a free node. Returns a ID of a new created job
-	NewJob(context.Context, *NewJobRequest) (*NewJobReply, error)
+func (m *ImportRequest) Reset()         { *m = ImportRequest{} }
+func (m *ImportRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportRequest) ProtoMessage()    {}
+func (*ImportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{10}
 }
 
-func RegisterRepoServer(s *grpc.Server, srv RepoServer) {
-	s.RegisterService(&_Repo_serviceDesc, srv)
+func (m *ImportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportRequest.Unmarshal(m, b)
+}
+func (m *ImportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportRequest.Marshal(b, m, deterministic)
+}
+func (m *ImportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportRequest.Merge(m, src)
+}
+func (m *ImportRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportRequest.Size(m)
+}
+func (m *ImportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportRequest.DiscardUnknown(m)
 }
 
-func _Repo_RegisterNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RegisterNodeRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(RepoServer).RegisterNode(ctx, in)
+var xxx_messageInfo_ImportRequest proto.InternalMessageInfo
+
+func (m *ImportRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pb.repo.Repo/RegisterNode",
+	return nil
+}
+
+type ImportReply struct {
+	Nodes                int32    `protobuf:"varint,1,opt,name=nodes,proto3" json:"nodes,omitempty"`
+	Jobs                 int32    `protobuf:"varint,2,opt,name=jobs,proto3" json:"jobs,omitempty"`
+	Events               int32    `protobuf:"varint,3,opt,name=events,proto3" json:"events,omitempty"`
+	Err                  string   `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
+	Queued               int32    `protobuf:"varint,5,opt,name=queued,proto3" json:"queued,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportReply) Reset()         { *m = ImportReply{} }
+func (m *ImportReply) String() string { return proto.CompactTextString(m) }
+func (*ImportReply) ProtoMessage()    {}
+func (*ImportReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{11}
+}
+
+func (m *ImportReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportReply.Unmarshal(m, b)
+}
+func (m *ImportReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportReply.Marshal(b, m, deterministic)
+}
+func (m *ImportReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportReply.Merge(m, src)
+}
+func (m *ImportReply) XXX_Size() int {
+	return xxx_messageInfo_ImportReply.Size(m)
+}
+func (m *ImportReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportReply proto.InternalMessageInfo
+
+func (m *ImportReply) GetNodes() int32 {
+	if m != nil {
+		return m.Nodes
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RepoServer).RegisterNode(ctx, req.(*RegisterNodeRequest))
+	return 0
+}
+
+func (m *ImportReply) GetJobs() int32 {
+	if m != nil {
+		return m.Jobs
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-func _Repo_GetAllNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetAllNodesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *ImportReply) GetEvents() int32 {
+	if m != nil {
+		return m.Events
 	}
-	if interceptor == nil {
-		return srv.(RepoServer).GetAllNodes(ctx, in)
+	return 0
+}
+
+func (m *ImportReply) GetErr() string {
+	if m != nil {
+		return m.Err
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pb.repo.Repo/GetAllNodes",
+	return ""
+}
+
+func (m *ImportReply) GetQueued() int32 {
+	if m != nil {
+		return m.Queued
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RepoServer).GetAllNodes(ctx, req.(*GetAllNodesRequest))
+	return 0
+}
+
+// ===========Heartbeat===========
+type HeartbeatRequest struct {
+	NodeID               string   `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	JobsCount            int32    `protobuf:"varint,2,opt,name=jobsCount,proto3" json:"jobsCount,omitempty"`
+	Jobs                 []*Job   `protobuf:"bytes,3,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	Full                 bool     `protobuf:"varint,4,opt,name=full,proto3" json:"full,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatRequest) Reset()         { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()    {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{12}
+}
+
+func (m *HeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatRequest.Unmarshal(m, b)
+}
+func (m *HeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatRequest.Merge(m, src)
+}
+func (m *HeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatRequest.Size(m)
+}
+func (m *HeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatRequest proto.InternalMessageInfo
+
+func (m *HeartbeatRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _Repo_NewJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(NewJobRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *HeartbeatRequest) GetJobsCount() int32 {
+	if m != nil {
+		return m.JobsCount
 	}
-	if interceptor == nil {
-		return srv.(RepoServer).NewJob(ctx, in)
+	return 0
+}
+
+func (m *HeartbeatRequest) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pb.repo.Repo/NewJob",
+	return nil
+}
+
+func (m *HeartbeatRequest) GetFull() bool {
+	if m != nil {
+		return m.Full
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RepoServer).NewJob(ctx, req.(*NewJobRequest))
+	return false
+}
+
+type HeartbeatReply struct {
+	Err                  string   `protobuf:"bytes,1,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatReply) Reset()         { *m = HeartbeatReply{} }
+func (m *HeartbeatReply) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatReply) ProtoMessage()    {}
+func (*HeartbeatReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{13}
+}
+
+func (m *HeartbeatReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatReply.Unmarshal(m, b)
+}
+func (m *HeartbeatReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatReply.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatReply.Merge(m, src)
+}
+func (m *HeartbeatReply) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatReply.Size(m)
+}
+func (m *HeartbeatReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatReply proto.InternalMessageInfo
+
+func (m *HeartbeatReply) GetErr() string {
+	if m != nil {
+		return m.Err
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-var _Repo_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "pb.repo.Repo",
-	HandlerType: (*RepoServer)(nil),
-	Methods: []grpc.MethodDesc{
+// ===========Availability===========
+type Incident struct {
+	Start                *timestamp.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End                  *timestamp.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	State                string               `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	FailedChecks         int32                `protobuf:"varint,4,opt,name=failedChecks,proto3" json:"failedChecks,omitempty"`
+	Err                  string               `protobuf:"bytes,5,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Incident) Reset()         { *m = Incident{} }
+func (m *Incident) String() string { return proto.CompactTextString(m) }
+func (*Incident) ProtoMessage()    {}
+func (*Incident) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{14}
+}
+
+func (m *Incident) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Incident.Unmarshal(m, b)
+}
+func (m *Incident) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Incident.Marshal(b, m, deterministic)
+}
+func (m *Incident) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Incident.Merge(m, src)
+}
+func (m *Incident) XXX_Size() int {
+	return xxx_messageInfo_Incident.Size(m)
+}
+func (m *Incident) XXX_DiscardUnknown() {
+	xxx_messageInfo_Incident.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Incident proto.InternalMessageInfo
+
+func (m *Incident) GetStart() *timestamp.Timestamp {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *Incident) GetEnd() *timestamp.Timestamp {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+func (m *Incident) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Incident) GetFailedChecks() int32 {
+	if m != nil {
+		return m.FailedChecks
+	}
+	return 0
+}
+
+func (m *Incident) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type Availability struct {
+	NodeID               string               `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	Name                 string               `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	State                string               `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Registered           *timestamp.Timestamp `protobuf:"bytes,4,opt,name=registered,proto3" json:"registered,omitempty"`
+	Deleted              *timestamp.Timestamp `protobuf:"bytes,5,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Uptime               float64              `protobuf:"fixed64,6,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	Up                   float64              `protobuf:"fixed64,7,opt,name=up,proto3" json:"up,omitempty"`
+	Down                 float64              `protobuf:"fixed64,8,opt,name=down,proto3" json:"down,omitempty"`
+	Failures             int32                `protobuf:"varint,9,opt,name=failures,proto3" json:"failures,omitempty"`
+	Mtbf                 float64              `protobuf:"fixed64,10,opt,name=mtbf,proto3" json:"mtbf,omitempty"`
+	Incidents            []*Incident          `protobuf:"bytes,11,rep,name=incidents,proto3" json:"incidents,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Availability) Reset()         { *m = Availability{} }
+func (m *Availability) String() string { return proto.CompactTextString(m) }
+func (*Availability) ProtoMessage()    {}
+func (*Availability) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{15}
+}
+
+func (m *Availability) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Availability.Unmarshal(m, b)
+}
+func (m *Availability) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Availability.Marshal(b, m, deterministic)
+}
+func (m *Availability) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Availability.Merge(m, src)
+}
+func (m *Availability) XXX_Size() int {
+	return xxx_messageInfo_Availability.Size(m)
+}
+func (m *Availability) XXX_DiscardUnknown() {
+	xxx_messageInfo_Availability.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Availability proto.InternalMessageInfo
+
+func (m *Availability) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
+func (m *Availability) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Availability) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Availability) GetRegistered() *timestamp.Timestamp {
+	if m != nil {
+		return m.Registered
+	}
+	return nil
+}
+
+func (m *Availability) GetDeleted() *timestamp.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+func (m *Availability) GetUptime() float64 {
+	if m != nil {
+		return m.Uptime
+	}
+	return 0
+}
+
+func (m *Availability) GetUp() float64 {
+	if m != nil {
+		return m.Up
+	}
+	return 0
+}
+
+func (m *Availability) GetDown() float64 {
+	if m != nil {
+		return m.Down
+	}
+	return 0
+}
+
+func (m *Availability) GetFailures() int32 {
+	if m != nil {
+		return m.Failures
+	}
+	return 0
+}
+
+func (m *Availability) GetMtbf() float64 {
+	if m != nil {
+		return m.Mtbf
+	}
+	return 0
+}
+
+func (m *Availability) GetIncidents() []*Incident {
+	if m != nil {
+		return m.Incidents
+	}
+	return nil
+}
+
+type GetAvailabilityRequest struct {
+	Incidents            int32    `protobuf:"varint,1,opt,name=incidents,proto3" json:"incidents,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetAvailabilityRequest) Reset()         { *m = GetAvailabilityRequest{} }
+func (m *GetAvailabilityRequest) String() string { return proto.CompactTextString(m) }
+func (*GetAvailabilityRequest) ProtoMessage()    {}
+func (*GetAvailabilityRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{16}
+}
+
+func (m *GetAvailabilityRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetAvailabilityRequest.Unmarshal(m, b)
+}
+func (m *GetAvailabilityRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetAvailabilityRequest.Marshal(b, m, deterministic)
+}
+func (m *GetAvailabilityRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetAvailabilityRequest.Merge(m, src)
+}
+func (m *GetAvailabilityRequest) XXX_Size() int {
+	return xxx_messageInfo_GetAvailabilityRequest.Size(m)
+}
+func (m *GetAvailabilityRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetAvailabilityRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetAvailabilityRequest proto.InternalMessageInfo
+
+func (m *GetAvailabilityRequest) GetIncidents() int32 {
+	if m != nil {
+		return m.Incidents
+	}
+	return 0
+}
+
+type GetAvailabilityReply struct {
+	Nodes                []*Availability `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Err                  string          `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *GetAvailabilityReply) Reset()         { *m = GetAvailabilityReply{} }
+func (m *GetAvailabilityReply) String() string { return proto.CompactTextString(m) }
+func (*GetAvailabilityReply) ProtoMessage()    {}
+func (*GetAvailabilityReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{17}
+}
+
+func (m *GetAvailabilityReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetAvailabilityReply.Unmarshal(m, b)
+}
+func (m *GetAvailabilityReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetAvailabilityReply.Marshal(b, m, deterministic)
+}
+func (m *GetAvailabilityReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetAvailabilityReply.Merge(m, src)
+}
+func (m *GetAvailabilityReply) XXX_Size() int {
+	return xxx_messageInfo_GetAvailabilityReply.Size(m)
+}
+func (m *GetAvailabilityReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetAvailabilityReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetAvailabilityReply proto.InternalMessageInfo
+
+func (m *GetAvailabilityReply) GetNodes() []*Availability {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+func (m *GetAvailabilityReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type GetNodeAvailabilityRequest struct {
+	NodeID               string   `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	Incidents            int32    `protobuf:"varint,2,opt,name=incidents,proto3" json:"incidents,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetNodeAvailabilityRequest) Reset()         { *m = GetNodeAvailabilityRequest{} }
+func (m *GetNodeAvailabilityRequest) String() string { return proto.CompactTextString(m) }
+func (*GetNodeAvailabilityRequest) ProtoMessage()    {}
+func (*GetNodeAvailabilityRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{18}
+}
+
+func (m *GetNodeAvailabilityRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetNodeAvailabilityRequest.Unmarshal(m, b)
+}
+func (m *GetNodeAvailabilityRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetNodeAvailabilityRequest.Marshal(b, m, deterministic)
+}
+func (m *GetNodeAvailabilityRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetNodeAvailabilityRequest.Merge(m, src)
+}
+func (m *GetNodeAvailabilityRequest) XXX_Size() int {
+	return xxx_messageInfo_GetNodeAvailabilityRequest.Size(m)
+}
+func (m *GetNodeAvailabilityRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetNodeAvailabilityRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetNodeAvailabilityRequest proto.InternalMessageInfo
+
+func (m *GetNodeAvailabilityRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
+func (m *GetNodeAvailabilityRequest) GetIncidents() int32 {
+	if m != nil {
+		return m.Incidents
+	}
+	return 0
+}
+
+type GetNodeAvailabilityReply struct {
+	Node                 *Availability `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Err                  string        `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *GetNodeAvailabilityReply) Reset()         { *m = GetNodeAvailabilityReply{} }
+func (m *GetNodeAvailabilityReply) String() string { return proto.CompactTextString(m) }
+func (*GetNodeAvailabilityReply) ProtoMessage()    {}
+func (*GetNodeAvailabilityReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{19}
+}
+
+func (m *GetNodeAvailabilityReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetNodeAvailabilityReply.Unmarshal(m, b)
+}
+func (m *GetNodeAvailabilityReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetNodeAvailabilityReply.Marshal(b, m, deterministic)
+}
+func (m *GetNodeAvailabilityReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetNodeAvailabilityReply.Merge(m, src)
+}
+func (m *GetNodeAvailabilityReply) XXX_Size() int {
+	return xxx_messageInfo_GetNodeAvailabilityReply.Size(m)
+}
+func (m *GetNodeAvailabilityReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetNodeAvailabilityReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetNodeAvailabilityReply proto.InternalMessageInfo
+
+func (m *GetNodeAvailabilityReply) GetNode() *Availability {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *GetNodeAvailabilityReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// ===========Shutdown===========
+type DrainNodeRequest struct {
+	NodeID               string   `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DrainNodeRequest) Reset()         { *m = DrainNodeRequest{} }
+func (m *DrainNodeRequest) String() string { return proto.CompactTextString(m) }
+func (*DrainNodeRequest) ProtoMessage()    {}
+func (*DrainNodeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{20}
+}
+
+func (m *DrainNodeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DrainNodeRequest.Unmarshal(m, b)
+}
+func (m *DrainNodeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DrainNodeRequest.Marshal(b, m, deterministic)
+}
+func (m *DrainNodeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DrainNodeRequest.Merge(m, src)
+}
+func (m *DrainNodeRequest) XXX_Size() int {
+	return xxx_messageInfo_DrainNodeRequest.Size(m)
+}
+func (m *DrainNodeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DrainNodeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DrainNodeRequest proto.InternalMessageInfo
+
+func (m *DrainNodeRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
+type DrainNodeReply struct {
+	Err                  string   `protobuf:"bytes,1,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DrainNodeReply) Reset()         { *m = DrainNodeReply{} }
+func (m *DrainNodeReply) String() string { return proto.CompactTextString(m) }
+func (*DrainNodeReply) ProtoMessage()    {}
+func (*DrainNodeReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{21}
+}
+
+func (m *DrainNodeReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DrainNodeReply.Unmarshal(m, b)
+}
+func (m *DrainNodeReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DrainNodeReply.Marshal(b, m, deterministic)
+}
+func (m *DrainNodeReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DrainNodeReply.Merge(m, src)
+}
+func (m *DrainNodeReply) XXX_Size() int {
+	return xxx_messageInfo_DrainNodeReply.Size(m)
+}
+func (m *DrainNodeReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DrainNodeReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DrainNodeReply proto.InternalMessageInfo
+
+func (m *DrainNodeReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type DeregisterNodeRequest struct {
+	NodeID               string   `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	Unfinished           []*Job   `protobuf:"bytes,2,rep,name=unfinished,proto3" json:"unfinished,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeregisterNodeRequest) Reset()         { *m = DeregisterNodeRequest{} }
+func (m *DeregisterNodeRequest) String() string { return proto.CompactTextString(m) }
+func (*DeregisterNodeRequest) ProtoMessage()    {}
+func (*DeregisterNodeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{22}
+}
+
+func (m *DeregisterNodeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeregisterNodeRequest.Unmarshal(m, b)
+}
+func (m *DeregisterNodeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeregisterNodeRequest.Marshal(b, m, deterministic)
+}
+func (m *DeregisterNodeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeregisterNodeRequest.Merge(m, src)
+}
+func (m *DeregisterNodeRequest) XXX_Size() int {
+	return xxx_messageInfo_DeregisterNodeRequest.Size(m)
+}
+func (m *DeregisterNodeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeregisterNodeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeregisterNodeRequest proto.InternalMessageInfo
+
+func (m *DeregisterNodeRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
+func (m *DeregisterNodeRequest) GetUnfinished() []*Job {
+	if m != nil {
+		return m.Unfinished
+	}
+	return nil
+}
+
+type DeregisterNodeReply struct {
+	Rescheduled          int32    `protobuf:"varint,1,opt,name=rescheduled,proto3" json:"rescheduled,omitempty"`
+	Err                  string   `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeregisterNodeReply) Reset()         { *m = DeregisterNodeReply{} }
+func (m *DeregisterNodeReply) String() string { return proto.CompactTextString(m) }
+func (*DeregisterNodeReply) ProtoMessage()    {}
+func (*DeregisterNodeReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{23}
+}
+
+func (m *DeregisterNodeReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeregisterNodeReply.Unmarshal(m, b)
+}
+func (m *DeregisterNodeReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeregisterNodeReply.Marshal(b, m, deterministic)
+}
+func (m *DeregisterNodeReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeregisterNodeReply.Merge(m, src)
+}
+func (m *DeregisterNodeReply) XXX_Size() int {
+	return xxx_messageInfo_DeregisterNodeReply.Size(m)
+}
+func (m *DeregisterNodeReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeregisterNodeReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeregisterNodeReply proto.InternalMessageInfo
+
+func (m *DeregisterNodeReply) GetRescheduled() int32 {
+	if m != nil {
+		return m.Rescheduled
+	}
+	return 0
+}
+
+func (m *DeregisterNodeReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// ===========DeadLetters===========
+type DeadLetter struct {
+	ID                   string               `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Subject              string               `protobuf:"bytes,2,opt,name=subject,proto3" json:"subject,omitempty"`
+	Stage                string               `protobuf:"bytes,3,opt,name=stage,proto3" json:"stage,omitempty"`
+	Err                  string               `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
+	Payload              []byte               `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	Time                 *timestamp.Timestamp `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	Replica              string               `protobuf:"bytes,7,opt,name=replica,proto3" json:"replica,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *DeadLetter) Reset()         { *m = DeadLetter{} }
+func (m *DeadLetter) String() string { return proto.CompactTextString(m) }
+func (*DeadLetter) ProtoMessage()    {}
+func (*DeadLetter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{24}
+}
+
+func (m *DeadLetter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeadLetter.Unmarshal(m, b)
+}
+func (m *DeadLetter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeadLetter.Marshal(b, m, deterministic)
+}
+func (m *DeadLetter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeadLetter.Merge(m, src)
+}
+func (m *DeadLetter) XXX_Size() int {
+	return xxx_messageInfo_DeadLetter.Size(m)
+}
+func (m *DeadLetter) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeadLetter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeadLetter proto.InternalMessageInfo
+
+func (m *DeadLetter) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *DeadLetter) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *DeadLetter) GetStage() string {
+	if m != nil {
+		return m.Stage
+	}
+	return ""
+}
+
+func (m *DeadLetter) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+func (m *DeadLetter) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *DeadLetter) GetTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *DeadLetter) GetReplica() string {
+	if m != nil {
+		return m.Replica
+	}
+	return ""
+}
+
+type ListDeadLettersRequest struct {
+	Limit                int32    `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListDeadLettersRequest) Reset()         { *m = ListDeadLettersRequest{} }
+func (m *ListDeadLettersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListDeadLettersRequest) ProtoMessage()    {}
+func (*ListDeadLettersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{25}
+}
+
+func (m *ListDeadLettersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListDeadLettersRequest.Unmarshal(m, b)
+}
+func (m *ListDeadLettersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListDeadLettersRequest.Marshal(b, m, deterministic)
+}
+func (m *ListDeadLettersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListDeadLettersRequest.Merge(m, src)
+}
+func (m *ListDeadLettersRequest) XXX_Size() int {
+	return xxx_messageInfo_ListDeadLettersRequest.Size(m)
+}
+func (m *ListDeadLettersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListDeadLettersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListDeadLettersRequest proto.InternalMessageInfo
+
+func (m *ListDeadLettersRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type ListDeadLettersReply struct {
+	Letters              []*DeadLetter `protobuf:"bytes,1,rep,name=letters,proto3" json:"letters,omitempty"`
+	Err                  string        `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ListDeadLettersReply) Reset()         { *m = ListDeadLettersReply{} }
+func (m *ListDeadLettersReply) String() string { return proto.CompactTextString(m) }
+func (*ListDeadLettersReply) ProtoMessage()    {}
+func (*ListDeadLettersReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{26}
+}
+
+func (m *ListDeadLettersReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListDeadLettersReply.Unmarshal(m, b)
+}
+func (m *ListDeadLettersReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListDeadLettersReply.Marshal(b, m, deterministic)
+}
+func (m *ListDeadLettersReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListDeadLettersReply.Merge(m, src)
+}
+func (m *ListDeadLettersReply) XXX_Size() int {
+	return xxx_messageInfo_ListDeadLettersReply.Size(m)
+}
+func (m *ListDeadLettersReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListDeadLettersReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListDeadLettersReply proto.InternalMessageInfo
+
+func (m *ListDeadLettersReply) GetLetters() []*DeadLetter {
+	if m != nil {
+		return m.Letters
+	}
+	return nil
+}
+
+func (m *ListDeadLettersReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type ReplayDeadLetterRequest struct {
+	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReplayDeadLetterRequest) Reset()         { *m = ReplayDeadLetterRequest{} }
+func (m *ReplayDeadLetterRequest) String() string { return proto.CompactTextString(m) }
+func (*ReplayDeadLetterRequest) ProtoMessage()    {}
+func (*ReplayDeadLetterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{27}
+}
+
+func (m *ReplayDeadLetterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplayDeadLetterRequest.Unmarshal(m, b)
+}
+func (m *ReplayDeadLetterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplayDeadLetterRequest.Marshal(b, m, deterministic)
+}
+func (m *ReplayDeadLetterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplayDeadLetterRequest.Merge(m, src)
+}
+func (m *ReplayDeadLetterRequest) XXX_Size() int {
+	return xxx_messageInfo_ReplayDeadLetterRequest.Size(m)
+}
+func (m *ReplayDeadLetterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplayDeadLetterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplayDeadLetterRequest proto.InternalMessageInfo
+
+func (m *ReplayDeadLetterRequest) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+type ReplayDeadLetterReply struct {
+	Err                  string   `protobuf:"bytes,1,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReplayDeadLetterReply) Reset()         { *m = ReplayDeadLetterReply{} }
+func (m *ReplayDeadLetterReply) String() string { return proto.CompactTextString(m) }
+func (*ReplayDeadLetterReply) ProtoMessage()    {}
+func (*ReplayDeadLetterReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{28}
+}
+
+func (m *ReplayDeadLetterReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReplayDeadLetterReply.Unmarshal(m, b)
+}
+func (m *ReplayDeadLetterReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReplayDeadLetterReply.Marshal(b, m, deterministic)
+}
+func (m *ReplayDeadLetterReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplayDeadLetterReply.Merge(m, src)
+}
+func (m *ReplayDeadLetterReply) XXX_Size() int {
+	return xxx_messageInfo_ReplayDeadLetterReply.Size(m)
+}
+func (m *ReplayDeadLetterReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplayDeadLetterReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplayDeadLetterReply proto.InternalMessageInfo
+
+func (m *ReplayDeadLetterReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// ===========NATS===========
+// TracedMessage carries a request over NATS, which has no message headers,
+// with the span context of its sender in the TextMap format of the tracer.
+// On the wire it is preceded by a zero byte, which no protobuf message
+// starts with, so plain requests are told apart from traced ones.
+type TracedMessage struct {
+	Trace                map[string]string `protobuf:"bytes,1,rep,name=trace,proto3" json:"trace,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Payload              []byte            `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TracedMessage) Reset()         { *m = TracedMessage{} }
+func (m *TracedMessage) String() string { return proto.CompactTextString(m) }
+func (*TracedMessage) ProtoMessage()    {}
+func (*TracedMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a6377fc15c39a05, []int{29}
+}
+
+func (m *TracedMessage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TracedMessage.Unmarshal(m, b)
+}
+func (m *TracedMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TracedMessage.Marshal(b, m, deterministic)
+}
+func (m *TracedMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TracedMessage.Merge(m, src)
+}
+func (m *TracedMessage) XXX_Size() int {
+	return xxx_messageInfo_TracedMessage.Size(m)
+}
+func (m *TracedMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_TracedMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TracedMessage proto.InternalMessageInfo
+
+func (m *TracedMessage) GetTrace() map[string]string {
+	if m != nil {
+		return m.Trace
+	}
+	return nil
+}
+
+func (m *TracedMessage) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*RegisterNodeRequest)(nil), "pb.repo.RegisterNodeRequest")
+	proto.RegisterType((*RegisterNodeReply)(nil), "pb.repo.RegisterNodeReply")
+	proto.RegisterType((*GetAllNodesRequest)(nil), "pb.repo.GetAllNodesRequest")
+	proto.RegisterType((*GetAllNodesReply)(nil), "pb.repo.GetAllNodesReply")
+	proto.RegisterType((*Node)(nil), "pb.repo.Node")
+	proto.RegisterType((*Job)(nil), "pb.repo.Job")
+	proto.RegisterType((*NewJobRequest)(nil), "pb.repo.NewJobRequest")
+	proto.RegisterType((*NewJobReply)(nil), "pb.repo.NewJobReply")
+	proto.RegisterType((*ExportRequest)(nil), "pb.repo.ExportRequest")
+	proto.RegisterType((*ExportReply)(nil), "pb.repo.ExportReply")
+	proto.RegisterType((*ImportRequest)(nil), "pb.repo.ImportRequest")
+	proto.RegisterType((*ImportReply)(nil), "pb.repo.ImportReply")
+	proto.RegisterType((*HeartbeatRequest)(nil), "pb.repo.HeartbeatRequest")
+	proto.RegisterType((*HeartbeatReply)(nil), "pb.repo.HeartbeatReply")
+	proto.RegisterType((*Incident)(nil), "pb.repo.Incident")
+	proto.RegisterType((*Availability)(nil), "pb.repo.Availability")
+	proto.RegisterType((*GetAvailabilityRequest)(nil), "pb.repo.GetAvailabilityRequest")
+	proto.RegisterType((*GetAvailabilityReply)(nil), "pb.repo.GetAvailabilityReply")
+	proto.RegisterType((*GetNodeAvailabilityRequest)(nil), "pb.repo.GetNodeAvailabilityRequest")
+	proto.RegisterType((*GetNodeAvailabilityReply)(nil), "pb.repo.GetNodeAvailabilityReply")
+	proto.RegisterType((*DrainNodeRequest)(nil), "pb.repo.DrainNodeRequest")
+	proto.RegisterType((*DrainNodeReply)(nil), "pb.repo.DrainNodeReply")
+	proto.RegisterType((*DeregisterNodeRequest)(nil), "pb.repo.DeregisterNodeRequest")
+	proto.RegisterType((*DeregisterNodeReply)(nil), "pb.repo.DeregisterNodeReply")
+	proto.RegisterType((*DeadLetter)(nil), "pb.repo.DeadLetter")
+	proto.RegisterType((*ListDeadLettersRequest)(nil), "pb.repo.ListDeadLettersRequest")
+	proto.RegisterType((*ListDeadLettersReply)(nil), "pb.repo.ListDeadLettersReply")
+	proto.RegisterType((*ReplayDeadLetterRequest)(nil), "pb.repo.ReplayDeadLetterRequest")
+	proto.RegisterType((*ReplayDeadLetterReply)(nil), "pb.repo.ReplayDeadLetterReply")
+	proto.RegisterType((*TracedMessage)(nil), "pb.repo.TracedMessage")
+	proto.RegisterMapType((map[string]string)(nil), "pb.repo.TracedMessage.TraceEntry")
+}
+
+func init() { proto.RegisterFile("repo.proto", fileDescriptor_9a6377fc15c39a05) }
+
+var fileDescriptor_9a6377fc15c39a05 = []byte{
+	// 1284 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xcf, 0x6e, 0xdb, 0x46,
+	0x13, 0x0f, 0x29, 0x4a, 0xb2, 0x46, 0x72, 0xa2, 0xac, 0x15, 0x87, 0x1f, 0x3f, 0xc7, 0x51, 0xe8,
+	0x8b, 0xdd, 0xa6, 0x72, 0xe1, 0x14, 0xad, 0xe1, 0x9b, 0x11, 0x05, 0xae, 0x8c, 0x34, 0x30, 0x98,
+	0xb4, 0x39, 0x05, 0x05, 0x25, 0x8e, 0x65, 0xc6, 0x14, 0xc9, 0x90, 0x4b, 0xa7, 0xba, 0x14, 0x3d,
+	0xf4, 0x31, 0xfa, 0x1c, 0x05, 0x7a, 0xed, 0x8b, 0xf4, 0x55, 0x8a, 0xdd, 0xe5, 0x9f, 0x95, 0x48,
+	0xda, 0xbd, 0xed, 0xcc, 0xfe, 0x66, 0xf6, 0xb7, 0xb3, 0x33, 0xb3, 0x03, 0x10, 0x61, 0x18, 0x8c,
+	0xc2, 0x28, 0xa0, 0x01, 0x69, 0x87, 0xd3, 0x11, 0x13, 0x8d, 0xa7, 0xf3, 0x20, 0x98, 0x7b, 0x78,
+	0xc8, 0xd5, 0xd3, 0xe4, 0xf2, 0x90, 0xba, 0x0b, 0x8c, 0xa9, 0xbd, 0x08, 0x05, 0xd2, 0x4c, 0x60,
+	0xcb, 0xc2, 0xb9, 0x1b, 0x53, 0x8c, 0xde, 0x04, 0x0e, 0x5a, 0xf8, 0x29, 0xc1, 0x98, 0x12, 0x02,
+	0x9a, 0x6f, 0x2f, 0x50, 0x57, 0x86, 0xca, 0x7e, 0xc7, 0xe2, 0x6b, 0xb2, 0x0d, 0x2d, 0x3f, 0x70,
+	0x70, 0x72, 0xa1, 0xab, 0x5c, 0x9b, 0x4a, 0xc4, 0x80, 0x0d, 0xb6, 0xba, 0x08, 0x22, 0xaa, 0x37,
+	0xf8, 0x4e, 0x2e, 0xe7, 0x36, 0x63, 0x5d, 0x93, 0x6c, 0xc6, 0xe6, 0x07, 0x78, 0xb8, 0x7a, 0x6c,
+	0xe8, 0x2d, 0x25, 0xb0, 0x22, 0x83, 0x49, 0x1f, 0x1a, 0x18, 0x45, 0xe9, 0xa9, 0x6c, 0x49, 0x76,
+	0x01, 0xe6, 0xe8, 0x63, 0x64, 0x53, 0x37, 0xf0, 0xf9, 0xa1, 0x9a, 0x25, 0x69, 0xcc, 0x01, 0x90,
+	0x33, 0xa4, 0xa7, 0x9e, 0xc7, 0x9c, 0xc7, 0xe9, 0xa5, 0xcc, 0x09, 0xf4, 0x57, 0xb4, 0xec, 0xcc,
+	0x3d, 0x68, 0xb2, 0x53, 0x62, 0x5d, 0x19, 0x36, 0xf6, 0xbb, 0x47, 0x9b, 0xa3, 0x34, 0x72, 0x23,
+	0x4e, 0x4b, 0xec, 0x95, 0x09, 0x98, 0xbf, 0xa9, 0xa0, 0x31, 0x04, 0xb9, 0x0f, 0x6a, 0xce, 0x57,
+	0x9d, 0x8c, 0xf3, 0xc0, 0xa9, 0x52, 0xe0, 0x18, 0xe6, 0x22, 0x0d, 0x8d, 0x3a, 0xb9, 0x60, 0x98,
+	0x90, 0x05, 0x4b, 0x84, 0x84, 0xaf, 0xc9, 0x0e, 0x74, 0x3e, 0x06, 0xd3, 0xf8, 0x65, 0x90, 0xf8,
+	0x54, 0x6f, 0x0e, 0x95, 0xfd, 0xa6, 0x55, 0x28, 0xc8, 0x10, 0x34, 0x26, 0xe8, 0x2d, 0x4e, 0xb2,
+	0x97, 0x93, 0x3c, 0x0f, 0xa6, 0x16, 0xdf, 0x21, 0x03, 0x68, 0xc6, 0xd4, 0xa6, 0xa8, 0xb7, 0xb9,
+	0x53, 0x21, 0x90, 0x13, 0x00, 0xbe, 0x78, 0xeb, 0xfa, 0x33, 0xd4, 0x37, 0x86, 0xca, 0x7e, 0xf7,
+	0xc8, 0x18, 0x89, 0x9c, 0x18, 0x65, 0x39, 0x31, 0x7a, 0x97, 0xe5, 0x84, 0x25, 0xa1, 0xd7, 0x62,
+	0xdc, 0x29, 0xc5, 0xf8, 0x2f, 0x05, 0x1a, 0xe7, 0xc1, 0xb4, 0x14, 0x81, 0x3e, 0x34, 0x42, 0x14,
+	0xc1, 0x52, 0x2d, 0xb6, 0x64, 0x09, 0xe2, 0x24, 0xd2, 0x5b, 0xa9, 0x56, 0x2e, 0x93, 0x63, 0xe8,
+	0xc4, 0xd4, 0x8e, 0x28, 0xe3, 0xa0, 0x6b, 0x77, 0x12, 0x2c, 0xc0, 0xec, 0x6e, 0x97, 0xae, 0xef,
+	0xc6, 0x57, 0xdc, 0xb4, 0x79, 0xf7, 0xdd, 0x0a, 0xb4, 0xf9, 0x00, 0x36, 0xdf, 0xe0, 0x67, 0x16,
+	0xbd, 0x34, 0x35, 0x0e, 0xa1, 0x9b, 0x29, 0x58, 0x56, 0x54, 0xdc, 0x69, 0x2d, 0x01, 0x1e, 0xc0,
+	0xe6, 0xab, 0x5f, 0xd8, 0xcb, 0x65, 0x1e, 0x5e, 0x40, 0x37, 0x53, 0x30, 0x0f, 0x04, 0x34, 0xc7,
+	0xa6, 0x36, 0xf7, 0xd1, 0xb3, 0xf8, 0xba, 0xc2, 0xcb, 0x1e, 0x6c, 0x4e, 0x16, 0x92, 0x97, 0x2a,
+	0x33, 0x73, 0x09, 0xdd, 0xc9, 0xa2, 0xf0, 0x3c, 0x28, 0x32, 0x96, 0x65, 0x89, 0x10, 0x98, 0x21,
+	0xcf, 0x10, 0x95, 0x2b, 0xf9, 0x9a, 0xd5, 0x13, 0xde, 0xa0, 0x4f, 0x63, 0x1e, 0xf5, 0xa6, 0x95,
+	0x4a, 0x19, 0x0f, 0xad, 0xa8, 0xa7, 0x6d, 0x68, 0x7d, 0x4a, 0x30, 0x41, 0x27, 0x4d, 0xbd, 0x54,
+	0x32, 0x7f, 0x85, 0xfe, 0xf7, 0x68, 0x47, 0x74, 0x8a, 0x76, 0x4e, 0xb1, 0xae, 0x4a, 0x57, 0x32,
+	0x58, 0xad, 0xcb, 0xe0, 0x46, 0x6d, 0x06, 0x13, 0xd0, 0x2e, 0x13, 0xcf, 0xe3, 0xb4, 0x36, 0x2c,
+	0xbe, 0x36, 0x4d, 0xb8, 0x2f, 0x9d, 0xcf, 0x6e, 0x9f, 0x72, 0x57, 0x8a, 0x18, 0xfe, 0xa9, 0xc0,
+	0xc6, 0xc4, 0x9f, 0xb9, 0x0e, 0xfa, 0x94, 0x7c, 0xcd, 0xcb, 0x20, 0xa2, 0xba, 0x72, 0x67, 0x3e,
+	0x08, 0x20, 0x79, 0x0e, 0x0d, 0xf4, 0x1d, 0x5d, 0xbd, 0x13, 0xcf, 0x60, 0x45, 0x99, 0x35, 0xe4,
+	0x32, 0x33, 0xa1, 0x77, 0x69, 0xbb, 0x1e, 0x3a, 0x2f, 0xaf, 0x70, 0x76, 0x1d, 0xf3, 0x2b, 0x34,
+	0xad, 0x15, 0x5d, 0x46, 0xbc, 0x59, 0x10, 0xff, 0x47, 0x85, 0xde, 0xe9, 0x8d, 0xed, 0x7a, 0xf6,
+	0xd4, 0xf5, 0x5c, 0x5a, 0xdf, 0xff, 0xaa, 0x7a, 0x4a, 0x35, 0x91, 0x13, 0xf6, 0x0b, 0x88, 0xb6,
+	0x8a, 0xce, 0x7f, 0x28, 0x27, 0x09, 0x4d, 0xbe, 0x81, 0xb6, 0x83, 0x1e, 0xd2, 0x34, 0x09, 0x6e,
+	0x37, 0xcc, 0xa0, 0x8c, 0x73, 0x12, 0xb2, 0x4f, 0x45, 0x6f, 0x0d, 0x95, 0x7d, 0xc5, 0x4a, 0x25,
+	0x56, 0x41, 0x49, 0xc8, 0x9b, 0x91, 0x62, 0xa9, 0x49, 0xc8, 0x13, 0x3b, 0xf8, 0xec, 0xf3, 0x1e,
+	0xa4, 0x58, 0x7c, 0xcd, 0xfa, 0x02, 0x0b, 0x51, 0x12, 0x61, 0xcc, 0xfb, 0x4b, 0xd3, 0xca, 0x65,
+	0x86, 0x5f, 0xd0, 0xe9, 0xa5, 0x0e, 0x02, 0xcf, 0xd6, 0xe4, 0x10, 0x3a, 0x6e, 0xfa, 0xd0, 0xb1,
+	0xde, 0xe5, 0x89, 0xf4, 0x30, 0x4f, 0xa4, 0x2c, 0x05, 0xac, 0x02, 0x63, 0x7e, 0x0b, 0xdb, 0xac,
+	0xe1, 0x4b, 0x31, 0xce, 0x92, 0x78, 0x47, 0x76, 0x25, 0x0a, 0x49, 0xb2, 0xfb, 0x11, 0x06, 0x25,
+	0x3b, 0x96, 0x7c, 0x5f, 0xae, 0x7e, 0x16, 0x8f, 0xf2, 0xc3, 0x57, 0xa0, 0xb5, 0x9f, 0x86, 0x05,
+	0xc6, 0x19, 0x52, 0xf6, 0x6d, 0x54, 0x51, 0xba, 0xa5, 0xae, 0x0a, 0xaa, 0xea, 0x3a, 0xd5, 0xf7,
+	0xa0, 0x57, 0xfa, 0x64, 0x74, 0x0f, 0x40, 0x63, 0x3e, 0xd2, 0x5a, 0xa8, 0x61, 0xcb, 0x21, 0x15,
+	0x64, 0xbf, 0x80, 0xfe, 0x38, 0xb2, 0x5d, 0x5f, 0x9e, 0x0a, 0x6a, 0x28, 0xb2, 0x32, 0x95, 0xb0,
+	0xd5, 0x65, 0xfa, 0x01, 0x1e, 0x8d, 0x31, 0xaa, 0x18, 0x35, 0xea, 0xee, 0xfd, 0x1c, 0x20, 0xf1,
+	0x45, 0xcf, 0x46, 0x56, 0x9f, 0xe5, 0xbe, 0x21, 0xed, 0x9b, 0x13, 0xd8, 0x5a, 0x77, 0xcf, 0x78,
+	0x0c, 0xa1, 0x1b, 0x61, 0x3c, 0xbb, 0x42, 0x27, 0xf1, 0xd0, 0x49, 0x5f, 0x5a, 0x56, 0x55, 0xdc,
+	0xfc, 0x6f, 0x05, 0x60, 0x8c, 0xb6, 0xf3, 0x1a, 0x29, 0xc5, 0xa8, 0xf4, 0x17, 0xe8, 0xd0, 0x8e,
+	0x93, 0xe9, 0x47, 0x9c, 0xd1, 0xd4, 0x28, 0x13, 0xd3, 0x9a, 0x9c, 0xcb, 0x35, 0x39, 0xc7, 0x8a,
+	0x6e, 0xab, 0x43, 0x3b, 0xb4, 0x97, 0x5e, 0x60, 0x8b, 0x4a, 0xeb, 0x59, 0x99, 0x48, 0x46, 0xa0,
+	0xe5, 0xb5, 0x74, 0x7b, 0x01, 0x72, 0x1c, 0xf3, 0x14, 0x61, 0xe8, 0xb9, 0x33, 0x3b, 0xfd, 0xf7,
+	0x33, 0xd1, 0x1c, 0xc1, 0xf6, 0x6b, 0x37, 0xa6, 0xc5, 0x3d, 0xb2, 0x29, 0x88, 0xb1, 0xf4, 0xdc,
+	0x85, 0x4b, 0xb3, 0xff, 0x83, 0x0b, 0xe6, 0x7b, 0x18, 0x94, 0xf0, 0x2c, 0x80, 0x5f, 0x41, 0xdb,
+	0x13, 0x72, 0x9a, 0xf4, 0x5b, 0xf9, 0x13, 0x14, 0x58, 0x2b, 0xc3, 0x54, 0x44, 0xf3, 0x00, 0x1e,
+	0x33, 0x4f, 0xf6, 0x52, 0x82, 0xa7, 0x4c, 0xd6, 0x22, 0x6b, 0x1e, 0xc0, 0xa3, 0x32, 0xb4, 0x3a,
+	0x9b, 0xfe, 0x50, 0x60, 0xf3, 0x5d, 0x64, 0xcf, 0xd0, 0xf9, 0x01, 0xe3, 0x98, 0x85, 0xf9, 0x3b,
+	0x68, 0x52, 0xa6, 0x48, 0x69, 0x3e, 0xcb, 0x69, 0xae, 0xc0, 0x84, 0xf4, 0xca, 0xa7, 0xd1, 0xd2,
+	0x12, 0x78, 0xf9, 0x35, 0xd4, 0x95, 0xd7, 0x30, 0x8e, 0x01, 0x0a, 0x38, 0x23, 0x71, 0x8d, 0xcb,
+	0x8c, 0xc4, 0x35, 0xf2, 0x9f, 0xf8, 0xc6, 0xf6, 0x92, 0xac, 0x31, 0x0b, 0xe1, 0x44, 0x3d, 0x56,
+	0x8e, 0x7e, 0x6f, 0x83, 0x66, 0x61, 0x18, 0x90, 0x73, 0xe8, 0xc9, 0x73, 0x2e, 0xd9, 0xc9, 0x69,
+	0x55, 0x4c, 0xdd, 0x86, 0x51, 0xb3, 0x1b, 0x7a, 0x4b, 0xf3, 0x1e, 0x39, 0x83, 0xae, 0x34, 0xbe,
+	0x92, 0xff, 0xe7, 0xe0, 0xf2, 0xa8, 0x6b, 0xfc, 0xaf, 0x7a, 0x53, 0x38, 0x3a, 0x86, 0x96, 0x18,
+	0x76, 0xc8, 0x76, 0x31, 0xee, 0xca, 0xe3, 0x90, 0x31, 0x28, 0xe9, 0x73, 0x4b, 0x31, 0xe4, 0x48,
+	0x96, 0x2b, 0x63, 0x90, 0x31, 0x28, 0xe9, 0x73, 0xcb, 0xc9, 0x62, 0xcd, 0x72, 0xb2, 0xa8, 0xb6,
+	0x94, 0xa6, 0x1d, 0xf3, 0x1e, 0x39, 0x85, 0x4e, 0x3e, 0x03, 0x90, 0xe2, 0x5e, 0xeb, 0x73, 0x89,
+	0xf1, 0xb8, 0x6a, 0x4b, 0xb8, 0x78, 0x0b, 0x0f, 0xd6, 0xfa, 0x39, 0x79, 0xba, 0x12, 0xa0, 0x72,
+	0x3b, 0x36, 0x9e, 0xd4, 0x03, 0x84, 0xd3, 0x9f, 0x61, 0xab, 0xa2, 0xf3, 0x92, 0x3d, 0xd9, 0xae,
+	0xa6, 0xd7, 0x1b, 0xcf, 0x6e, 0x07, 0xe5, 0x17, 0xcf, 0xbb, 0xaa, 0x74, 0xf1, 0xf5, 0xae, 0x6c,
+	0x3c, 0xae, 0xda, 0x12, 0x2e, 0x2e, 0xe0, 0xfe, 0x6a, 0x57, 0x24, 0xbb, 0x05, 0xb8, 0xaa, 0x1b,
+	0x1b, 0x3b, 0xb5, 0xfb, 0x79, 0x28, 0xd7, 0xfa, 0x84, 0x14, 0xca, 0xea, 0x8e, 0x63, 0x3c, 0xa9,
+	0x07, 0x08, 0xa7, 0x3f, 0x41, 0x7f, 0xbd, 0xf0, 0xc9, 0x50, 0xaa, 0x85, 0xca, 0xf6, 0x61, 0xec,
+	0xde, 0x82, 0xe0, 0x7e, 0xa7, 0x2d, 0xde, 0x38, 0x5f, 0xfc, 0x3b, 0x00, 0xad, 0xf7, 0x24, 0x40,
+	0x1b, 0x0f, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RepoClient is the client API for Repo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RepoClient interface {
+	// Register new node
+	RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeReply, error)
+	// GetAllNodes returns all notes with jobs that registared in the Repository
+	GetAllNodes(ctx context.Context, in *GetAllNodesRequest, opts ...grpc.CallOption) (*GetAllNodesReply, error)
+	// NewJob create a new job on a free node. Returns a ID of a new created job
+	NewJob(ctx context.Context, in *NewJobRequest, opts ...grpc.CallOption) (*NewJobReply, error)
+	// Export dumps nodes, jobs and history of jobs in the backup format
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ExportReply, error)
+	// Import restores nodes, jobs and history of jobs from a dump made by Export
+	Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error)
+	// Heartbeat reports the running jobs of a node, workers usually send it over NATS
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatReply, error)
+	// GetAvailability returns uptime, MTBF and the last incidents of all nodes
+	GetAvailability(ctx context.Context, in *GetAvailabilityRequest, opts ...grpc.CallOption) (*GetAvailabilityReply, error)
+	// GetNodeAvailability returns uptime, MTBF and the last incidents of a node
+	GetNodeAvailability(ctx context.Context, in *GetNodeAvailabilityRequest, opts ...grpc.CallOption) (*GetNodeAvailabilityReply, error)
+	// DrainNode stops scheduling jobs on a node which is shutting down
+	DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (*DrainNodeReply, error)
+	// DeregisterNode deletes a node which shuts down and reschedules its unfinished jobs
+	DeregisterNode(ctx context.Context, in *DeregisterNodeRequest, opts ...grpc.CallOption) (*DeregisterNodeReply, error)
+	// ListDeadLetters returns NATS messages which failed, the newest first
+	ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*ListDeadLettersReply, error)
+	// ReplayDeadLetter publishes the message of a dead letter again on its subject
+	ReplayDeadLetter(ctx context.Context, in *ReplayDeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLetterReply, error)
+}
+
+type repoClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRepoClient(cc *grpc.ClientConn) RepoClient {
+	return &repoClient{cc}
+}
+
+func (c *repoClient) RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeReply, error) {
+	out := new(RegisterNodeReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/RegisterNode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) GetAllNodes(ctx context.Context, in *GetAllNodesRequest, opts ...grpc.CallOption) (*GetAllNodesReply, error) {
+	out := new(GetAllNodesReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/GetAllNodes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) NewJob(ctx context.Context, in *NewJobRequest, opts ...grpc.CallOption) (*NewJobReply, error) {
+	out := new(NewJobReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/NewJob", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ExportReply, error) {
+	out := new(ExportReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/Export", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error) {
+	out := new(ImportReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/Import", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatReply, error) {
+	out := new(HeartbeatReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/Heartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) GetAvailability(ctx context.Context, in *GetAvailabilityRequest, opts ...grpc.CallOption) (*GetAvailabilityReply, error) {
+	out := new(GetAvailabilityReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/GetAvailability", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) GetNodeAvailability(ctx context.Context, in *GetNodeAvailabilityRequest, opts ...grpc.CallOption) (*GetNodeAvailabilityReply, error) {
+	out := new(GetNodeAvailabilityReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/GetNodeAvailability", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (*DrainNodeReply, error) {
+	out := new(DrainNodeReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/DrainNode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) DeregisterNode(ctx context.Context, in *DeregisterNodeRequest, opts ...grpc.CallOption) (*DeregisterNodeReply, error) {
+	out := new(DeregisterNodeReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/DeregisterNode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*ListDeadLettersReply, error) {
+	out := new(ListDeadLettersReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/ListDeadLetters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *repoClient) ReplayDeadLetter(ctx context.Context, in *ReplayDeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLetterReply, error) {
+	out := new(ReplayDeadLetterReply)
+	err := c.cc.Invoke(ctx, "/pb.repo.Repo/ReplayDeadLetter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RepoServer is the server API for Repo service.
+type RepoServer interface {
+	// Register new node
+	RegisterNode(context.Context, *RegisterNodeRequest) (*RegisterNodeReply, error)
+	// GetAllNodes returns all notes with jobs that registared in the Repository
+	GetAllNodes(context.Context, *GetAllNodesRequest) (*GetAllNodesReply, error)
+	// NewJob create a new job on a free node. Returns a ID of a new created job
+	NewJob(context.Context, *NewJobRequest) (*NewJobReply, error)
+	// Export dumps nodes, jobs and history of jobs in the backup format
+	Export(context.Context, *ExportRequest) (*ExportReply, error)
+	// Import restores nodes, jobs and history of jobs from a dump made by Export
+	Import(context.Context, *ImportRequest) (*ImportReply, error)
+	// Heartbeat reports the running jobs of a node, workers usually send it over NATS
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatReply, error)
+	// GetAvailability returns uptime, MTBF and the last incidents of all nodes
+	GetAvailability(context.Context, *GetAvailabilityRequest) (*GetAvailabilityReply, error)
+	// GetNodeAvailability returns uptime, MTBF and the last incidents of a node
+	GetNodeAvailability(context.Context, *GetNodeAvailabilityRequest) (*GetNodeAvailabilityReply, error)
+	// DrainNode stops scheduling jobs on a node which is shutting down
+	DrainNode(context.Context, *DrainNodeRequest) (*DrainNodeReply, error)
+	// DeregisterNode deletes a node which shuts down and reschedules its unfinished jobs
+	DeregisterNode(context.Context, *DeregisterNodeRequest) (*DeregisterNodeReply, error)
+	// ListDeadLetters returns NATS messages which failed, the newest first
+	ListDeadLetters(context.Context, *ListDeadLettersRequest) (*ListDeadLettersReply, error)
+	// ReplayDeadLetter publishes the message of a dead letter again on its subject
+	ReplayDeadLetter(context.Context, *ReplayDeadLetterRequest) (*ReplayDeadLetterReply, error)
+}
+
+func RegisterRepoServer(s *grpc.Server, srv RepoServer) {
+	s.RegisterService(&_Repo_serviceDesc, srv)
+}
+
+func _Repo_RegisterNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).RegisterNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/RegisterNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).RegisterNode(ctx, req.(*RegisterNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_GetAllNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).GetAllNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/GetAllNodes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).GetAllNodes(ctx, req.(*GetAllNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_NewJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).NewJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/NewJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).NewJob(ctx, req.(*NewJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_Export_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).Export(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).Export(ctx, req.(*ExportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_Import_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).Import(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/Import",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).Import(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/Heartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_GetAvailability_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAvailabilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).GetAvailability(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/GetAvailability",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).GetAvailability(ctx, req.(*GetAvailabilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_GetNodeAvailability_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNodeAvailabilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).GetNodeAvailability(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/GetNodeAvailability",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).GetNodeAvailability(ctx, req.(*GetNodeAvailabilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_DrainNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).DrainNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/DrainNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).DrainNode(ctx, req.(*DrainNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_DeregisterNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).DeregisterNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/DeregisterNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).DeregisterNode(ctx, req.(*DeregisterNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/ListDeadLetters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).ListDeadLetters(ctx, req.(*ListDeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Repo_ReplayDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplayDeadLetterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RepoServer).ReplayDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.repo.Repo/ReplayDeadLetter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RepoServer).ReplayDeadLetter(ctx, req.(*ReplayDeadLetterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Repo_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.repo.Repo",
+	HandlerType: (*RepoServer)(nil),
+	Methods: []grpc.MethodDesc{
 		{
 			MethodName: "RegisterNode",
 			Handler:    _Repo_RegisterNode_Handler,
@@ -620,6 +2154,42 @@ var _Repo_serviceDesc = grpc.ServiceDesc{
 			MethodName: "NewJob",
 			Handler:    _Repo_NewJob_Handler,
 		},
+		{
+			MethodName: "Export",
+			Handler:    _Repo_Export_Handler,
+		},
+		{
+			MethodName: "Import",
+			Handler:    _Repo_Import_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _Repo_Heartbeat_Handler,
+		},
+		{
+			MethodName: "GetAvailability",
+			Handler:    _Repo_GetAvailability_Handler,
+		},
+		{
+			MethodName: "GetNodeAvailability",
+			Handler:    _Repo_GetNodeAvailability_Handler,
+		},
+		{
+			MethodName: "DrainNode",
+			Handler:    _Repo_DrainNode_Handler,
+		},
+		{
+			MethodName: "DeregisterNode",
+			Handler:    _Repo_DeregisterNode_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _Repo_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetter",
+			Handler:    _Repo_ReplayDeadLetter_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "repo.proto",
@@ -0,0 +1,348 @@
+// Package availability keeps a history of node health and derives how
+// available every node was: the share of time it was healthy, the mean time
+// between failures and its last incidents.
+//
+// A node is up while it is Healthy, or Draining before a planned shutdown. A
+// failure is a move from up to any other state, the incident it opens lasts
+// until the node is up again or is deleted.
+package availability
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+)
+
+// DefaultMaxIncidents is how many incidents are kept for every node.
+const DefaultMaxIncidents = 100
+
+// DefaultRetention is how long history of deleted nodes is kept.
+const DefaultRetention = 24 * time.Hour
+
+// Incident is a period when a node was not healthy.
+type Incident struct {
+	Start time.Time `json:"start"`
+	// End is zero while the incident lasts.
+	End time.Time `json:"end,omitempty"`
+	// State is the worst state the node reached during the incident.
+	State repo.NodeState `json:"state"`
+	// FailedChecks counts failed health checks during the incident,
+	// Err is the error of the first one.
+	FailedChecks int    `json:"failedChecks"`
+	Err          string `json:"err,omitempty"`
+}
+
+// Duration of the incident until now if it still lasts.
+func (i Incident) Duration(now time.Time) time.Duration {
+	if i.End.IsZero() {
+		return now.Sub(i.Start)
+	}
+	return i.End.Sub(i.Start)
+}
+
+// Report describes availability of a node since its registration.
+type Report struct {
+	NodeID     repo.NodeID    `json:"node"`
+	Name       string         `json:"name"`
+	State      repo.NodeState `json:"state"`
+	Registered time.Time      `json:"registered"`
+	// Deleted is zero for nodes which are still in the repository.
+	Deleted time.Time `json:"deleted,omitempty"`
+	// Uptime is a percentage of time the node was healthy.
+	Uptime   float64       `json:"uptime"`
+	Up       time.Duration `json:"up"`
+	Down     time.Duration `json:"down"`
+	Failures int           `json:"failures"`
+	// MTBF is the mean time between failures, the healthy time divided by the
+	// number of failures. It is zero for nodes which never failed.
+	MTBF time.Duration `json:"mtbf"`
+	// Incidents are the last incidents, the latest first.
+	Incidents []Incident `json:"incidents"`
+}
+
+type node struct {
+	name       string
+	registered time.Time
+	deleted    time.Time
+	state      repo.NodeState
+	since      time.Time     // of the current state
+	up, down   time.Duration // before since
+	failures   int
+	incidents  []Incident // the latest last
+}
+
+func (n *node) open() *Incident {
+	if len(n.incidents) == 0 {
+		return nil
+	}
+	i := &n.incidents[len(n.incidents)-1]
+	if !i.End.IsZero() {
+		return nil
+	}
+	return i
+}
+
+// History keeps health history of nodes in memory. It is fed by the
+// repository as things happen and may be rebuilt from the event log.
+type History struct {
+	mtx          sync.Mutex
+	nodes        map[repo.NodeID]*node
+	maxIncidents int
+	retention    time.Duration
+}
+
+// New returns an empty history which keeps at most maxIncidents incidents
+// of a node and forgets deleted nodes after the retention.
+func New(maxIncidents int, retention time.Duration) *History {
+	return &History{
+		nodes:        make(map[repo.NodeID]*node),
+		maxIncidents: maxIncidents,
+		retention:    retention,
+	}
+}
+
+// Replay feeds node events of the log into the history.
+func (h *History) Replay(evs []events.Event) {
+	for _, e := range evs {
+		switch e.Type {
+		case events.NodeRegistered:
+			name := ""
+			if e.Node != nil {
+				name = e.Node.Name
+			}
+			h.Registered(e.NodeID, name, e.Time)
+		case events.NodeUpdated:
+			if e.Node != nil && e.Node.State != "" {
+				h.StateChanged(e.NodeID, e.Node.State, e.Time)
+			}
+		case events.NodeCheckFailed:
+			h.CheckFailed(e.NodeID, e.Err, e.Time)
+		case events.NodeRemoved:
+			h.Deleted(e.NodeID, e.Time)
+		}
+	}
+}
+
+// Registered starts history of a new node, which is healthy.
+func (h *History) Registered(id repo.NodeID, name string, at time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.prune(at)
+	h.nodes[id] = &node{
+		name:       name,
+		registered: at,
+		state:      repo.NodeHealthy,
+		since:      at,
+		incidents:  make([]Incident, 0),
+	}
+}
+
+// Track starts history of a stored node which the history doesn't know yet,
+// for example an imported one or one registered without an event log. Its
+// history starts in its current state.
+func (h *History) Track(n repo.Node, now time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if _, ok := h.nodes[n.ID]; ok {
+		return
+	}
+	state := n.State
+	if state == "" {
+		state = repo.NodeHealthy
+	}
+	h.nodes[n.ID] = &node{
+		name:       n.Name,
+		registered: now,
+		state:      state,
+		since:      now,
+		incidents:  make([]Incident, 0),
+	}
+}
+
+// StateChanged records a new state of the node.
+func (h *History) StateChanged(id repo.NodeID, state repo.NodeState, at time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	n := h.get(id, at)
+	if state == n.state {
+		return
+	}
+	wasUp := isUp(n.state)
+	n.account(at)
+	n.state = state
+
+	switch {
+	case wasUp && !isUp(state):
+		n.failures++
+		n.incidents = append(n.incidents, Incident{Start: at, State: state})
+		if len(n.incidents) > h.maxIncidents {
+			n.incidents = n.incidents[len(n.incidents)-h.maxIncidents:]
+		}
+	case isUp(state):
+		if i := n.open(); i != nil {
+			i.End = at
+		}
+	default:
+		if i := n.open(); i != nil && worse(state, i.State) {
+			i.State = state
+		}
+	}
+}
+
+// CheckFailed records a failed health check of the node.
+func (h *History) CheckFailed(id repo.NodeID, err string, at time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	n := h.get(id, at)
+	if i := n.open(); i != nil {
+		i.FailedChecks++
+		if i.Err == "" {
+			i.Err = err
+		}
+	}
+}
+
+// Deleted closes history of the node, it is kept for the retention.
+func (h *History) Deleted(id repo.NodeID, at time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	n, ok := h.nodes[id]
+	if !ok || !n.deleted.IsZero() {
+		return
+	}
+	n.account(at)
+	n.deleted = at
+	if i := n.open(); i != nil {
+		i.End = at
+	}
+}
+
+// Report returns availability of the node with at most incidents last
+// incidents.
+func (h *History) Report(id repo.NodeID, incidents int, now time.Time) (Report, bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	n, ok := h.nodes[id]
+	if !ok {
+		return Report{}, false
+	}
+	return n.report(id, incidents, now), true
+}
+
+// Reports returns availability of all known nodes, the least available first.
+func (h *History) Reports(incidents int, now time.Time) []Report {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.prune(now)
+	result := make([]Report, 0, len(h.nodes))
+	for id, n := range h.nodes {
+		result = append(result, n.report(id, incidents, now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Uptime != result[j].Uptime {
+			return result[i].Uptime < result[j].Uptime
+		}
+		return result[i].Registered.Before(result[j].Registered)
+	})
+	return result
+}
+
+// get returns the node, nodes known before the history was started are
+// taken as registered at the moment they are first seen.
+func (h *History) get(id repo.NodeID, at time.Time) *node {
+	n, ok := h.nodes[id]
+	if !ok {
+		n = &node{
+			registered: at,
+			state:      repo.NodeHealthy,
+			since:      at,
+			incidents:  make([]Incident, 0),
+		}
+		h.nodes[id] = n
+	}
+	return n
+}
+
+// prune forgets deleted nodes after the retention.
+func (h *History) prune(now time.Time) {
+	for id, n := range h.nodes {
+		if !n.deleted.IsZero() && now.Sub(n.deleted) > h.retention {
+			delete(h.nodes, id)
+		}
+	}
+}
+
+// account adds the time spent in the current state until at.
+func (n *node) account(at time.Time) {
+	if at.Before(n.since) {
+		at = n.since
+	}
+	if isUp(n.state) {
+		n.up += at.Sub(n.since)
+	} else {
+		n.down += at.Sub(n.since)
+	}
+	n.since = at
+}
+
+func (n *node) report(id repo.NodeID, incidents int, now time.Time) Report {
+	up, down := n.up, n.down
+	if n.deleted.IsZero() && now.After(n.since) {
+		if isUp(n.state) {
+			up += now.Sub(n.since)
+		} else {
+			down += now.Sub(n.since)
+		}
+	}
+
+	r := Report{
+		NodeID:     id,
+		Name:       n.name,
+		State:      n.state,
+		Registered: n.registered,
+		Deleted:    n.deleted,
+		Uptime:     100,
+		Up:         up,
+		Down:       down,
+		Failures:   n.failures,
+		Incidents:  make([]Incident, 0),
+	}
+	if total := up + down; total > 0 {
+		r.Uptime = 100 * float64(up) / float64(total)
+	}
+	if n.failures > 0 {
+		r.MTBF = up / time.Duration(n.failures)
+	}
+	for i := len(n.incidents) - 1; i >= 0 && len(r.Incidents) < incidents; i-- {
+		r.Incidents = append(r.Incidents, n.incidents[i])
+	}
+	return r
+}
+
+// isUp tells whether a node in the state counts as available.
+func isUp(state repo.NodeState) bool {
+	return state == repo.NodeHealthy || state == repo.NodeDraining
+}
+
+// worse tells whether the state a is further from Healthy than b.
+func worse(a, b repo.NodeState) bool {
+	return severity[a] > severity[b]
+}
+
+var severity = map[repo.NodeState]int{
+	repo.NodeHealthy:   0,
+	repo.NodeDraining:  0,
+	repo.NodeSuspect:   1,
+	repo.NodeUnhealthy: 2,
+	repo.NodeRemoved:   3,
+}
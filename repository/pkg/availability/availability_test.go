@@ -0,0 +1,217 @@
+package availability_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"repository/pkg/availability"
+	repo "repository/pkg/model"
+)
+
+var t0 = time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// at returns the moment m minutes after t0.
+func at(m int) time.Time {
+	return t0.Add(time.Duration(m) * time.Minute)
+}
+
+// step feeds one thing that happened to the node into the history.
+type step func(h *availability.History, id repo.NodeID)
+
+func registered(m int) step {
+	return func(h *availability.History, id repo.NodeID) { h.Registered(id, "node-1", at(m)) }
+}
+
+func changed(m int, state repo.NodeState) step {
+	return func(h *availability.History, id repo.NodeID) { h.StateChanged(id, state, at(m)) }
+}
+
+func checkFailed(m int, err string) step {
+	return func(h *availability.History, id repo.NodeID) { h.CheckFailed(id, err, at(m)) }
+}
+
+func deleted(m int) step {
+	return func(h *availability.History, id repo.NodeID) { h.Deleted(id, at(m)) }
+}
+
+func TestReport(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		maxIncidents int
+		steps        []step
+		now          int
+		incidents    int
+
+		uptime   float64
+		up, down time.Duration
+		failures int
+		mtbf     time.Duration
+		state    repo.NodeState
+		deleted  time.Time
+		last     []availability.Incident
+	}{
+		{
+			name:      "never failed",
+			steps:     []step{registered(0)},
+			now:       60,
+			incidents: 10,
+			uptime:    100, up: time.Hour,
+			state: repo.NodeHealthy,
+			last:  []availability.Incident{},
+		},
+		{
+			name:      "draining is up",
+			steps:     []step{registered(0), changed(10, repo.NodeDraining)},
+			now:       60,
+			incidents: 10,
+			uptime:    100, up: time.Hour,
+			state: repo.NodeDraining,
+			last:  []availability.Incident{},
+		},
+		{
+			name:      "recovered",
+			steps:     []step{registered(0), changed(30, repo.NodeUnhealthy), changed(45, repo.NodeHealthy)},
+			now:       60,
+			incidents: 10,
+			uptime:    75, up: 45 * time.Minute, down: 15 * time.Minute,
+			failures: 1, mtbf: 45 * time.Minute,
+			state: repo.NodeHealthy,
+			last: []availability.Incident{
+				{Start: at(30), End: at(45), State: repo.NodeUnhealthy},
+			},
+		},
+		{
+			name: "lasting incident gets worse",
+			steps: []step{
+				registered(0),
+				changed(20, repo.NodeSuspect),
+				checkFailed(25, "timeout"),
+				checkFailed(30, "connection refused"),
+				changed(30, repo.NodeUnhealthy),
+			},
+			now:       40,
+			incidents: 10,
+			uptime:    50, up: 20 * time.Minute, down: 20 * time.Minute,
+			failures: 1, mtbf: 20 * time.Minute,
+			state: repo.NodeUnhealthy,
+			last: []availability.Incident{
+				{Start: at(20), State: repo.NodeUnhealthy, FailedChecks: 2, Err: "timeout"},
+			},
+		},
+		{
+			name:      "deleted while down",
+			steps:     []step{registered(0), changed(30, repo.NodeUnhealthy), deleted(40)},
+			now:       100,
+			incidents: 10,
+			uptime:    75, up: 30 * time.Minute, down: 10 * time.Minute,
+			failures: 1, mtbf: 30 * time.Minute,
+			state:   repo.NodeUnhealthy,
+			deleted: at(40),
+			last: []availability.Incident{
+				{Start: at(30), End: at(40), State: repo.NodeUnhealthy},
+			},
+		},
+		{
+			name: "last two incidents",
+			steps: []step{
+				registered(0),
+				changed(10, repo.NodeSuspect), changed(20, repo.NodeHealthy),
+				changed(30, repo.NodeUnhealthy), changed(40, repo.NodeHealthy),
+				changed(50, repo.NodeSuspect), changed(55, repo.NodeHealthy),
+			},
+			now:       60,
+			incidents: 2,
+			uptime:    100 * 35.0 / 60, up: 35 * time.Minute, down: 25 * time.Minute,
+			failures: 3, mtbf: 35 * time.Minute / 3,
+			state: repo.NodeHealthy,
+			last: []availability.Incident{
+				{Start: at(50), End: at(55), State: repo.NodeSuspect},
+				{Start: at(30), End: at(40), State: repo.NodeUnhealthy},
+			},
+		},
+		{
+			name:         "incidents beyond the maximum are forgotten",
+			maxIncidents: 2,
+			steps: []step{
+				registered(0),
+				changed(10, repo.NodeSuspect), changed(20, repo.NodeHealthy),
+				changed(30, repo.NodeUnhealthy), changed(40, repo.NodeHealthy),
+				changed(50, repo.NodeSuspect), changed(55, repo.NodeHealthy),
+			},
+			now:       60,
+			incidents: 10,
+			uptime:    100 * 35.0 / 60, up: 35 * time.Minute, down: 25 * time.Minute,
+			failures: 3, mtbf: 35 * time.Minute / 3,
+			state: repo.NodeHealthy,
+			last: []availability.Incident{
+				{Start: at(50), End: at(55), State: repo.NodeSuspect},
+				{Start: at(30), End: at(40), State: repo.NodeUnhealthy},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			max := tc.maxIncidents
+			if max == 0 {
+				max = availability.DefaultMaxIncidents
+			}
+			h := availability.New(max, availability.DefaultRetention)
+			id := repo.NodeID{UUID: uuid.New()}
+			for _, s := range tc.steps {
+				s(h, id)
+			}
+
+			r, ok := h.Report(id, tc.incidents, at(tc.now))
+			if !ok {
+				t.Fatal("no report")
+			}
+			if math.Abs(r.Uptime-tc.uptime) > 1e-9 {
+				t.Errorf("uptime: want %v, got %v", tc.uptime, r.Uptime)
+			}
+			if r.Up != tc.up || r.Down != tc.down {
+				t.Errorf("want %v up and %v down, got %v and %v", tc.up, tc.down, r.Up, r.Down)
+			}
+			if r.Failures != tc.failures || r.MTBF != tc.mtbf {
+				t.Errorf("want %d failures and MTBF %v, got %d and %v", tc.failures, tc.mtbf, r.Failures, r.MTBF)
+			}
+			if r.State != tc.state || !r.Deleted.Equal(tc.deleted) {
+				t.Errorf("want %s deleted at %v, got %s deleted at %v", tc.state, tc.deleted, r.State, r.Deleted)
+			}
+			if !r.Registered.Equal(t0) {
+				t.Errorf("want registered at %v, got %v", t0, r.Registered)
+			}
+			if !reflect.DeepEqual(r.Incidents, tc.last) {
+				t.Errorf("incidents:\nwant %+v\ngot  %+v", tc.last, r.Incidents)
+			}
+		})
+	}
+}
+
+// Reports lists the least available node first and forgets deleted nodes
+// after the retention.
+func TestReports(t *testing.T) {
+	h := availability.New(availability.DefaultMaxIncidents, time.Hour)
+	up, down, gone := repo.NodeID{UUID: uuid.New()}, repo.NodeID{UUID: uuid.New()}, repo.NodeID{UUID: uuid.New()}
+	h.Registered(up, "up", at(0))
+	h.Registered(down, "down", at(0))
+	h.Registered(gone, "gone", at(5))
+	h.StateChanged(down, repo.NodeUnhealthy, at(30))
+	h.Deleted(gone, at(10))
+
+	names := func(rs []availability.Report) []string {
+		result := make([]string, 0, len(rs))
+		for _, r := range rs {
+			result = append(result, r.Name)
+		}
+		return result
+	}
+	if got, want := names(h.Reports(0, at(60))), []string{"down", "up", "gone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got, want := names(h.Reports(0, at(71))), []string{"down", "up"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the retention: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+// Package backup dumps the content of a repository to a versioned NDJSON
+// stream and restores it into any storage. A dump starts with a header line,
+// followed by one line per node (with its current jobs), one line per event
+// of the history, so archived jobs survive a restore too, and one line per
+// job which waits for a worker in the queue of the dispatcher.
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"repository/pkg/dispatch"
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+)
+
+// Version is a version of the dump format written by Export.
+const Version = 1
+
+// Kinds of records.
+const (
+	KindHeader = "header"
+	KindNode   = "node"
+	KindEvent  = "event"
+	KindQueued = "queued"
+)
+
+// ErrNoHeader is returned by Import for a stream which doesn't start with a header.
+var ErrNoHeader = errors.New("backup: dump has no header")
+
+// Record is a single line of a dump.
+type Record struct {
+	Kind    string        `json:"kind"`
+	Version int           `json:"version,omitempty"`
+	Created time.Time     `json:"created,omitempty"`
+	Node    *repo.Node    `json:"node,omitempty"`
+	Event   *events.Event `json:"event,omitempty"`
+	Queued  *QueuedJob    `json:"queued,omitempty"`
+}
+
+// QueuedJob is a job which waits for a worker.
+type QueuedJob struct {
+	ID      string `json:"id"`
+	Request string `json:"request"`
+}
+
+// Storage is the part of service.Storage used by backups.
+type Storage interface {
+	GetAllNodes() ([]repo.Node, error)
+	SaveNode(n repo.Node) error
+}
+
+// Queue is the part of the dispatcher used by backups. Restore queues jobs
+// which aren't queued already and returns how many it queued.
+type Queue interface {
+	Pending() ([]dispatch.Job, error)
+	Restore(jobs ...dispatch.Job) (int, error)
+}
+
+// Stats counts exported or imported records. Imported events and queued jobs
+// are the ones actually written, those already there are not counted.
+type Stats struct {
+	Nodes  int `json:"nodes"`
+	Jobs   int `json:"jobs"`
+	Events int `json:"events"`
+	Queued int `json:"queued"`
+}
+
+// Export writes all nodes of the storage, all events of the journal and the
+// jobs of the queue to w. The queue is nil if jobs aren't dispatched.
+func Export(w io.Writer, s Storage, journal events.Journal, queue Queue) (Stats, error) {
+	var stats Stats
+
+	nodes, err := s.GetAllNodes()
+	if err != nil {
+		return stats, err
+	}
+	history, err := journal.All()
+	if err != nil {
+		return stats, err
+	}
+	queued := make([]dispatch.Job, 0)
+	if queue != nil {
+		if queued, err = queue.Pending(); err != nil {
+			return stats, err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	if err := enc.Encode(Record{Kind: KindHeader, Version: Version, Created: time.Now().UTC()}); err != nil {
+		return stats, err
+	}
+	for i := range nodes {
+		if err := enc.Encode(Record{Kind: KindNode, Node: &nodes[i]}); err != nil {
+			return stats, err
+		}
+		stats.Nodes++
+		stats.Jobs += len(nodes[i].Jobs)
+	}
+	for i := range history {
+		if err := enc.Encode(Record{Kind: KindEvent, Event: &history[i]}); err != nil {
+			return stats, err
+		}
+		stats.Events++
+	}
+	for _, j := range queued {
+		if err := enc.Encode(Record{Kind: KindQueued, Queued: &QueuedJob{ID: j.ID, Request: j.Request}}); err != nil {
+			return stats, err
+		}
+		stats.Queued++
+	}
+
+	return stats, bw.Flush()
+}
+
+// Import reads a dump made by Export and writes it to the storage, the journal
+// and the queue. Importing the same dump twice duplicates nothing:
+//   - Nodes keep their IDs.
+//   - Events are history, they are restored with their sequence numbers
+//     straight into the event store, see events.Restorer, and are neither
+//     recorded nor published again. Events already stored are skipped, and a
+//     journal without a store, like events.Discard, writes none.
+//   - Jobs already queued aren't queued again. A nil queue takes none.
+//
+// Events are restored before nodes are saved, that way a journal which
+// compares saved nodes with its history doesn't record the imported state a
+// second time.
+func Import(r io.Reader, s Storage, journal events.Journal, queue Queue) (Stats, error) {
+	var stats Stats
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header Record
+	if err := dec.Decode(&header); err != nil {
+		return stats, err
+	}
+	if header.Kind != KindHeader {
+		return stats, ErrNoHeader
+	}
+	if header.Version > Version {
+		return stats, fmt.Errorf("backup: unsupported dump version %d", header.Version)
+	}
+
+	nodes := make([]repo.Node, 0)
+	history := make([]events.Event, 0)
+	queued := make([]dispatch.Job, 0)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		switch {
+		case rec.Kind == KindNode && rec.Node != nil:
+			nodes = append(nodes, *rec.Node)
+		case rec.Kind == KindEvent && rec.Event != nil:
+			history = append(history, *rec.Event)
+		case rec.Kind == KindQueued && rec.Queued != nil:
+			queued = append(queued, dispatch.Job{ID: rec.Queued.ID, Request: rec.Queued.Request})
+		default:
+			return stats, fmt.Errorf("backup: unknown record %q", rec.Kind)
+		}
+	}
+
+	if rs, ok := journal.(events.Restorer); ok {
+		written, err := rs.Restore(history...)
+		if err != nil {
+			return stats, err
+		}
+		stats.Events = len(written)
+	}
+
+	for _, n := range nodes {
+		if err := s.SaveNode(n); err != nil {
+			return stats, err
+		}
+		stats.Nodes++
+		stats.Jobs += len(n.Jobs)
+	}
+
+	if queue != nil && len(queued) > 0 {
+		n, err := queue.Restore(queued...)
+		stats.Queued = n
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
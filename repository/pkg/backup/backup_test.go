@@ -0,0 +1,160 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+
+	"repository/pkg/backup"
+	"repository/pkg/dispatch"
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+	"repository/pkg/storage/inmem"
+)
+
+// Importing a dump twice restores its history, nodes and queued jobs once,
+// and publishes nothing.
+func TestImportTwice(t *testing.T) {
+	dump, history := source(t)
+
+	store := events.NewMemStore()
+	pub := &publisher{}
+	stream, err := events.NewStream(store, pub, time.Second, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := inmem.New()
+	recorder, err := events.NewRecorder(storage, stream, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := dispatch.New(nil, nil, events.Discard, dispatch.Config{}, dispatch.Metrics{}, log.NewNopLogger())
+
+	want := []backup.Stats{
+		{Nodes: 1, Jobs: 2, Events: len(history), Queued: 1},
+		{Nodes: 1, Jobs: 2, Events: 0, Queued: 0},
+	}
+	for i, w := range want {
+		stats, err := backup.Import(bytes.NewReader(dump), recorder, recorder, queue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != w {
+			t.Errorf("import %d: want %+v, got %+v", i+1, w, stats)
+		}
+	}
+
+	got, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(history) {
+		t.Fatalf("want %d events, got %d", len(history), len(got))
+	}
+	for i := range history {
+		if got[i].Seq != history[i].Seq || got[i].Type != history[i].Type {
+			t.Errorf("event %d: want %d %s, got %d %s", i, history[i].Seq, history[i].Type, got[i].Seq, got[i].Type)
+		}
+	}
+	if n := pub.count(); n != 0 {
+		t.Errorf("want nothing published, got %d events", n)
+	}
+
+	nodes, err := storage.GetAllNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || len(nodes[0].Jobs) != 2 {
+		t.Errorf("want 1 node with 2 jobs, got %+v", nodes)
+	}
+	pending, err := queue.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != "job-queued" {
+		t.Errorf("want job-queued in the queue, got %+v", pending)
+	}
+
+	// New events are numbered after the restored ones.
+	if err := recorder.Record(events.Event{Type: events.JobSubmitted, Request: "req-new"}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := store.All()
+	if last := all[len(all)-1]; last.Seq != history[len(history)-1].Seq+1 {
+		t.Errorf("want seq %d, got %d", history[len(history)-1].Seq+1, last.Seq)
+	}
+}
+
+// A journal without an event store writes no history.
+func TestImportDiscard(t *testing.T) {
+	dump, _ := source(t)
+	stats, err := backup.Import(bytes.NewReader(dump), inmem.New(), events.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (backup.Stats{Nodes: 1, Jobs: 2}); stats != want {
+		t.Errorf("want %+v, got %+v", want, stats)
+	}
+}
+
+// source builds a repository with a node, two jobs and a queued job, and
+// returns its dump and history.
+func source(t *testing.T) ([]byte, []events.Event) {
+	t.Helper()
+	store := events.NewMemStore()
+	recorder, err := events.NewRecorder(inmem.New(), store, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := recorder.NewNode(repo.Node{Name: "node-1", IP: "127.0.0.1", Port: ":8081"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := repo.Node{ID: id, Name: "node-1", IP: "127.0.0.1", Port: ":8081", JobsCount: 1, Jobs: []repo.Job{
+		{ID: repo.JobID{UUID: uuid.New()}, Per: 50},
+		{ID: repo.JobID{UUID: uuid.New()}, Per: 100},
+	}}
+	if err := recorder.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := dispatch.New(nil, nil, events.Discard, dispatch.Config{}, dispatch.Metrics{}, log.NewNopLogger())
+	if err := queue.Dispatch(context.Background(), "job-queued", "req-queued"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	stats, err := backup.Export(&buf, recorder, recorder, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, _ := store.All()
+	if want := (backup.Stats{Nodes: 1, Jobs: 2, Events: len(history), Queued: 1}); stats != want {
+		t.Fatalf("export: want %+v, got %+v", want, stats)
+	}
+	return buf.Bytes(), history
+}
+
+// publisher counts published events.
+type publisher struct {
+	mtx sync.Mutex
+	n   int
+}
+
+func (p *publisher) Publish(subject string, data []byte) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.n++
+	return nil
+}
+
+func (p *publisher) count() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.n
+}
@@ -0,0 +1,220 @@
+// Package connpool keeps one gRPC connection per worker node, so the repository
+// doesn't dial a worker every time it checks it or starts a job on it.
+package connpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+
+	repo "repository/pkg/model"
+	workerservice "worker/pkg/service"
+	workertransport "worker/pkg/transport"
+)
+
+// ErrClosed is returned by Get after the pool is closed.
+var ErrClosed = errors.New("connpool: pool is closed")
+
+// Config configures connections of the pool.
+type Config struct {
+	// KeepaliveTime is how long a connection stays quiet before it is pinged.
+	// Workers must permit pings that often.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is how long a ping waits for an answer before the
+	// connection is considered broken.
+	KeepaliveTimeout time.Duration
+}
+
+// DefaultConfig pings idle workers every 30 seconds.
+var DefaultConfig = Config{
+	KeepaliveTime:    30 * time.Second,
+	KeepaliveTimeout: 10 * time.Second,
+}
+
+// Metrics of the pool. Open is the number of pooled connections, Dials,
+// Reuses and Evictions count new connections, reused connections and closed
+// ones.
+type Metrics struct {
+	Open      metrics.Gauge
+	Dials     metrics.Counter
+	Reuses    metrics.Counter
+	Evictions metrics.Counter
+}
+
+// DiscardMetrics drops all metrics of the pool.
+var DiscardMetrics = Metrics{
+	Open:      discard.NewGauge(),
+	Dials:     discard.NewCounter(),
+	Reuses:    discard.NewCounter(),
+	Evictions: discard.NewCounter(),
+}
+
+type entry struct {
+	addr   string
+	conn   *grpc.ClientConn
+	svc    workerservice.Service
+	health healthpb.HealthClient
+}
+
+// Pool keeps connections to workers keyed by node ID.
+type Pool struct {
+	mtx     sync.Mutex
+	entries map[repo.NodeID]*entry
+	closed  bool
+	cfg     Config
+	metrics Metrics
+	logger  log.Logger
+}
+
+// New returns an empty pool.
+func New(cfg Config, m Metrics, logger log.Logger) *Pool {
+	return &Pool{
+		entries: make(map[repo.NodeID]*entry),
+		cfg:     cfg,
+		metrics: m,
+		logger:  logger,
+	}
+}
+
+// Get returns a worker client for the node. A pooled connection is reused
+// unless the node moved to another address or the connection was shut down,
+// otherwise a new one is dialled within the context deadline.
+func (p *Pool) Get(ctx context.Context, id repo.NodeID, addr string) (workerservice.Service, error) {
+	e, err := p.get(ctx, id, addr)
+	if err != nil {
+		return nil, err
+	}
+	return e.svc, nil
+}
+
+// Health returns a grpc.health.v1 client for the node, it shares the
+// connection with the client returned by Get.
+func (p *Pool) Health(ctx context.Context, id repo.NodeID, addr string) (healthpb.HealthClient, error) {
+	e, err := p.get(ctx, id, addr)
+	if err != nil {
+		return nil, err
+	}
+	return e.health, nil
+}
+
+func (p *Pool) get(ctx context.Context, id repo.NodeID, addr string) (*entry, error) {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return nil, ErrClosed
+	}
+	e, ok := p.entries[id]
+	if ok && e.addr == addr && e.conn.GetState() != connectivity.Shutdown {
+		p.mtx.Unlock()
+		p.metrics.Reuses.Add(1)
+		return e, nil
+	}
+	if ok {
+		p.evictLocked(id)
+	}
+	p.mtx.Unlock()
+
+	// Dialling happens without the lock, a slow worker doesn't block others.
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                p.cfg.KeepaliveTime,
+			Timeout:             p.cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.metrics.Dials.Add(1)
+
+	otTracer := stdopentracing.GlobalTracer() // no-op
+	e = &entry{
+		addr:   addr,
+		conn:   conn,
+		svc:    workertransport.NewGRPCClient(conn, otTracer, p.logger),
+		health: healthpb.NewHealthClient(conn),
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.closed {
+		conn.Close()
+		return nil, ErrClosed
+	}
+	if other, ok := p.entries[id]; ok && other.addr == addr {
+		// Somebody dialled the same node meanwhile, keep their connection.
+		conn.Close()
+		return other, nil
+	}
+	if _, ok := p.entries[id]; ok {
+		p.evictLocked(id)
+	}
+	p.entries[id] = e
+	p.metrics.Open.Set(float64(len(p.entries)))
+	return e, nil
+}
+
+// Evict closes the connection to the node, if there is one.
+func (p *Pool) Evict(id repo.NodeID) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.evictLocked(id)
+}
+
+// Retain closes connections to all nodes which are not in ids.
+func (p *Pool) Retain(ids []repo.NodeID) {
+	keep := make(map[repo.NodeID]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for id := range p.entries {
+		if !keep[id] {
+			p.evictLocked(id)
+		}
+	}
+}
+
+// Len returns the number of pooled connections.
+func (p *Pool) Len() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.entries)
+}
+
+// Close closes all connections, the pool can't be used afterwards.
+func (p *Pool) Close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for id := range p.entries {
+		p.evictLocked(id)
+	}
+	p.closed = true
+}
+
+func (p *Pool) evictLocked(id repo.NodeID) {
+	e, ok := p.entries[id]
+	if !ok {
+		return
+	}
+	delete(p.entries, id)
+	if err := e.conn.Close(); err != nil {
+		p.logger.Log("connpool", "Evict", "node", id.String(), "err", err)
+	}
+	p.metrics.Evictions.Add(1)
+	p.metrics.Open.Set(float64(len(p.entries)))
+}
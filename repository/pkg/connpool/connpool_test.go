@@ -0,0 +1,169 @@
+package connpool_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"repository/pkg/connpool"
+	repo "repository/pkg/model"
+)
+
+// A node is dialled once and its connection is reused, until it moves to
+// another address.
+func TestGetReuse(t *testing.T) {
+	addr1, addr2 := worker(t), worker(t)
+	pool, m := newPool()
+	defer pool.Close()
+	id := nodeID()
+
+	for i := 0; i < 3; i++ {
+		get(t, pool, id, addr1)
+	}
+	if _, err := pool.Health(ctx(t), id, addr1); err != nil {
+		t.Fatal(err)
+	}
+	if dials, reuses := m.Dials.Value(), m.Reuses.Value(); dials != 1 || reuses != 3 {
+		t.Errorf("want 1 dial and 3 reuses, got %v and %v", dials, reuses)
+	}
+
+	health, err := pool.Health(ctx(t), id, addr2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := health.Check(ctx(t), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if dials, evictions := m.Dials.Value(), m.Evictions.Value(); dials != 2 || evictions != 1 {
+		t.Errorf("moved node: want 2 dials and 1 eviction, got %v and %v", dials, evictions)
+	}
+	if n := pool.Len(); n != 1 {
+		t.Errorf("want 1 connection, got %d", n)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	addr := worker(t)
+	pool, m := newPool()
+	defer pool.Close()
+	id := nodeID()
+
+	get(t, pool, id, addr)
+	pool.Evict(id)
+	pool.Evict(id) // unknown nodes are ignored
+	if n := pool.Len(); n != 0 {
+		t.Errorf("want no connections, got %d", n)
+	}
+	if evictions := m.Evictions.Value(); evictions != 1 {
+		t.Errorf("want 1 eviction, got %v", evictions)
+	}
+
+	get(t, pool, id, addr)
+	if dials := m.Dials.Value(); dials != 2 {
+		t.Errorf("want the evicted node dialled again, got %v dials", dials)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	addr := worker(t)
+	pool, m := newPool()
+	defer pool.Close()
+	ids := []repo.NodeID{nodeID(), nodeID(), nodeID()}
+	for _, id := range ids {
+		get(t, pool, id, addr)
+	}
+
+	pool.Retain(ids[1:2])
+	if n := pool.Len(); n != 1 {
+		t.Errorf("want 1 connection, got %d", n)
+	}
+	if evictions := m.Evictions.Value(); evictions != 2 {
+		t.Errorf("want 2 evictions, got %v", evictions)
+	}
+	get(t, pool, ids[1], addr)
+	if reuses := m.Reuses.Value(); reuses != 1 {
+		t.Errorf("want the retained node reused, got %v reuses", reuses)
+	}
+
+	pool.Retain(nil)
+	if n := pool.Len(); n != 0 {
+		t.Errorf("want no connections, got %d", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	addr := worker(t)
+	pool, _ := newPool()
+	id := nodeID()
+	get(t, pool, id, addr)
+
+	pool.Close()
+	if n := pool.Len(); n != 0 {
+		t.Errorf("want no connections, got %d", n)
+	}
+	if _, err := pool.Get(ctx(t), id, addr); err != connpool.ErrClosed {
+		t.Errorf("Get: want %v, got %v", connpool.ErrClosed, err)
+	}
+	if _, err := pool.Health(ctx(t), id, addr); err != connpool.ErrClosed {
+		t.Errorf("Health: want %v, got %v", connpool.ErrClosed, err)
+	}
+}
+
+type counters struct {
+	Dials, Reuses, Evictions *generic.Counter
+}
+
+func newPool() (*connpool.Pool, counters) {
+	c := counters{
+		Dials:     generic.NewCounter("dials"),
+		Reuses:    generic.NewCounter("reuses"),
+		Evictions: generic.NewCounter("evictions"),
+	}
+	m := connpool.Metrics{
+		Open:      discard.NewGauge(),
+		Dials:     c.Dials,
+		Reuses:    c.Reuses,
+		Evictions: c.Evictions,
+	}
+	return connpool.New(connpool.DefaultConfig, m, log.NewNopLogger()), c
+}
+
+// worker starts a gRPC server with the health service and returns its address.
+func worker(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, grpchealth.NewServer())
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+	return ln.Addr().String()
+}
+
+func get(t *testing.T, pool *connpool.Pool, id repo.NodeID, addr string) {
+	t.Helper()
+	if _, err := pool.Get(ctx(t), id, addr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ctx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func nodeID() repo.NodeID {
+	return repo.NodeID{UUID: uuid.New()}
+}
@@ -0,0 +1,204 @@
+// Package deadletter keeps NATS messages the repository failed to handle.
+//
+// A message which can't be decoded, or whose endpoint fails with anything but
+// an answer of the protocol, is republished on the dead-letter subject with
+// the error and its original payload. Every replica keeps the last letters it
+// received there, so any of them can list the letters and replay one, which
+// publishes the payload again on its original subject.
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/google/uuid"
+	"github.com/nats-io/go-nats"
+
+	"repository/pkg/natsconn"
+)
+
+// Subjects of dead letters.
+const (
+	// Subject is where failed messages are republished as letters.
+	Subject = "repository.DeadLetter"
+	// ReplayedSubject tells the replicas the ID of a letter which was
+	// replayed, they drop it.
+	ReplayedSubject = "repository.DeadLetter.Replayed"
+)
+
+// Stages a message fails at.
+const (
+	// StageDecode is a message which couldn't be decoded into a request.
+	StageDecode = "decode"
+	// StageEndpoint is a request the endpoint failed.
+	StageEndpoint = "endpoint"
+)
+
+// DefaultCapacity is how many letters a Box keeps by default.
+const DefaultCapacity = 1000
+
+// ErrUnknownLetter is returned for a letter the box doesn't keep, it was
+// replayed already or pushed out by newer letters.
+var ErrUnknownLetter = errors.New("deadletter: unknown letter")
+
+// Letter is a failed message with the error it failed with.
+type Letter struct {
+	ID      string    `json:"id"`
+	Subject string    `json:"subject"`
+	Stage   string    `json:"stage"`
+	Err     string    `json:"err"`
+	Payload []byte    `json:"payload"`
+	Time    time.Time `json:"time"`
+	// Replica is the replica which failed the message.
+	Replica string `json:"replica,omitempty"`
+}
+
+// Box sends failed messages to the dead-letter subject and keeps the last
+// letters received there.
+type Box struct {
+	conn     *natsconn.Manager
+	capacity int
+	replica  string
+	sent     metrics.Counter
+	logger   log.Logger
+
+	mtx     sync.Mutex
+	letters []Letter // oldest first
+}
+
+// New returns a box keeping up to capacity letters, which subscribes to the
+// dead-letter subjects on conn. Letters sent by the box are counted by sent,
+// with the subject and the stage as labels.
+func New(conn *natsconn.Manager, capacity int, replica string, sent metrics.Counter, logger log.Logger) (*Box, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if sent == nil {
+		sent = discard.NewCounter()
+	}
+	b := &Box{
+		conn:     conn,
+		capacity: capacity,
+		replica:  replica,
+		sent:     sent,
+		logger:   log.With(logger, "component", "DeadLetter"),
+	}
+	// Every replica keeps every letter, no queue group.
+	if err := conn.Subscribe(Subject, "", natsconn.Serve(b.receive)); err != nil {
+		return nil, err
+	}
+	if err := conn.Subscribe(ReplayedSubject, "", natsconn.Serve(b.replayed)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Send republishes a message received on subject which failed at stage with
+// err on the dead-letter subject. While NATS is unreachable the letter is
+// only logged.
+func (b *Box) Send(subject, stage string, err error, payload []byte) {
+	l := Letter{
+		ID:      uuid.New().String(),
+		Subject: subject,
+		Stage:   stage,
+		Err:     err.Error(),
+		Payload: payload,
+		Time:    time.Now().UTC(),
+		Replica: b.replica,
+	}
+	b.sent.With("subject", subject, "stage", stage).Add(1)
+	data, merr := json.Marshal(l)
+	if merr == nil {
+		merr = b.conn.Publish(Subject, data)
+	}
+	if merr != nil {
+		b.logger.Log("letter", l.ID, "subject", subject, "stage", stage, "reason", l.Err, "err", merr)
+	}
+}
+
+// List returns up to limit letters, the newest first, or all of them if
+// limit isn't positive.
+func (b *Box) List(limit int) []Letter {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	n := len(b.letters)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	letters := make([]Letter, 0, n)
+	for i := len(b.letters) - 1; i >= 0 && len(letters) < n; i-- {
+		letters = append(letters, b.letters[i])
+	}
+	return letters
+}
+
+// Replay publishes the payload of the letter on its original subject, with
+// no reply subject, and drops the letter from every replica. The requester
+// of the original message stopped waiting long ago, so nobody gets a reply:
+// a replay is a publish, its outcome shows in its effects or, if it fails
+// again, in a new letter.
+func (b *Box) Replay(id string) error {
+	b.mtx.Lock()
+	var (
+		l     Letter
+		found bool
+	)
+	for _, cur := range b.letters {
+		if cur.ID == id {
+			l, found = cur, true
+			break
+		}
+	}
+	b.mtx.Unlock()
+	if !found {
+		return ErrUnknownLetter
+	}
+
+	if err := b.conn.Publish(l.Subject, l.Payload); err != nil {
+		return err
+	}
+	b.drop(id)
+	if err := b.conn.Publish(ReplayedSubject, []byte(id)); err != nil {
+		b.logger.Log("letter", id, "err", err)
+	}
+	b.logger.Log("letter", id, "subject", l.Subject, "message", "replayed")
+	return nil
+}
+
+// receive keeps a letter published on the dead-letter subject.
+func (b *Box) receive(msg *nats.Msg) {
+	var l Letter
+	if err := json.Unmarshal(msg.Data, &l); err != nil || l.ID == "" {
+		b.logger.Log("message", "dropping a malformed letter", "err", err)
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.letters = append(b.letters, l)
+	if over := len(b.letters) - b.capacity; over > 0 {
+		b.letters = append(b.letters[:0], b.letters[over:]...)
+	}
+}
+
+// replayed drops a letter another replica replayed.
+func (b *Box) replayed(msg *nats.Msg) {
+	b.drop(string(msg.Data))
+}
+
+func (b *Box) drop(id string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for i, l := range b.letters {
+		if l.ID == id {
+			b.letters = append(b.letters[:i], b.letters[i+1:]...)
+			return
+		}
+	}
+}
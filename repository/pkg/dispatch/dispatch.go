@@ -0,0 +1,371 @@
+// Package dispatch offers new jobs to workers over NATS instead of calling a
+// chosen node.
+//
+// Jobs wait in a queue of the repository and are published one by one as
+// requests on the dispatch subject. Workers with free capacity take them in a
+// queue group, so every offer goes to a single worker, which starts the job
+// and acknowledges it with a reply. An offer which isn't acknowledged within
+// the ack timeout, because no worker had room or the worker died, is made
+// again after a pause, until a worker takes the job or the attempts run out.
+// Delivery is at least once: a job whose acknowledgement got lost may be
+// started by two workers.
+//
+// The queue is in memory by default, its jobs are lost with the repository.
+// A JetStreamQueue keeps them in a NATS JetStream stream instead, a job stays
+// there until a worker took it, so jobs survive restarts of the repository.
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/google/uuid"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+	workermodel "worker/pkg/model"
+	"worker/pkg/natstrace"
+)
+
+// ErrQueueFull is returned when a job is dispatched while the queue is full.
+var ErrQueueFull = errors.New("dispatch: queue of jobs is full")
+
+// ErrNotTaken is recorded for a job no worker took in all attempts.
+var ErrNotTaken = errors.New("dispatch: no worker took the job")
+
+// Requester sends a request on a subject and returns the reply.
+type Requester interface {
+	Request(subject string, data []byte, timeout time.Duration) ([]byte, error)
+}
+
+// Config tunes the dispatcher.
+type Config struct {
+	// AckTimeout is how long an offer waits for a worker to take the job.
+	AckTimeout time.Duration
+	// RetryPause is the pause before a job is offered again.
+	RetryPause time.Duration
+	// MaxAttempts is how many times a job is offered before it fails.
+	MaxAttempts int
+	// QueueSize is how many jobs may wait for a worker.
+	QueueSize int
+	// InFlight is how many offers wait for an acknowledgement at the same time.
+	InFlight int
+}
+
+// DefaultConfig is used for zero fields of a Config.
+var DefaultConfig = Config{
+	AckTimeout:  5 * time.Second,
+	RetryPause:  time.Second,
+	MaxAttempts: 60,
+	QueueSize:   10000,
+	InFlight:    64,
+}
+
+// Metrics of the dispatcher, nil ones are discarded.
+type Metrics struct {
+	// Dispatched counts jobs taken by workers.
+	Dispatched metrics.Counter
+	// Redelivered counts offers made again.
+	Redelivered metrics.Counter
+	// Failed counts jobs no worker took.
+	Failed metrics.Counter
+	// Pending is the number of jobs waiting for a worker.
+	Pending metrics.Gauge
+}
+
+// Job is a job waiting for a worker.
+type Job struct {
+	ID      string
+	Request string
+	// Parent is the span the job was dispatched in, offers follow from it.
+	Parent stdopentracing.SpanContext
+}
+
+// Queue keeps jobs until workers take them.
+type Queue interface {
+	// Put adds the job to the queue.
+	Put(j Job) error
+	// Get waits for the next job until stop is closed, ok is false then. The
+	// job has to be released with done: it leaves the queue, unless retry is
+	// true, then it is handed out again later.
+	Get(stop <-chan struct{}) (j Job, done func(retry bool), ok bool)
+	// Len returns the number of jobs in the queue, including the ones handed
+	// out and not released yet.
+	Len() int
+	// Pending returns the jobs in the queue, including the ones handed out
+	// and not released yet.
+	Pending() ([]Job, error)
+}
+
+// Dispatcher keeps the queue of jobs and offers them to workers.
+type Dispatcher struct {
+	conn    Requester
+	queue   Queue
+	tracer  stdopentracing.Tracer
+	journal events.Journal
+	cfg     Config
+	metrics Metrics
+	logger  log.Logger
+}
+
+// New returns a dispatcher which keeps jobs in the queue, offers them through
+// conn and records their start or failure to the journal. A nil queue is an
+// in-memory one of cfg.QueueSize jobs.
+func New(conn Requester, queue Queue, journal events.Journal, cfg Config, m Metrics, logger log.Logger) *Dispatcher {
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = DefaultConfig.AckTimeout
+	}
+	if cfg.RetryPause <= 0 {
+		cfg.RetryPause = DefaultConfig.RetryPause
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultConfig.QueueSize
+	}
+	if cfg.InFlight <= 0 {
+		cfg.InFlight = DefaultConfig.InFlight
+	}
+	if m.Dispatched == nil {
+		m.Dispatched = discard.NewCounter()
+	}
+	if m.Redelivered == nil {
+		m.Redelivered = discard.NewCounter()
+	}
+	if m.Failed == nil {
+		m.Failed = discard.NewCounter()
+	}
+	if m.Pending == nil {
+		m.Pending = discard.NewGauge()
+	}
+	if queue == nil {
+		queue = NewMemoryQueue(cfg.QueueSize)
+	}
+	return &Dispatcher{
+		conn:    conn,
+		queue:   queue,
+		tracer:  stdopentracing.GlobalTracer(),
+		journal: journal,
+		cfg:     cfg,
+		metrics: m,
+		logger:  logger,
+	}
+}
+
+// Dispatch queues the job, it is offered to workers in the background. The
+// offers continue the trace of the context.
+func (d *Dispatcher) Dispatch(ctx context.Context, jobID, request string) error {
+	j := Job{ID: jobID, Request: request}
+	if span := stdopentracing.SpanFromContext(ctx); span != nil {
+		j.Parent = span.Context()
+	}
+	if err := d.queue.Put(j); err != nil {
+		return err
+	}
+	d.metrics.Pending.Set(float64(d.queue.Len()))
+	return nil
+}
+
+// Pending returns the jobs which wait for a worker, for backups.
+func (d *Dispatcher) Pending() ([]Job, error) {
+	return d.queue.Pending()
+}
+
+// Restore queues the jobs of a backup which aren't queued already and
+// returns how many it queued.
+func (d *Dispatcher) Restore(jobs ...Job) (int, error) {
+	pending, err := d.queue.Pending()
+	if err != nil {
+		return 0, err
+	}
+	queued := make(map[string]bool, len(pending))
+	for _, j := range pending {
+		queued[j.ID] = true
+	}
+
+	n := 0
+	for _, j := range jobs {
+		if queued[j.ID] {
+			continue
+		}
+		if err := d.queue.Put(j); err != nil {
+			return n, err
+		}
+		queued[j.ID] = true
+		n++
+	}
+	d.metrics.Pending.Set(float64(d.queue.Len()))
+	return n, nil
+}
+
+// Run offers queued jobs until stop is closed. Jobs still in an in-memory
+// queue then are dropped, a durable queue hands them out again.
+func (d *Dispatcher) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, d.cfg.InFlight)
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-stop:
+			return
+		}
+		j, done, ok := d.queue.Get(stop)
+		if !ok {
+			return
+		}
+		d.metrics.Pending.Set(float64(d.queue.Len()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			done(!d.deliver(j, stop))
+			d.metrics.Pending.Set(float64(d.queue.Len()))
+		}()
+	}
+}
+
+// deliver offers the job until a worker takes it or the attempts run out, it
+// returns false if stop interrupted it.
+func (d *Dispatcher) deliver(j Job, stop <-chan struct{}) bool {
+	jobID := repo.JobID{}
+	if id, err := uuid.Parse(j.ID); err == nil {
+		jobID.UUID = id
+	}
+
+	var err error
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			d.metrics.Redelivered.Add(1)
+			select {
+			case <-time.After(d.cfg.RetryPause):
+			case <-stop:
+				return false
+			}
+		}
+
+		var ack workermodel.DispatchAck
+		if ack, err = d.offer(j, attempt); err != nil {
+			continue
+		}
+
+		nodeID := repo.NodeID{}
+		if id, err := uuid.Parse(ack.NodeID); err == nil {
+			nodeID.UUID = id
+		}
+		d.metrics.Dispatched.Add(1)
+		d.logger.Log("dispatch", "Deliver", "job", j.ID, "node", ack.NodeID, "attempt", attempt)
+		d.journal.Record(events.Event{Type: events.JobScheduled, Request: j.Request, NodeID: nodeID, JobID: jobID})
+		return true
+	}
+
+	d.metrics.Failed.Add(1)
+	d.logger.Log("dispatch", "Deliver", "job", j.ID, "attempts", d.cfg.MaxAttempts, "err", err)
+	d.journal.Record(events.Event{Type: events.JobFailed, Request: j.Request, JobID: jobID, Err: ErrNotTaken.Error()})
+	return true
+}
+
+// offer makes a single offer of the job and waits for the acknowledgement.
+func (d *Dispatcher) offer(j Job, attempt int) (workermodel.DispatchAck, error) {
+	var ack workermodel.DispatchAck
+	opts := []stdopentracing.StartSpanOption{ext.SpanKindRPCClient, stdopentracing.Tag{Key: "attempt", Value: attempt}}
+	if j.Parent != nil {
+		opts = append(opts, stdopentracing.FollowsFrom(j.Parent))
+	}
+	span := d.tracer.StartSpan("DispatchJob", opts...)
+	defer span.Finish()
+
+	data, err := json.Marshal(workermodel.Dispatch{JobID: j.ID, Attempt: attempt})
+	if err == nil {
+		data, err = natstrace.Wrap(d.tracer, span, data)
+	}
+	if err != nil {
+		return ack, err
+	}
+	reply, err := d.conn.Request(workermodel.DispatchSubject, data, d.cfg.AckTimeout)
+	if err != nil {
+		span.LogKV("err", err)
+		return ack, err
+	}
+	if err := json.Unmarshal(reply, &ack); err != nil {
+		return ack, err
+	}
+	if ack.Err != "" {
+		return ack, errors.New(ack.Err)
+	}
+	return ack, nil
+}
+
+// MemoryQueue is a Queue in memory, its jobs are lost with the repository.
+type MemoryQueue struct {
+	jobs chan Job
+
+	mtx     sync.Mutex
+	pending map[string]Job // jobs in the channel or handed out
+}
+
+// NewMemoryQueue returns a queue of up to size jobs.
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{jobs: make(chan Job, size), pending: make(map[string]Job)}
+}
+
+// Put adds the job to the queue, it fails with ErrQueueFull if there is no
+// room.
+func (q *MemoryQueue) Put(j Job) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	select {
+	case q.jobs <- j:
+		q.pending[j.ID] = j
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Get waits for the next job. A job released for a retry is put back at the
+// end of the queue, unless it is full.
+func (q *MemoryQueue) Get(stop <-chan struct{}) (Job, func(bool), bool) {
+	select {
+	case j := <-q.jobs:
+		return j, func(retry bool) {
+			q.mtx.Lock()
+			delete(q.pending, j.ID)
+			q.mtx.Unlock()
+			if retry {
+				q.Put(j)
+			}
+		}, true
+	case <-stop:
+		return Job{}, nil, false
+	}
+}
+
+// Len returns the number of jobs in the queue or handed out.
+func (q *MemoryQueue) Len() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return len(q.pending)
+}
+
+// Pending returns the jobs in the queue or handed out, in no particular
+// order.
+func (q *MemoryQueue) Pending() ([]Job, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	result := make([]Job, 0, len(q.pending))
+	for _, j := range q.pending {
+		result = append(result, j)
+	}
+	return result, nil
+}
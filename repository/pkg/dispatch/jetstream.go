@@ -0,0 +1,457 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"repository/pkg/natsconn"
+)
+
+// ErrNoJetStream is returned while the stream of jobs can't be set up, e.g.
+// because the NATS server runs without JetStream.
+var ErrNoJetStream = errors.New("dispatch: JetStream is not available")
+
+// JetStreamConfig tunes a JetStreamQueue.
+type JetStreamConfig struct {
+	// Stream is the name of the stream of jobs.
+	Stream string
+	// Subject is where jobs are published to the stream.
+	Subject string
+	// Consumer is the name of the durable consumer of the repository, all
+	// replicas share it.
+	Consumer string
+	// AckWait is how long a job handed out may go without progress before
+	// it is handed out again, to the same or to another replica.
+	AckWait time.Duration
+	// PullWait is how long a request for the next job waits on the server.
+	PullWait time.Duration
+	// Timeout is how long other requests to the JetStream API wait.
+	Timeout time.Duration
+}
+
+// DefaultJetStreamConfig is used for zero fields of a JetStreamConfig.
+var DefaultJetStreamConfig = JetStreamConfig{
+	Stream:   "JOBS",
+	Subject:  "SubmitJob",
+	Consumer: "repository",
+	AckWait:  30 * time.Second,
+	PullWait: 5 * time.Second,
+	Timeout:  5 * time.Second,
+}
+
+// Acknowledgements of JetStream messages.
+var (
+	ackOK         = []byte("+ACK")
+	ackNak        = []byte("-NAK")
+	ackInProgress = []byte("+WPI")
+)
+
+// JetStreamQueue is a Queue kept in a JetStream work queue stream. A job is
+// published to the stream before Dispatch returns and is removed only once
+// the dispatcher releases it, so jobs survive restarts of the repository.
+// The stream and the consumer are created on first use, the JetStream API is
+// spoken over plain NATS requests.
+type JetStreamQueue struct {
+	conn   *natsconn.Manager
+	cfg    JetStreamConfig
+	inbox  string         // pulled jobs come here
+	msgs   chan *nats.Msg // messages received on the inbox
+	tracer stdopentracing.Tracer
+	logger log.Logger
+
+	pending  int32 // jobs in the stream not handed out, as of the last one
+	inFlight int32 // jobs handed out and not released yet
+
+	mtx   sync.Mutex
+	ready bool // the stream and the consumer exist
+}
+
+// submission is a job as it is stored in the stream.
+type submission struct {
+	JobID   string            `json:"job"`
+	Request string            `json:"request"`
+	Trace   map[string]string `json:"trace,omitempty"`
+}
+
+// jsError is the error of a reply of the JetStream API.
+type jsError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *jsError) Error() string {
+	return fmt.Sprintf("jetstream: %s (%d)", e.Description, e.ErrCode)
+}
+
+// jsStreamNameInUse is the error code of a stream which exists with another
+// configuration.
+const jsStreamNameInUse = 10058
+
+// jsNoMessageFound is the error code of a message which was removed from the
+// stream.
+const jsNoMessageFound = 10037
+
+// NewJetStreamQueue returns a queue in the stream of jobs, reached through
+// conn.
+func NewJetStreamQueue(conn *natsconn.Manager, cfg JetStreamConfig, logger log.Logger) (*JetStreamQueue, error) {
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultJetStreamConfig.Stream
+	}
+	if cfg.Subject == "" {
+		cfg.Subject = DefaultJetStreamConfig.Subject
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = DefaultJetStreamConfig.Consumer
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = DefaultJetStreamConfig.AckWait
+	}
+	if cfg.PullWait <= 0 {
+		cfg.PullWait = DefaultJetStreamConfig.PullWait
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultJetStreamConfig.Timeout
+	}
+	q := &JetStreamQueue{
+		conn:   conn,
+		cfg:    cfg,
+		inbox:  nats.NewInbox(),
+		msgs:   make(chan *nats.Msg, 16),
+		tracer: stdopentracing.GlobalTracer(),
+		logger: log.With(logger, "queue", "JetStream", "stream", cfg.Stream),
+	}
+	// Pulled messages are delivered under the subject they were published
+	// on, which the request/reply of the client can't handle, so they come to
+	// an inbox of the queue.
+	err := conn.Subscribe(q.inbox, "", natsconn.Serve(q.deliver))
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// deliver hands a pulled message to Get. Messages of the inbox are handled
+// one by one, so deliver never waits: a job which comes while the inbox is
+// full, because Get gave up on its pulls, is handed back to the server and
+// handed out again right away.
+func (q *JetStreamQueue) deliver(msg *nats.Msg) {
+	select {
+	case q.msgs <- msg:
+		return
+	default:
+	}
+	if _, _, ok := ackInfo(msg.Reply); !ok {
+		return
+	}
+	q.logger.Log("message", "inbox is full, the job is handed back")
+	if err := q.conn.Publish(msg.Reply, ackNak); err != nil {
+		q.logger.Log("err", err)
+	}
+}
+
+// Put publishes the job to the stream and waits until the server stored it.
+func (q *JetStreamQueue) Put(j Job) error {
+	if err := q.setup(); err != nil {
+		return err
+	}
+	s := submission{JobID: j.ID, Request: j.Request}
+	if j.Parent != nil {
+		carrier := stdopentracing.TextMapCarrier{}
+		if err := q.tracer.Inject(j.Parent, stdopentracing.TextMap, carrier); err == nil {
+			s.Trace = carrier
+		}
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	var ack struct {
+		Stream string   `json:"stream"`
+		Seq    uint64   `json:"seq"`
+		Error  *jsError `json:"error"`
+	}
+	if err := q.request(q.cfg.Subject, data, q.cfg.Timeout, &ack); err != nil {
+		return err
+	}
+	if ack.Error != nil {
+		return ack.Error
+	}
+	atomic.AddInt32(&q.pending, 1)
+	return nil
+}
+
+// Get pulls the next job from the stream. While the job is handed out the
+// server is told that it is in progress, done acknowledges it or, for a
+// retry, asks the server to hand it out again.
+func (q *JetStreamQueue) Get(stop <-chan struct{}) (Job, func(bool), bool) {
+	next := "$JS.API.CONSUMER.MSG.NEXT." + q.cfg.Stream + "." + q.cfg.Consumer
+	pull, _ := json.Marshal(struct {
+		Batch   int   `json:"batch"`
+		Expires int64 `json:"expires"`
+	}{Batch: 1, Expires: int64(q.cfg.PullWait)})
+
+	for {
+		select {
+		case <-stop:
+			return Job{}, nil, false
+		default:
+		}
+		if err := q.setup(); err != nil {
+			q.logger.Log("err", err)
+			if !q.pause(stop) {
+				return Job{}, nil, false
+			}
+			continue
+		}
+
+		if err := q.conn.PublishRequest(next, q.inbox, pull); err != nil {
+			q.logger.Log("err", err)
+			if !q.pause(stop) {
+				return Job{}, nil, false
+			}
+			continue
+		}
+		var msg *nats.Msg
+		select {
+		case msg = <-q.msgs:
+		case <-time.After(q.cfg.PullWait + time.Second):
+			continue
+		case <-stop:
+			// A job pulled meanwhile is handed out again after AckWait.
+			return Job{}, nil, false
+		}
+		delivered, pending, ok := ackInfo(msg.Reply)
+		if !ok {
+			// A status message, no job came within the pull wait.
+			continue
+		}
+		atomic.StoreInt32(&q.pending, int32(pending))
+
+		j, err := q.decode(msg.Data)
+		if err != nil {
+			q.logger.Log("message", "dropping a malformed job", "err", err)
+			q.conn.Publish(msg.Reply, ackOK)
+			continue
+		}
+		if delivered > 1 {
+			q.logger.Log("job", j.ID, "delivered", delivered, "message", "job handed out again")
+		}
+
+		atomic.AddInt32(&q.inFlight, 1)
+		reply := msg.Reply
+		working := make(chan struct{})
+		go q.inProgress(reply, working)
+		return j, func(retry bool) {
+			close(working)
+			atomic.AddInt32(&q.inFlight, -1)
+			ack := ackOK
+			if retry {
+				ack = ackNak
+			}
+			if err := q.conn.Publish(reply, ack); err != nil {
+				q.logger.Log("job", j.ID, "err", err)
+			}
+		}, true
+	}
+}
+
+// decode returns the job of a submission stored in the stream.
+func (q *JetStreamQueue) decode(data []byte) (Job, error) {
+	var s submission
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Job{}, err
+	}
+	j := Job{ID: s.JobID, Request: s.Request}
+	if s.Trace != nil {
+		if sc, err := q.tracer.Extract(stdopentracing.TextMap, stdopentracing.TextMapCarrier(s.Trace)); err == nil {
+			j.Parent = sc
+		}
+	}
+	return j, nil
+}
+
+// inProgress tells the server that the job of the reply subject is still
+// being delivered until working is closed, so it isn't handed out again.
+func (q *JetStreamQueue) inProgress(reply string, working <-chan struct{}) {
+	ticker := time.NewTicker(q.cfg.AckWait / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := q.conn.Publish(reply, ackInProgress); err != nil {
+				q.logger.Log("err", err)
+			}
+		case <-working:
+			return
+		}
+	}
+}
+
+// pause waits before the next attempt, it returns false if stop was closed
+// meanwhile.
+func (q *JetStreamQueue) pause(stop <-chan struct{}) bool {
+	select {
+	case <-time.After(time.Second):
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Len returns the number of jobs in the stream, including the ones handed
+// out. Jobs put by other replicas are counted once one is handed out here.
+func (q *JetStreamQueue) Len() int {
+	return int(atomic.LoadInt32(&q.pending) + atomic.LoadInt32(&q.inFlight))
+}
+
+// Pending reads the jobs in the stream, of all replicas, including the ones
+// handed out and not acknowledged yet. Jobs are read one by one from the
+// first to the last message of the stream, acknowledged ones are gone.
+func (q *JetStreamQueue) Pending() ([]Job, error) {
+	if err := q.setup(); err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		State struct {
+			FirstSeq uint64 `json:"first_seq"`
+			LastSeq  uint64 `json:"last_seq"`
+		} `json:"state"`
+		Error *jsError `json:"error"`
+	}
+	if err := q.request("$JS.API.STREAM.INFO."+q.cfg.Stream, nil, q.cfg.Timeout, &info); err != nil {
+		return nil, err
+	}
+	if info.Error != nil {
+		return nil, info.Error
+	}
+
+	result := make([]Job, 0)
+	for seq := info.State.FirstSeq; seq > 0 && seq <= info.State.LastSeq; seq++ {
+		get, _ := json.Marshal(struct {
+			Seq uint64 `json:"seq"`
+		}{seq})
+		var msg struct {
+			Message struct {
+				Data []byte `json:"data"`
+			} `json:"message"`
+			Error *jsError `json:"error"`
+		}
+		if err := q.request("$JS.API.STREAM.MSG.GET."+q.cfg.Stream, get, q.cfg.Timeout, &msg); err != nil {
+			return nil, err
+		}
+		if msg.Error != nil {
+			if msg.Error.ErrCode == jsNoMessageFound {
+				continue
+			}
+			return nil, msg.Error
+		}
+		j, err := q.decode(msg.Message.Data)
+		if err != nil {
+			continue
+		}
+		result = append(result, j)
+	}
+	return result, nil
+}
+
+// setup creates the stream and the consumer unless they exist already.
+func (q *JetStreamQueue) setup() error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.ready {
+		return nil
+	}
+
+	stream, _ := json.Marshal(map[string]interface{}{
+		"name":      q.cfg.Stream,
+		"subjects":  []string{q.cfg.Subject},
+		"retention": "workqueue",
+		"storage":   "file",
+	})
+	var created struct {
+		Error *jsError `json:"error"`
+	}
+	err := q.request("$JS.API.STREAM.CREATE."+q.cfg.Stream, stream, q.cfg.Timeout, &created)
+	if err == nats.ErrTimeout {
+		// Nobody serves the JetStream API.
+		return ErrNoJetStream
+	}
+	if err != nil {
+		return err
+	}
+	if created.Error != nil && created.Error.ErrCode != jsStreamNameInUse {
+		return created.Error
+	}
+
+	consumer, _ := json.Marshal(map[string]interface{}{
+		"stream_name": q.cfg.Stream,
+		"config": map[string]interface{}{
+			"durable_name":   q.cfg.Consumer,
+			"ack_policy":     "explicit",
+			"ack_wait":       int64(q.cfg.AckWait),
+			"deliver_policy": "all",
+			"max_deliver":    -1,
+		},
+	})
+	created.Error = nil
+	if err := q.request("$JS.API.CONSUMER.DURABLE.CREATE."+q.cfg.Stream+"."+q.cfg.Consumer, consumer, q.cfg.Timeout, &created); err != nil {
+		return err
+	}
+	if created.Error != nil {
+		return created.Error
+	}
+
+	q.ready = true
+	q.logger.Log("subject", q.cfg.Subject, "consumer", q.cfg.Consumer, "message", "stream of jobs is ready")
+	return nil
+}
+
+// request sends a request to the JetStream API and decodes the reply into
+// resp.
+func (q *JetStreamQueue) request(subject string, data []byte, timeout time.Duration, resp interface{}) error {
+	msg, err := q.conn.Request(subject, data, timeout)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg.Data, resp)
+}
+
+// ackInfo returns how many times the message was delivered and how many
+// messages are pending in the consumer, as told by the reply subject of a
+// message delivered by JetStream:
+// $JS.ACK.<stream>.<consumer>.<delivered>.<stream seq>.<consumer seq>.<time>.<pending>
+// Newer servers add the domain and the account hash after $JS.ACK and a
+// random token at the end. Other subjects aren't messages of a stream.
+func ackInfo(reply string) (delivered, pending int, ok bool) {
+	if !strings.HasPrefix(reply, "$JS.ACK.") {
+		return 0, 0, false
+	}
+	tokens := strings.Split(reply, ".")
+	var d, p int
+	switch {
+	case len(tokens) == 9:
+		d, p = 4, 8
+	case len(tokens) >= 11:
+		d, p = 6, 10
+	default:
+		return 0, 0, false
+	}
+	delivered, err := strconv.Atoi(tokens[d])
+	if err != nil {
+		return 0, 0, false
+	}
+	pending, err = strconv.Atoi(tokens[p])
+	return delivered, pending, err == nil
+}
@@ -0,0 +1,443 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"repository/pkg/events"
+	"repository/pkg/natsconn"
+	"repository/pkg/natsserver"
+	workermodel "worker/pkg/model"
+	"worker/pkg/natstrace"
+)
+
+// testJetStream is quick to hand jobs out again and to notice a stop.
+var testJetStream = JetStreamConfig{
+	AckWait:  time.Second,
+	PullWait: 200 * time.Millisecond,
+	Timeout:  2 * time.Second,
+}
+
+func TestJetStreamQueueRetry(t *testing.T) {
+	url := startNATS(t)
+	q := newJetStreamQueue(t, connect(t, url))
+
+	if err := q.Put(Job{ID: "job-1", Request: "req-1"}); err != nil {
+		t.Fatal(err)
+	}
+	j, done := get(t, q, 5*time.Second)
+	if j.ID != "job-1" || j.Request != "req-1" {
+		t.Fatalf("want job-1 of req-1, got %+v", j)
+	}
+	if n := q.Len(); n != 1 {
+		t.Errorf("want 1 job in the queue, got %d", n)
+	}
+	done(true)
+
+	j, done = get(t, q, 5*time.Second)
+	if j.ID != "job-1" {
+		t.Fatalf("want job-1 handed out again, got %+v", j)
+	}
+	done(false)
+
+	if j, _, ok := tryGet(q, 2*testJetStream.AckWait); ok {
+		t.Fatalf("want an empty queue, got %+v", j)
+	}
+}
+
+// A job pulled while the inbox is full is handed out again without waiting
+// for AckWait.
+func TestJetStreamQueueInboxFull(t *testing.T) {
+	url := startNATS(t)
+	conn := connect(t, url)
+	handedBack := make(chan struct{}, 1)
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if keyvals[i] == "message" && keyvals[i+1] == "inbox is full, the job is handed back" {
+				select {
+				case handedBack <- struct{}{}:
+				default:
+				}
+			}
+		}
+		return nil
+	})
+	q, err := NewJetStreamQueue(conn, testJetStream, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(Job{ID: "job-1", Request: "req-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for len(q.msgs) < cap(q.msgs) {
+		q.msgs <- &nats.Msg{}
+	}
+	next := "$JS.API.CONSUMER.MSG.NEXT." + q.cfg.Stream + "." + q.cfg.Consumer
+	if err := conn.PublishRequest(next, q.inbox, []byte(`{"batch":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handedBack:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job wasn't handed back")
+	}
+
+	for len(q.msgs) > 0 {
+		<-q.msgs
+	}
+	j, done := get(t, q, testJetStream.AckWait/2)
+	if j.ID != "job-1" {
+		t.Fatalf("want job-1, got %+v", j)
+	}
+	done(false)
+}
+
+// Pending lists queued jobs and jobs handed out, not the acknowledged ones.
+func TestJetStreamQueuePending(t *testing.T) {
+	url := startNATS(t)
+	q := newJetStreamQueue(t, connect(t, url))
+
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := q.Put(Job{ID: id, Request: "req-" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j, done := get(t, q, 5*time.Second)
+	if pending := pendingIDs(t, q); !pending["job-1"] || !pending["job-2"] || len(pending) != 2 {
+		t.Fatalf("want job-1 and job-2 pending, got %v", pending)
+	}
+
+	// The acknowledgement is published without waiting for the server.
+	done(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pending := pendingIDs(t, q)
+		if !pending[j.ID] && len(pending) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want only the job other than %s pending, got %v", j.ID, pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A job handed out to a replica which dies is handed out to another one
+// after AckWait, and jobs survive the replica which put them.
+func TestJetStreamQueueRedelivery(t *testing.T) {
+	url := startNATS(t)
+	crashed := connect(t, url)
+	q1 := newJetStreamQueue(t, crashed)
+
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := q1.Put(Job{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if j, _ := get(t, q1, 5*time.Second); j.ID != "job-1" {
+		t.Fatalf("want job-1, got %+v", j)
+	}
+	crashed.Close()
+
+	q2 := newJetStreamQueue(t, connect(t, url))
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		j, done := get(t, q2, 5*time.Second)
+		got[j.ID] = true
+		done(false)
+	}
+	if !got["job-1"] || !got["job-2"] {
+		t.Errorf("want job-1 and job-2, got %v", got)
+	}
+}
+
+// A job no worker takes fails after MaxAttempts offers and leaves the stream.
+func TestDispatcherMaxAttempts(t *testing.T) {
+	url := startNATS(t)
+	offers := make(chan string, 16)
+	serveWorker(t, connect(t, url), "node-1", true, offers)
+
+	conn := connect(t, url)
+	journal := &journal{}
+	d := New(requester{conn}, newJetStreamQueue(t, conn), journal, Config{
+		AckTimeout:  time.Second,
+		RetryPause:  10 * time.Millisecond,
+		MaxAttempts: 3,
+	}, Metrics{}, log.NewNopLogger())
+	stop := run(d)
+
+	id := uuid.New().String()
+	if err := d.Dispatch(context.Background(), id, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	failed := journal.wait(t, events.JobFailed, 1, 10*time.Second)
+	if failed[0].JobID.String() != id || failed[0].Err != ErrNotTaken.Error() {
+		t.Errorf("want job %s failed with %q, got %+v", id, ErrNotTaken, failed[0])
+	}
+	stop()
+
+	if n := len(offers); n != 3 {
+		t.Errorf("want 3 offers, got %d", n)
+	}
+	if n := len(journal.of(events.JobScheduled)); n != 0 {
+		t.Errorf("want no job scheduled, got %d", n)
+	}
+	q := newJetStreamQueue(t, connect(t, url))
+	if j, _, ok := tryGet(q, 2*testJetStream.AckWait); ok {
+		t.Fatalf("want an empty queue, got %+v", j)
+	}
+}
+
+// Replicas share the stream, every job is taken once whichever replica
+// dispatched it.
+func TestDispatcherReplicas(t *testing.T) {
+	const replicas, jobs = 3, 30
+
+	url := startNATS(t)
+	taken := make(chan string, 2*jobs)
+	serveWorker(t, connect(t, url), "node-1", false, taken)
+	serveWorker(t, connect(t, url), "node-2", false, taken)
+
+	dispatchers := make([]*Dispatcher, replicas)
+	journals := make([]*journal, replicas)
+	for i := range dispatchers {
+		conn := connect(t, url)
+		journals[i] = &journal{}
+		dispatchers[i] = New(requester{conn}, newJetStreamQueue(t, conn), journals[i], Config{
+			AckTimeout: time.Second,
+			RetryPause: 10 * time.Millisecond,
+		}, Metrics{}, log.NewNopLogger())
+		defer run(dispatchers[i])()
+	}
+
+	for i := 0; i < jobs; i++ {
+		id := uuid.New().String()
+		if err := dispatchers[i%replicas].Dispatch(context.Background(), id, fmt.Sprintf("req-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]int)
+	timeout := time.After(20 * time.Second)
+	for len(seen) < jobs {
+		select {
+		case id := <-taken:
+			seen[id]++
+		case <-timeout:
+			t.Fatalf("want %d jobs taken, got %d", jobs, len(seen))
+		}
+	}
+	// Give a job taken twice a chance to show up.
+	time.Sleep(100 * time.Millisecond)
+	for len(taken) > 0 {
+		seen[<-taken]++
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %s taken %d times", id, n)
+		}
+	}
+
+	scheduled := 0
+	for _, j := range journals {
+		scheduled += len(j.of(events.JobScheduled))
+	}
+	if scheduled != jobs {
+		t.Errorf("want %d jobs scheduled, got %d", jobs, scheduled)
+	}
+}
+
+func TestAckInfo(t *testing.T) {
+	tests := []struct {
+		reply              string
+		delivered, pending int
+		ok                 bool
+	}{
+		{"$JS.ACK.JOBS.repository.2.10.7.1700000000000000000.3", 2, 3, true},
+		{"$JS.ACK.domain.hash.JOBS.repository.1.10.7.1700000000000000000.0.random", 1, 0, true},
+		{"$JS.ACK.JOBS.repository.x.10.7.1700000000000000000.3", 0, 0, false},
+		{"$JS.ACK.JOBS.repository", 0, 0, false},
+		{"_INBOX.abc", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		delivered, pending, ok := ackInfo(tt.reply)
+		if delivered != tt.delivered || pending != tt.pending || ok != tt.ok {
+			t.Errorf("%q: want %d, %d, %v, got %d, %d, %v", tt.reply, tt.delivered, tt.pending, tt.ok, delivered, pending, ok)
+		}
+	}
+}
+
+// startNATS starts a NATS server with JetStream and returns its URL.
+func startNATS(t *testing.T) string {
+	ns, err := natsserver.Start(natsserver.Config{
+		Addr:     "127.0.0.1:-1",
+		StoreDir: t.TempDir(),
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns.ClientURL()
+}
+
+// connect returns a connection to the server, once it is established.
+func connect(t *testing.T, url string) *natsconn.Manager {
+	conn := natsconn.New(url, natsconn.Config{Name: t.Name(), ReconnectWait: 10 * time.Millisecond}, natsconn.Metrics{}, log.NewNopLogger())
+	t.Cleanup(conn.Close)
+	deadline := time.Now().Add(5 * time.Second)
+	for conn.Check() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("no connection to %s", url)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return conn
+}
+
+func newJetStreamQueue(t *testing.T, conn *natsconn.Manager) *JetStreamQueue {
+	q, err := NewJetStreamQueue(conn, testJetStream, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// get waits for the next job of the queue.
+func get(t *testing.T, q Queue, timeout time.Duration) (Job, func(bool)) {
+	t.Helper()
+	j, done, ok := tryGet(q, timeout)
+	if !ok {
+		t.Fatalf("no job within %v", timeout)
+	}
+	return j, done
+}
+
+func tryGet(q Queue, timeout time.Duration) (Job, func(bool), bool) {
+	stop := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stop) })
+	defer timer.Stop()
+	return q.Get(stop)
+}
+
+// run runs the dispatcher and returns a function which stops it.
+func run(d *Dispatcher) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(stop)
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// serveWorker takes dispatched jobs as a worker of the node does, or refuses
+// them. Offers are sent to offers.
+func serveWorker(t *testing.T, conn *natsconn.Manager, node string, refuse bool, offers chan<- string) {
+	err := conn.Subscribe(workermodel.DispatchSubject, workermodel.DispatchQueue, func(nc *nats.Conn) func(*nats.Msg) {
+		return func(msg *nats.Msg) {
+			data, _ := natstrace.Unwrap(stdopentracing.GlobalTracer(), msg.Data)
+			var d workermodel.Dispatch
+			if err := json.Unmarshal(data, &d); err != nil {
+				t.Errorf("malformed offer: %v", err)
+				return
+			}
+			ack := workermodel.DispatchAck{JobID: d.JobID, NodeID: node}
+			if refuse {
+				ack.Err = "no capacity"
+			}
+			reply, _ := json.Marshal(ack)
+			nc.Publish(msg.Reply, reply)
+			offers <- d.JobID
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// requester is the Requester of the dispatcher in the repository.
+type requester struct {
+	conn *natsconn.Manager
+}
+
+func (r requester) Request(subject string, data []byte, timeout time.Duration) ([]byte, error) {
+	msg, err := r.conn.Request(subject, data, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Data, nil
+}
+
+// journal keeps recorded events in memory.
+type journal struct {
+	mtx    sync.Mutex
+	events []events.Event
+}
+
+func (j *journal) Record(es ...events.Event) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.events = append(j.events, es...)
+	return nil
+}
+
+func (j *journal) All() ([]events.Event, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return append([]events.Event(nil), j.events...), nil
+}
+
+// of returns recorded events of the type.
+func (j *journal) of(typ events.Type) []events.Event {
+	all, _ := j.All()
+	result := make([]events.Event, 0)
+	for _, e := range all {
+		if e.Type == typ {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// wait waits until n events of the type are recorded.
+func (j *journal) wait(t *testing.T, typ events.Type, n int, timeout time.Duration) []events.Event {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		if es := j.of(typ); len(es) >= n {
+			return es
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d %s events within %v", n, typ, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pendingIDs returns the IDs of the pending jobs of the queue.
+func pendingIDs(t *testing.T, q Queue) map[string]bool {
+	t.Helper()
+	pending, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]bool)
+	for _, j := range pending {
+		result[j.ID] = true
+	}
+	return result
+}
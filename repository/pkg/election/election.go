@@ -0,0 +1,204 @@
+// Package election picks a single leader among replicas of the repository.
+//
+// Replicas compete for a lease kept in a shared LeaseStore. The holder renews
+// it every third of its TTL, the others take it over once it expires. Only
+// the leader checks nodes, followers forward writes to the leader at the
+// address it advertised in the lease.
+package election
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// DefaultLease is the name of the lease the repository competes for.
+const DefaultLease = "repository-leader"
+
+// DefaultTTL is how long a lease holds without being renewed.
+const DefaultTTL = 10 * time.Second
+
+// ErrNoLeader is returned when there is no leader to forward a call to.
+var ErrNoLeader = errors.New("election: no leader")
+
+// Holder is a replica holding a lease.
+type Holder struct {
+	// ID identifies the replica, it must be unique among replicas.
+	ID string
+	// Addr is the gRPC address other replicas reach the replica at.
+	Addr string
+	// Expires is when the lease ends unless it is renewed.
+	Expires time.Time
+}
+
+// LeaseStore keeps leases shared by replicas.
+type LeaseStore interface {
+	// Acquire takes the lease for the holder for ttl, or renews it if the
+	// holder already has it. It returns whoever holds the lease afterwards,
+	// which is somebody else while their lease hasn't expired.
+	Acquire(name string, holder Holder, ttl time.Duration) (Holder, error)
+	// Release gives the lease up if the holder has it, so that another
+	// replica doesn't wait for it to expire.
+	Release(name string, holderID string) error
+}
+
+// MemoryLeases keeps leases of a single process. It is used with storages
+// which can't be shared by replicas, the only replica is always the leader.
+type MemoryLeases struct {
+	mtx    sync.Mutex
+	leases map[string]Holder
+}
+
+// NewMemoryLeases returns an empty lease store.
+func NewMemoryLeases() *MemoryLeases {
+	return &MemoryLeases{leases: make(map[string]Holder)}
+}
+
+// Acquire implements LeaseStore.
+func (m *MemoryLeases) Acquire(name string, holder Holder, ttl time.Duration) (Holder, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	if cur, ok := m.leases[name]; ok && cur.ID != holder.ID && now.Before(cur.Expires) {
+		return cur, nil
+	}
+	holder.Expires = now.Add(ttl)
+	m.leases[name] = holder
+	return holder, nil
+}
+
+// Release implements LeaseStore.
+func (m *MemoryLeases) Release(name string, holderID string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if cur, ok := m.leases[name]; ok && cur.ID == holderID {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+// Elector keeps competing for a lease on behalf of a replica.
+type Elector struct {
+	store  LeaseStore
+	name   string
+	self   Holder
+	ttl    time.Duration
+	gauge  metrics.Gauge
+	logger log.Logger
+
+	mtx    sync.RWMutex
+	leader Holder
+	// until is when the replica stops taking itself for the leader unless it
+	// renews the lease. It is counted from the local clock before the lease
+	// was acquired, so the replica steps down before others may take over.
+	until time.Time
+}
+
+// New returns an elector of the replica self for the named lease. The gauge
+// is 1 while the replica leads and 0 otherwise, it may be nil.
+func New(store LeaseStore, name string, self Holder, ttl time.Duration, gauge metrics.Gauge, logger log.Logger) *Elector {
+	if gauge == nil {
+		gauge = discard.NewGauge()
+	}
+	return &Elector{
+		store:  store,
+		name:   name,
+		self:   self,
+		ttl:    ttl,
+		gauge:  gauge,
+		logger: logger,
+	}
+}
+
+// Run competes for the lease until stop is closed, then releases it.
+func (e *Elector) Run(stop <-chan struct{}) {
+	e.campaign()
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			e.resign()
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// IsLeader tells whether the replica holds the lease.
+func (e *Elector) IsLeader() bool {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	return e.leader.ID == e.self.ID && time.Now().Before(e.until)
+}
+
+// Leader returns the replica holding the lease, false if nobody is known to
+// hold it.
+func (e *Elector) Leader() (Holder, bool) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	if e.leader.ID == "" || !time.Now().Before(e.leader.Expires) {
+		return Holder{}, false
+	}
+	if e.leader.ID == e.self.ID && !time.Now().Before(e.until) {
+		return Holder{}, false
+	}
+	return e.leader, true
+}
+
+// Self returns the replica the elector competes for.
+func (e *Elector) Self() Holder {
+	return e.self
+}
+
+func (e *Elector) campaign() {
+	was := e.IsLeader()
+	start := time.Now()
+	leader, err := e.store.Acquire(e.name, e.self, e.ttl)
+	if err != nil {
+		// The lease is kept until it expires, a short outage of the store
+		// doesn't change the leader.
+		e.logger.Log("election", e.name, "replica", e.self.ID, "err", err)
+	} else {
+		e.mtx.Lock()
+		e.leader = leader
+		if leader.ID == e.self.ID {
+			e.until = start.Add(e.ttl)
+		}
+		e.mtx.Unlock()
+	}
+
+	now := e.IsLeader()
+	switch {
+	case now && !was:
+		e.gauge.Set(1)
+		e.logger.Log("election", e.name, "replica", e.self.ID, "leader", true)
+	case !now && was:
+		e.gauge.Set(0)
+		e.logger.Log("election", e.name, "replica", e.self.ID, "leader", false, "holder", leader.ID)
+	}
+}
+
+func (e *Elector) resign() {
+	if !e.IsLeader() {
+		return
+	}
+	e.mtx.Lock()
+	e.leader = Holder{}
+	e.until = time.Time{}
+	e.mtx.Unlock()
+	e.gauge.Set(0)
+
+	if err := e.store.Release(e.name, e.self.ID); err != nil {
+		e.logger.Log("election", e.name, "replica", e.self.ID, "during", "Release", "err", err)
+		return
+	}
+	e.logger.Log("election", e.name, "replica", e.self.ID, "leader", false, "action", "lease released")
+}
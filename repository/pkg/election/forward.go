@@ -0,0 +1,173 @@
+package election
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/deadletter"
+	"repository/pkg/model"
+	"repository/pkg/service"
+	"repository/pkg/transport"
+)
+
+// Forward returns a middleware which serves calls on the leader and forwards
+// them from followers to the leader over gRPC. Stored nodes are read by every
+// replica. Writes, availability reports, which are kept by the leader in
+// memory, exports, since the leader records the history and queues the jobs,
+// and dead letters, so an admin sees and replays them in one place, are
+// forwarded. The options are added to the dial of the leader.
+func Forward(e *Elector, logger log.Logger, opts ...grpc.DialOption) service.Middleware {
+	return func(next service.Service) service.Service {
+		return &forwarder{
+			next:     next,
+			elector:  e,
+			dialOpts: append([]grpc.DialOption{grpc.WithInsecure()}, opts...),
+			logger:   logger,
+		}
+	}
+}
+
+type forwarder struct {
+	next     service.Service
+	elector  *Elector
+	dialOpts []grpc.DialOption
+	logger   log.Logger
+
+	mtx    sync.Mutex
+	addr   string
+	conn   *grpc.ClientConn
+	leader service.Service
+}
+
+// target returns the service a write goes to, the local one on the leader.
+func (f *forwarder) target() (service.Service, error) {
+	if f.elector.IsLeader() {
+		return f.next, nil
+	}
+	leader, ok := f.elector.Leader()
+	if !ok || leader.ID == f.elector.Self().ID {
+		return nil, ErrNoLeader
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.conn != nil && f.addr == leader.Addr {
+		return f.leader, nil
+	}
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn, f.leader = nil, nil
+	}
+
+	// The dial doesn't block, a call to an unreachable leader fails within
+	// its own deadline.
+	conn, err := grpc.Dial(leader.Addr, f.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	f.logger.Log("election", "Forward", "leader", leader.ID, "addr", leader.Addr)
+	f.addr, f.conn = leader.Addr, conn
+	// Writes of all workers go through this client, they are limited by the
+	// endpoints of the leader only.
+	f.leader = transport.NewGRPCClientWithRate(conn, rate.Inf, stdopentracing.GlobalTracer(), f.logger)
+	return f.leader, nil
+}
+
+func (f *forwarder) RegisterNode(ctx context.Context, id string, name string, ip string, port string) (string, uint64, error) {
+	svc, err := f.target()
+	if err != nil {
+		return "", 0, err
+	}
+	return svc.RegisterNode(ctx, id, name, ip, port)
+}
+
+func (f *forwarder) GetAllNodes(ctx context.Context) ([]model.Node, error) {
+	return f.next.GetAllNodes(ctx)
+}
+
+func (f *forwarder) NewJob(ctx context.Context) (string, error) {
+	svc, err := f.target()
+	if err != nil {
+		return "", err
+	}
+	return svc.NewJob(ctx)
+}
+
+func (f *forwarder) Export(ctx context.Context) ([]byte, error) {
+	svc, err := f.target()
+	if err != nil {
+		return nil, err
+	}
+	return svc.Export(ctx)
+}
+
+func (f *forwarder) Import(ctx context.Context, data []byte) (backup.Stats, error) {
+	svc, err := f.target()
+	if err != nil {
+		return backup.Stats{}, err
+	}
+	return svc.Import(ctx, data)
+}
+
+func (f *forwarder) Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []model.Job, full bool) error {
+	svc, err := f.target()
+	if err != nil {
+		return err
+	}
+	return svc.Heartbeat(ctx, nodeID, jobsCount, jobs, full)
+}
+
+func (f *forwarder) GetAvailability(ctx context.Context, incidents int) ([]availability.Report, error) {
+	svc, err := f.target()
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetAvailability(ctx, incidents)
+}
+
+func (f *forwarder) GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (availability.Report, error) {
+	svc, err := f.target()
+	if err != nil {
+		return availability.Report{}, err
+	}
+	return svc.GetNodeAvailability(ctx, nodeID, incidents)
+}
+
+func (f *forwarder) DrainNode(ctx context.Context, nodeID string) error {
+	svc, err := f.target()
+	if err != nil {
+		return err
+	}
+	return svc.DrainNode(ctx, nodeID)
+}
+
+func (f *forwarder) DeregisterNode(ctx context.Context, nodeID string, unfinished []model.Job) (int, error) {
+	svc, err := f.target()
+	if err != nil {
+		return 0, err
+	}
+	return svc.DeregisterNode(ctx, nodeID, unfinished)
+}
+
+func (f *forwarder) ListDeadLetters(ctx context.Context, limit int) ([]deadletter.Letter, error) {
+	svc, err := f.target()
+	if err != nil {
+		return nil, err
+	}
+	return svc.ListDeadLetters(ctx, limit)
+}
+
+func (f *forwarder) ReplayDeadLetter(ctx context.Context, id string) error {
+	svc, err := f.target()
+	if err != nil {
+		return err
+	}
+	return svc.ReplayDeadLetter(ctx, id)
+}
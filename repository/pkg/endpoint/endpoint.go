@@ -15,8 +15,11 @@ import (
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
 
-	"repository/pkg/service"
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/deadletter"
 	repo "repository/pkg/model"
+	"repository/pkg/service"
 )
 
 // EndpointSet collects all of the endpoints that compose a repo service. It's meant to
@@ -26,6 +29,25 @@ type EndpointSet struct {
 	RegisterNodeEndpoint kitendpoint.Endpoint
 	GetAllNodesEndpoint  kitendpoint.Endpoint
 	NewJobEndpoint       kitendpoint.Endpoint
+	ExportEndpoint       kitendpoint.Endpoint
+	ImportEndpoint       kitendpoint.Endpoint
+	HeartbeatEndpoint    kitendpoint.Endpoint
+
+	GetAvailabilityEndpoint     kitendpoint.Endpoint
+	GetNodeAvailabilityEndpoint kitendpoint.Endpoint
+
+	DrainNodeEndpoint      kitendpoint.Endpoint
+	DeregisterNodeEndpoint kitendpoint.Endpoint
+
+	ListDeadLettersEndpoint  kitendpoint.Endpoint
+	ReplayDeadLetterEndpoint kitendpoint.Endpoint
+}
+
+// Failer is implemented by every response, it returns the error of the
+// service method. Transports which encode responses generically use it to
+// tell failed calls apart.
+type Failer interface {
+	Failed() error
 }
 
 // New returns a Set that wraps the provided server, and wires in all of the
@@ -61,10 +83,113 @@ func New(svc service.Service, logger log.Logger, duration metrics.Histogram, otT
 		newJobEndpoint = InstrumentingMiddleware(duration.With("method", "NewJob"))(newJobEndpoint)
 	}
 
+	var exportEndpoint kitendpoint.Endpoint
+	{
+		exportEndpoint = MakeExportEndpoint(svc)
+		exportEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(exportEndpoint)
+		exportEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(exportEndpoint)
+		exportEndpoint = opentracing.TraceServer(otTracer, "Export")(exportEndpoint)
+		exportEndpoint = LoggingMiddleware(log.With(logger, "method", "Export"))(exportEndpoint)
+		exportEndpoint = InstrumentingMiddleware(duration.With("method", "Export"))(exportEndpoint)
+	}
+
+	var importEndpoint kitendpoint.Endpoint
+	{
+		importEndpoint = MakeImportEndpoint(svc)
+		importEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(importEndpoint)
+		importEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(importEndpoint)
+		importEndpoint = opentracing.TraceServer(otTracer, "Import")(importEndpoint)
+		importEndpoint = LoggingMiddleware(log.With(logger, "method", "Import"))(importEndpoint)
+		importEndpoint = InstrumentingMiddleware(duration.With("method", "Import"))(importEndpoint)
+	}
+
+	var heartbeatEndpoint kitendpoint.Endpoint
+	{
+		heartbeatEndpoint = MakeHeartbeatEndpoint(svc)
+		// Every worker sends a heartbeat each second, so bursts are allowed.
+		heartbeatEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 100))(heartbeatEndpoint)
+		heartbeatEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(heartbeatEndpoint)
+		heartbeatEndpoint = opentracing.TraceServer(otTracer, "Heartbeat")(heartbeatEndpoint)
+		heartbeatEndpoint = LoggingMiddleware(log.With(logger, "method", "Heartbeat"))(heartbeatEndpoint)
+		heartbeatEndpoint = InstrumentingMiddleware(duration.With("method", "Heartbeat"))(heartbeatEndpoint)
+	}
+
+	var getAvailabilityEndpoint kitendpoint.Endpoint
+	{
+		getAvailabilityEndpoint = MakeGetAvailabilityEndpoint(svc)
+		getAvailabilityEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = opentracing.TraceServer(otTracer, "GetAvailability")(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = LoggingMiddleware(log.With(logger, "method", "GetAvailability"))(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = InstrumentingMiddleware(duration.With("method", "GetAvailability"))(getAvailabilityEndpoint)
+	}
+
+	var getNodeAvailabilityEndpoint kitendpoint.Endpoint
+	{
+		getNodeAvailabilityEndpoint = MakeGetNodeAvailabilityEndpoint(svc)
+		getNodeAvailabilityEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = opentracing.TraceServer(otTracer, "GetNodeAvailability")(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = LoggingMiddleware(log.With(logger, "method", "GetNodeAvailability"))(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = InstrumentingMiddleware(duration.With("method", "GetNodeAvailability"))(getNodeAvailabilityEndpoint)
+	}
+
+	var drainNodeEndpoint kitendpoint.Endpoint
+	{
+		drainNodeEndpoint = MakeDrainNodeEndpoint(svc)
+		drainNodeEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(drainNodeEndpoint)
+		drainNodeEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(drainNodeEndpoint)
+		drainNodeEndpoint = opentracing.TraceServer(otTracer, "DrainNode")(drainNodeEndpoint)
+		drainNodeEndpoint = LoggingMiddleware(log.With(logger, "method", "DrainNode"))(drainNodeEndpoint)
+		drainNodeEndpoint = InstrumentingMiddleware(duration.With("method", "DrainNode"))(drainNodeEndpoint)
+	}
+
+	var deregisterNodeEndpoint kitendpoint.Endpoint
+	{
+		deregisterNodeEndpoint = MakeDeregisterNodeEndpoint(svc)
+		deregisterNodeEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = opentracing.TraceServer(otTracer, "DeregisterNode")(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = LoggingMiddleware(log.With(logger, "method", "DeregisterNode"))(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = InstrumentingMiddleware(duration.With("method", "DeregisterNode"))(deregisterNodeEndpoint)
+	}
+
+	var listDeadLettersEndpoint kitendpoint.Endpoint
+	{
+		listDeadLettersEndpoint = MakeListDeadLettersEndpoint(svc)
+		listDeadLettersEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = opentracing.TraceServer(otTracer, "ListDeadLetters")(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = LoggingMiddleware(log.With(logger, "method", "ListDeadLetters"))(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = InstrumentingMiddleware(duration.With("method", "ListDeadLetters"))(listDeadLettersEndpoint)
+	}
+
+	var replayDeadLetterEndpoint kitendpoint.Endpoint
+	{
+		replayDeadLetterEndpoint = MakeReplayDeadLetterEndpoint(svc)
+		replayDeadLetterEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = opentracing.TraceServer(otTracer, "ReplayDeadLetter")(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = LoggingMiddleware(log.With(logger, "method", "ReplayDeadLetter"))(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = InstrumentingMiddleware(duration.With("method", "ReplayDeadLetter"))(replayDeadLetterEndpoint)
+	}
+
 	return EndpointSet{
 		RegisterNodeEndpoint: registerNodeEndpoint,
 		GetAllNodesEndpoint:  getAllNodesEndpoint,
 		NewJobEndpoint:       newJobEndpoint,
+		ExportEndpoint:       exportEndpoint,
+		ImportEndpoint:       importEndpoint,
+		HeartbeatEndpoint:    heartbeatEndpoint,
+
+		GetAvailabilityEndpoint:     getAvailabilityEndpoint,
+		GetNodeAvailabilityEndpoint: getNodeAvailabilityEndpoint,
+
+		DrainNodeEndpoint:      drainNodeEndpoint,
+		DeregisterNodeEndpoint: deregisterNodeEndpoint,
+
+		ListDeadLettersEndpoint:  listDeadLettersEndpoint,
+		ReplayDeadLetterEndpoint: replayDeadLetterEndpoint,
 	}
 }
 
@@ -72,32 +197,39 @@ func New(svc service.Service, logger log.Logger, duration metrics.Histogram, otT
 
 // RegisterNode implements the service interface, so EndpointSet may be used as a service.
 // This is primarily useful in the context of a client library.
-func (s EndpointSet) RegisterNode(ctx context.Context, name string, IP string, port string) (string, error) {
-	resp, err := s.RegisterNodeEndpoint(ctx, RegisterNodeRequest{Name: name, IP: IP, Port: port})
+func (s EndpointSet) RegisterNode(ctx context.Context, id string, name string, IP string, port string) (string, uint64, error) {
+	resp, err := s.RegisterNodeEndpoint(ctx, RegisterNodeRequest{ID: id, Name: name, IP: IP, Port: port})
 	if err != nil {
-		return "-1", err
+		return "-1", 0, err
 	}
 	response := resp.(RegisterNodeResponse)
-	return response.ID, response.Err
+	return response.ID, response.Generation, response.Err
 }
 
 // RegisterNodeRequest collects the request parameters for the RegisterNode method.
 type RegisterNodeRequest struct {
-	Name, IP, Port string
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+	Port string `json:"port"`
 }
 
 // RegisterNodeResponse collects the response values for the RegisterNode method.
 type RegisterNodeResponse struct {
-	ID  string `json:"id"`
-	Err error  `json:"-"` // should be intercepted by Failed/errorEncoder
+	ID         string `json:"id"`
+	Generation uint64 `json:"generation"`
+	Err        error  `json:"-"` // should be intercepted by Failed/errorEncoder
 }
 
+// Failed implements Failer.
+func (r RegisterNodeResponse) Failed() error { return r.Err }
+
 // MakeRegisterNodeEndpoint constructs a Sum endpoint wrapping the service.
 func MakeRegisterNodeEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(RegisterNodeRequest)
-		ID, err := s.RegisterNode(ctx, req.Name, req.IP, req.Port)
-		return RegisterNodeResponse{ID: ID, Err: err}, nil
+		ID, generation, err := s.RegisterNode(ctx, req.ID, req.Name, req.IP, req.Port)
+		return RegisterNodeResponse{ID: ID, Generation: generation, Err: err}, nil
 	}
 }
 
@@ -124,6 +256,9 @@ type GetAllNodesResponse struct {
 	Err   error       `json:"-"` // should be intercepted by Failed/errorEncoder
 }
 
+// Failed implements Failer.
+func (r GetAllNodesResponse) Failed() error { return r.Err }
+
 // MakeGetAllNodesEndpoint constructs a Sum endpoint wrapping the service.
 func MakeGetAllNodesEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -156,6 +291,9 @@ type NewJobResponse struct {
 	Err error  `json:"-"` // should be intercepted by Failed/errorEncoder
 }
 
+// Failed implements Failer.
+func (r NewJobResponse) Failed() error { return r.Err }
+
 // MakeNewJobEndpoint constructs a Sum endpoint wrapping the service.
 func MakeNewJobEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -164,3 +302,327 @@ func MakeNewJobEndpoint(s service.Service) kitendpoint.Endpoint {
 		return NewJobResponse{ID: id, Err: err}, nil
 	}
 }
+
+// ========= Export ===========
+
+// Export implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) Export(ctx context.Context) ([]byte, error) {
+	resp, err := s.ExportEndpoint(ctx, ExportRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ExportResponse)
+	return response.Data, response.Err
+}
+
+// ExportRequest collects the request parameters for the Export method.
+type ExportRequest struct {
+}
+
+// ExportResponse collects the response values for the Export method.
+type ExportResponse struct {
+	Data []byte `json:"data"`
+	Err  error  `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r ExportResponse) Failed() error { return r.Err }
+
+// MakeExportEndpoint constructs a Export endpoint wrapping the service.
+func MakeExportEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		data, err := s.Export(ctx)
+		return ExportResponse{Data: data, Err: err}, nil
+	}
+}
+
+// ========= Import ===========
+
+// Import implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) Import(ctx context.Context, data []byte) (backup.Stats, error) {
+	resp, err := s.ImportEndpoint(ctx, ImportRequest{Data: data})
+	if err != nil {
+		return backup.Stats{}, err
+	}
+	response := resp.(ImportResponse)
+	return response.Stats, response.Err
+}
+
+// ImportRequest collects the request parameters for the Import method.
+type ImportRequest struct {
+	Data []byte `json:"data"`
+}
+
+// ImportResponse collects the response values for the Import method.
+type ImportResponse struct {
+	Stats backup.Stats `json:"stats"`
+	Err   error        `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r ImportResponse) Failed() error { return r.Err }
+
+// MakeImportEndpoint constructs a Import endpoint wrapping the service.
+func MakeImportEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ImportRequest)
+		stats, err := s.Import(ctx, req.Data)
+		return ImportResponse{Stats: stats, Err: err}, nil
+	}
+}
+
+// ========= Heartbeat ===========
+
+// Heartbeat implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []repo.Job, full bool) error {
+	resp, err := s.HeartbeatEndpoint(ctx, HeartbeatRequest{NodeID: nodeID, JobsCount: jobsCount, Jobs: jobs, Full: full})
+	if err != nil {
+		return err
+	}
+	response := resp.(HeartbeatResponse)
+	return response.Err
+}
+
+// HeartbeatRequest collects the request parameters for the Heartbeat method.
+type HeartbeatRequest struct {
+	NodeID    string     `json:"node"`
+	JobsCount int        `json:"jobsCount"`
+	Jobs      []repo.Job `json:"jobs"`
+	Full      bool       `json:"full"`
+}
+
+// HeartbeatResponse collects the response values for the Heartbeat method.
+type HeartbeatResponse struct {
+	Err error `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r HeartbeatResponse) Failed() error { return r.Err }
+
+// MakeHeartbeatEndpoint constructs a Heartbeat endpoint wrapping the service.
+func MakeHeartbeatEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(HeartbeatRequest)
+		err = s.Heartbeat(ctx, req.NodeID, req.JobsCount, req.Jobs, req.Full)
+		return HeartbeatResponse{Err: err}, nil
+	}
+}
+
+// ========= GetAvailability ===========
+
+// GetAvailability implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) GetAvailability(ctx context.Context, incidents int) ([]availability.Report, error) {
+	resp, err := s.GetAvailabilityEndpoint(ctx, GetAvailabilityRequest{Incidents: incidents})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(GetAvailabilityResponse)
+	return response.Reports, response.Err
+}
+
+// GetAvailabilityRequest collects the request parameters for the GetAvailability method.
+type GetAvailabilityRequest struct {
+	Incidents int `json:"incidents"`
+}
+
+// GetAvailabilityResponse collects the response values for the GetAvailability method.
+type GetAvailabilityResponse struct {
+	Reports []availability.Report `json:"reports"`
+	Err     error                 `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r GetAvailabilityResponse) Failed() error { return r.Err }
+
+// MakeGetAvailabilityEndpoint constructs a GetAvailability endpoint wrapping the service.
+func MakeGetAvailabilityEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetAvailabilityRequest)
+		reports, err := s.GetAvailability(ctx, req.Incidents)
+		return GetAvailabilityResponse{Reports: reports, Err: err}, nil
+	}
+}
+
+// ========= GetNodeAvailability ===========
+
+// GetNodeAvailability implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (availability.Report, error) {
+	resp, err := s.GetNodeAvailabilityEndpoint(ctx, GetNodeAvailabilityRequest{NodeID: nodeID, Incidents: incidents})
+	if err != nil {
+		return availability.Report{}, err
+	}
+	response := resp.(GetNodeAvailabilityResponse)
+	return response.Report, response.Err
+}
+
+// GetNodeAvailabilityRequest collects the request parameters for the GetNodeAvailability method.
+type GetNodeAvailabilityRequest struct {
+	NodeID    string `json:"node"`
+	Incidents int    `json:"incidents"`
+}
+
+// GetNodeAvailabilityResponse collects the response values for the GetNodeAvailability method.
+type GetNodeAvailabilityResponse struct {
+	Report availability.Report `json:"report"`
+	Err    error               `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r GetNodeAvailabilityResponse) Failed() error { return r.Err }
+
+// MakeGetNodeAvailabilityEndpoint constructs a GetNodeAvailability endpoint wrapping the service.
+func MakeGetNodeAvailabilityEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetNodeAvailabilityRequest)
+		report, err := s.GetNodeAvailability(ctx, req.NodeID, req.Incidents)
+		return GetNodeAvailabilityResponse{Report: report, Err: err}, nil
+	}
+}
+
+// ========= DrainNode ===========
+
+// DrainNode implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) DrainNode(ctx context.Context, nodeID string) error {
+	resp, err := s.DrainNodeEndpoint(ctx, DrainNodeRequest{NodeID: nodeID})
+	if err != nil {
+		return err
+	}
+	response := resp.(DrainNodeResponse)
+	return response.Err
+}
+
+// DrainNodeRequest collects the request parameters for the DrainNode method.
+type DrainNodeRequest struct {
+	NodeID string `json:"node"`
+}
+
+// DrainNodeResponse collects the response values for the DrainNode method.
+type DrainNodeResponse struct {
+	Err error `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r DrainNodeResponse) Failed() error { return r.Err }
+
+// MakeDrainNodeEndpoint constructs a DrainNode endpoint wrapping the service.
+func MakeDrainNodeEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DrainNodeRequest)
+		err = s.DrainNode(ctx, req.NodeID)
+		return DrainNodeResponse{Err: err}, nil
+	}
+}
+
+// ========= DeregisterNode ===========
+
+// DeregisterNode implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) DeregisterNode(ctx context.Context, nodeID string, unfinished []repo.Job) (int, error) {
+	resp, err := s.DeregisterNodeEndpoint(ctx, DeregisterNodeRequest{NodeID: nodeID, Unfinished: unfinished})
+	if err != nil {
+		return 0, err
+	}
+	response := resp.(DeregisterNodeResponse)
+	return response.Rescheduled, response.Err
+}
+
+// DeregisterNodeRequest collects the request parameters for the DeregisterNode method.
+type DeregisterNodeRequest struct {
+	NodeID     string     `json:"node"`
+	Unfinished []repo.Job `json:"unfinished"`
+}
+
+// DeregisterNodeResponse collects the response values for the DeregisterNode method.
+type DeregisterNodeResponse struct {
+	Rescheduled int   `json:"rescheduled"`
+	Err         error `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r DeregisterNodeResponse) Failed() error { return r.Err }
+
+// MakeDeregisterNodeEndpoint constructs a DeregisterNode endpoint wrapping the service.
+func MakeDeregisterNodeEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DeregisterNodeRequest)
+		rescheduled, err := s.DeregisterNode(ctx, req.NodeID, req.Unfinished)
+		return DeregisterNodeResponse{Rescheduled: rescheduled, Err: err}, nil
+	}
+}
+
+// ========= ListDeadLetters ===========
+
+// ListDeadLetters implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) ListDeadLetters(ctx context.Context, limit int) ([]deadletter.Letter, error) {
+	resp, err := s.ListDeadLettersEndpoint(ctx, ListDeadLettersRequest{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListDeadLettersResponse)
+	return response.Letters, response.Err
+}
+
+// ListDeadLettersRequest collects the request parameters for the ListDeadLetters method.
+type ListDeadLettersRequest struct {
+	Limit int `json:"limit"`
+}
+
+// ListDeadLettersResponse collects the response values for the ListDeadLetters method.
+type ListDeadLettersResponse struct {
+	Letters []deadletter.Letter `json:"letters"`
+	Err     error               `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r ListDeadLettersResponse) Failed() error { return r.Err }
+
+// MakeListDeadLettersEndpoint constructs a ListDeadLetters endpoint wrapping the service.
+func MakeListDeadLettersEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListDeadLettersRequest)
+		letters, err := s.ListDeadLetters(ctx, req.Limit)
+		return ListDeadLettersResponse{Letters: letters, Err: err}, nil
+	}
+}
+
+// ========= ReplayDeadLetter ===========
+
+// ReplayDeadLetter implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) ReplayDeadLetter(ctx context.Context, id string) error {
+	resp, err := s.ReplayDeadLetterEndpoint(ctx, ReplayDeadLetterRequest{ID: id})
+	if err != nil {
+		return err
+	}
+	response := resp.(ReplayDeadLetterResponse)
+	return response.Err
+}
+
+// ReplayDeadLetterRequest collects the request parameters for the ReplayDeadLetter method.
+type ReplayDeadLetterRequest struct {
+	ID string `json:"id"`
+}
+
+// ReplayDeadLetterResponse collects the response values for the ReplayDeadLetter method.
+type ReplayDeadLetterResponse struct {
+	Err error `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// Failed implements Failer.
+func (r ReplayDeadLetterResponse) Failed() error { return r.Err }
+
+// MakeReplayDeadLetterEndpoint constructs a ReplayDeadLetter endpoint wrapping the service.
+func MakeReplayDeadLetterEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ReplayDeadLetterRequest)
+		err = s.ReplayDeadLetter(ctx, req.ID)
+		return ReplayDeadLetterResponse{Err: err}, nil
+	}
+}
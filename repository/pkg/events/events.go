@@ -0,0 +1,86 @@
+// Package events keeps an append-only log of everything that happens to nodes
+// and jobs in the repository. The current state, as returned by GetAllNodes,
+// is a projection of the log and can be rebuilt for any point in time.
+package events
+
+import (
+	"time"
+
+	repo "repository/pkg/model"
+)
+
+// Type is a kind of an event.
+type Type string
+
+// Event types.
+const (
+	NodeRegistered Type = "node_registered"
+	NodeUpdated    Type = "node_updated"
+	NodeRemoved    Type = "node_removed"
+	// NodeCheckFailed is a failed health check, Err tells why it failed.
+	NodeCheckFailed Type = "node_check_failed"
+	JobSubmitted    Type = "job_submitted"
+	JobScheduled    Type = "job_scheduled"
+	JobProgress     Type = "job_progress"
+	JobFinished     Type = "job_finished"
+	JobFailed       Type = "job_failed"
+	JobArchived     Type = "job_archived"
+	// JobRescheduled is a job handed back by a node which shut down and was
+	// started again, Rescheduled is the ID of the new job.
+	JobRescheduled Type = "job_rescheduled"
+)
+
+// Event is a single fact about a node or a job.
+// Node and Job hold snapshots taken at the moment of the event, Node never has jobs.
+type Event struct {
+	Seq         uint64      `json:"seq"`
+	Time        time.Time   `json:"time"`
+	Type        Type        `json:"type"`
+	Request     string      `json:"request,omitempty"` // correlates events of one NewJob call
+	NodeID      repo.NodeID `json:"node"`
+	JobID       repo.JobID  `json:"job"`
+	Node        *repo.Node  `json:"nodeSnapshot,omitempty"`
+	Job         *repo.Job   `json:"jobSnapshot,omitempty"`
+	Err         string      `json:"err,omitempty"`
+	Rescheduled *repo.JobID `json:"rescheduled,omitempty"` // the new job of a JobRescheduled job
+}
+
+// Store is an append-only storage of events.
+type Store interface {
+	// Append assigns sequence numbers to events and stores them.
+	// Time is set to now for events without it.
+	Append(events ...Event) ([]Event, error)
+	// All returns all stored events ordered by sequence numbers.
+	All() ([]Event, error)
+}
+
+// Tracker is implemented by stores which follow the state of jobs besides
+// storing events. Track passes them events of a state which is taken as it
+// is, rather than recorded: the events are not stored.
+type Tracker interface {
+	Track(events ...Event)
+}
+
+// Restorer is implemented by stores and journals which take the events of a
+// backup as they are: with their sequence numbers and times, and without
+// publishing them. Events whose sequence numbers are stored already are
+// skipped, so a backup can be restored twice. Restore returns the events it
+// wrote.
+type Restorer interface {
+	Restore(events ...Event) ([]Event, error)
+}
+
+// Journal records events produced by the repository and gives access to the history.
+type Journal interface {
+	Record(events ...Event) error
+	All() ([]Event, error)
+}
+
+// Discard is a Journal that drops all events.
+var Discard Journal = discard{}
+
+type discard struct{}
+
+func (discard) Record(...Event) error { return nil }
+
+func (discard) All() ([]Event, error) { return []Event{}, nil }
@@ -0,0 +1,157 @@
+package events
+
+import (
+	"time"
+
+	repo "repository/pkg/model"
+)
+
+// ArchivedJob is a job which is no longer reported by its node.
+type ArchivedJob struct {
+	NodeID     repo.NodeID `json:"node"`
+	Job        repo.Job    `json:"job"`
+	ArchivedAt time.Time   `json:"archivedAt"`
+}
+
+// Projection folds events into the current view of nodes and jobs.
+type Projection struct {
+	nodes    map[repo.NodeID]*repo.Node
+	order    []repo.NodeID
+	archived []ArchivedJob
+	seq      uint64
+	time     time.Time
+}
+
+// NewProjection returns an empty projection.
+func NewProjection() *Projection {
+	return &Projection{
+		nodes:    make(map[repo.NodeID]*repo.Node),
+		order:    make([]repo.NodeID, 0),
+		archived: make([]ArchivedJob, 0),
+	}
+}
+
+// Replay builds a projection from events which happened not later than at.
+// A zero at means all events.
+func Replay(events []Event, at time.Time) *Projection {
+	p := NewProjection()
+	for _, e := range events {
+		if !at.IsZero() && e.Time.After(at) {
+			break
+		}
+		p.Apply(e)
+	}
+	return p
+}
+
+// Apply changes the projection according to the event.
+func (p *Projection) Apply(e Event) {
+	p.seq = e.Seq
+	p.time = e.Time
+
+	switch e.Type {
+	case NodeRegistered, NodeUpdated:
+		if e.Node == nil {
+			return
+		}
+		n, ok := p.nodes[e.NodeID]
+		if !ok {
+			n = &repo.Node{ID: e.NodeID, Jobs: make([]repo.Job, 0)}
+			p.nodes[e.NodeID] = n
+			p.order = append(p.order, e.NodeID)
+		}
+		n.Name, n.IP, n.Port, n.JobsCount = e.Node.Name, e.Node.IP, e.Node.Port, e.Node.JobsCount
+		n.State, n.StateSince = e.Node.State, e.Node.StateSince
+		n.Generation = e.Node.Generation
+
+	case NodeRemoved:
+		n, ok := p.nodes[e.NodeID]
+		if !ok {
+			return
+		}
+		for _, j := range n.Jobs {
+			p.archived = append(p.archived, ArchivedJob{NodeID: e.NodeID, Job: j, ArchivedAt: e.Time})
+		}
+		delete(p.nodes, e.NodeID)
+		for i, id := range p.order {
+			if id == e.NodeID {
+				p.order = append(p.order[:i], p.order[i+1:]...)
+				break
+			}
+		}
+
+	case JobScheduled, JobProgress, JobFinished:
+		n, ok := p.nodes[e.NodeID]
+		if !ok {
+			return
+		}
+		j := repo.Job{ID: e.JobID, StartTime: e.Time}
+		if e.Job != nil {
+			j = *e.Job
+		}
+		for i := range n.Jobs {
+			if n.Jobs[i].ID == e.JobID {
+				n.Jobs[i] = j
+				return
+			}
+		}
+		n.Jobs = append(n.Jobs, j)
+
+	// A failed or rescheduled job won't finish on its node any more.
+	case JobArchived, JobFailed, JobRescheduled:
+		n, ok := p.nodes[e.NodeID]
+		if !ok {
+			return
+		}
+		for i, j := range n.Jobs {
+			if j.ID == e.JobID {
+				p.archived = append(p.archived, ArchivedJob{NodeID: e.NodeID, Job: j, ArchivedAt: e.Time})
+				n.Jobs = append(n.Jobs[:i], n.Jobs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Node returns a node by its ID.
+func (p *Projection) Node(id repo.NodeID) (repo.Node, bool) {
+	n, ok := p.nodes[id]
+	if !ok {
+		return repo.Node{}, false
+	}
+	return copyNode(*n), true
+}
+
+// Nodes returns the same view of nodes as service.Storage.GetAllNodes,
+// ordered by registration time.
+func (p *Projection) Nodes() []repo.Node {
+	result := make([]repo.Node, 0, len(p.order))
+	for _, id := range p.order {
+		result = append(result, copyNode(*p.nodes[id]))
+	}
+	return result
+}
+
+// Archived returns jobs archived so far, the oldest first.
+func (p *Projection) Archived() []ArchivedJob {
+	result := make([]ArchivedJob, len(p.archived))
+	copy(result, p.archived)
+	return result
+}
+
+// Seq returns a sequence number of the last applied event.
+func (p *Projection) Seq() uint64 {
+	return p.seq
+}
+
+// Time returns time of the last applied event.
+func (p *Projection) Time() time.Time {
+	return p.time
+}
+
+func copyNode(n repo.Node) repo.Node {
+	jobs := make([]repo.Job, len(n.Jobs))
+	copy(jobs, n.Jobs)
+	n.Jobs = jobs
+	return n
+}
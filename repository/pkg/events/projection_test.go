@@ -0,0 +1,92 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+)
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	node := repo.NodeID{UUID: uuid.New()}
+	jobs := make([]repo.JobID, 4)
+	for i := range jobs {
+		jobs[i] = repo.JobID{UUID: uuid.New()}
+	}
+	newJob := repo.JobID{UUID: uuid.New()}
+	log := []events.Event{
+		{Type: events.NodeRegistered, NodeID: node, Node: &repo.Node{Name: "node-1"}},
+		{Type: events.JobScheduled, NodeID: node, JobID: jobs[0]},
+		{Type: events.JobScheduled, NodeID: node, JobID: jobs[1]},
+		{Type: events.JobScheduled, NodeID: node, JobID: jobs[2]},
+		{Type: events.JobScheduled, NodeID: node, JobID: jobs[3]},
+		{Type: events.JobFinished, NodeID: node, JobID: jobs[0], Job: &repo.Job{ID: jobs[0], Per: 100}},
+		{Type: events.JobArchived, NodeID: node, JobID: jobs[1]},
+		{Type: events.JobFailed, NodeID: node, JobID: jobs[2], Err: "broken"},
+		{Type: events.JobRescheduled, NodeID: node, JobID: jobs[3], Rescheduled: &newJob},
+		{Type: events.NodeRemoved, NodeID: node},
+	}
+	for i := range log {
+		log[i].Seq = uint64(i + 1)
+		log[i].Time = start.Add(time.Duration(i) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		seq      uint64
+		jobs     []repo.JobID
+		archived []repo.JobID
+	}{
+		{"scheduled", start.Add(4 * time.Minute), 5, jobs, nil},
+		{"finished", start.Add(5 * time.Minute), 6, jobs, nil},
+		{"archived", start.Add(6 * time.Minute), 7, []repo.JobID{jobs[0], jobs[2], jobs[3]}, jobs[1:2]},
+		{"failed", start.Add(7 * time.Minute), 8, []repo.JobID{jobs[0], jobs[3]}, jobs[1:3]},
+		{"rescheduled", start.Add(8 * time.Minute), 9, jobs[:1], jobs[1:]},
+		{"removed", time.Time{}, 10, nil, []repo.JobID{jobs[1], jobs[2], jobs[3], jobs[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := events.Replay(log, tt.at)
+			if p.Seq() != tt.seq {
+				t.Errorf("want seq %d, got %d", tt.seq, p.Seq())
+			}
+
+			var got []repo.JobID
+			if n, ok := p.Node(node); ok {
+				for _, j := range n.Jobs {
+					got = append(got, j.ID)
+				}
+			} else if tt.jobs != nil {
+				t.Fatal("node is missing")
+			}
+			if !equalIDs(got, tt.jobs) {
+				t.Errorf("want jobs %v, got %v", tt.jobs, got)
+			}
+
+			got = nil
+			for _, a := range p.Archived() {
+				got = append(got, a.Job.ID)
+			}
+			if !equalIDs(got, tt.archived) {
+				t.Errorf("want archived %v, got %v", tt.archived, got)
+			}
+		})
+	}
+}
+
+func equalIDs(a, b []repo.JobID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	repo "repository/pkg/model"
+)
+
+// Storage mirrors service.Storage, the service package imports this one.
+type Storage interface {
+	NewNode(n repo.Node) (repo.NodeID, error)
+	SaveNode(n repo.Node) error
+	PatchNode(n repo.Node) error
+	GetAllNodes() ([]repo.Node, error)
+	GetNode(id repo.NodeID) (repo.Node, bool, error)
+	DeleteNode(repo.NodeID) error
+	JobsByState(state repo.JobState) ([]repo.Job, error)
+}
+
+// Recorder is a Storage middleware that turns every write into events.
+// SaveNode is compared with the projection of the log, so only changes are
+// recorded: a new job, progress of a job, its finish and its disappearance.
+// Reads are served by the next storage.
+//
+// A node the log doesn't know, because there is no log to replay or the node
+// was written by another replica, is taken into the projection as it is
+// stored before it is written for the first time. Nothing is recorded for
+// it, so a restart doesn't record and publish the whole history again.
+type Recorder struct {
+	mtx    sync.Mutex
+	next   Storage
+	store  Store
+	view   *Projection
+	logger log.Logger
+}
+
+// NewRecorder rebuilds the projection from the store and returns a Recorder
+// which writes through to the next storage.
+func NewRecorder(next Storage, store Store, logger log.Logger) (*Recorder, error) {
+	events, err := store.All()
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		next:   next,
+		store:  store,
+		view:   Replay(events, time.Time{}),
+		logger: logger,
+	}, nil
+}
+
+// Record appends events to the store and applies them to the projection.
+func (r *Recorder) Record(events ...Event) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.record(events...)
+}
+
+func (r *Recorder) record(events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	stored, err := r.store.Append(events...)
+	if err != nil {
+		r.logger.Log("events", "Record", "err", err)
+		return err
+	}
+	for _, e := range stored {
+		r.view.Apply(e)
+	}
+	return nil
+}
+
+// Restore implements Restorer for stores which do, nothing is written to
+// other stores. The projection is built again from all stored events.
+func (r *Recorder) Restore(events ...Event) ([]Event, error) {
+	rs, ok := r.store.(Restorer)
+	if !ok {
+		return []Event{}, nil
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	written, err := rs.Restore(events...)
+	if err != nil || len(written) == 0 {
+		return written, err
+	}
+	all, err := r.store.All()
+	if err != nil {
+		return written, err
+	}
+	r.view = Replay(all, time.Time{})
+	return written, nil
+}
+
+// All returns all recorded events.
+func (r *Recorder) All() ([]Event, error) {
+	return r.store.All()
+}
+
+// Projection returns the current view of nodes built from the events.
+func (r *Recorder) Projection() []repo.Node {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.view.Nodes()
+}
+
+func (r *Recorder) NewNode(n repo.Node) (repo.NodeID, error) {
+	id, err := r.next.NewNode(n)
+	if err != nil {
+		return id, err
+	}
+
+	n.ID = id
+	n.JobsCount = 0
+	n.Jobs = nil
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return id, r.record(Event{Type: NodeRegistered, NodeID: id, Node: &n})
+}
+
+func (r *Recorder) SaveNode(n repo.Node) error {
+	if err := r.seed(n.ID); err != nil {
+		return err
+	}
+	if err := r.next.SaveNode(n); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	prev, _ := r.view.Node(n.ID)
+	return r.record(diff(prev, n)...)
+}
+
+// PatchNode records changes of the patched jobs only, other jobs of the node
+// are taken from the projection.
+func (r *Recorder) PatchNode(n repo.Node) error {
+	if err := r.seed(n.ID); err != nil {
+		return err
+	}
+	if err := r.next.PatchNode(n); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	prev, _ := r.view.Node(n.ID)
+	n.Jobs = repo.MergeJobs(prev.Jobs, n.Jobs)
+	return r.record(diff(prev, n)...)
+}
+
+func (r *Recorder) GetAllNodes() ([]repo.Node, error) {
+	return r.next.GetAllNodes()
+}
+
+func (r *Recorder) GetNode(id repo.NodeID) (repo.Node, bool, error) {
+	return r.next.GetNode(id)
+}
+
+func (r *Recorder) JobsByState(state repo.JobState) ([]repo.Job, error) {
+	return r.next.JobsByState(state)
+}
+
+func (r *Recorder) DeleteNode(id repo.NodeID) error {
+	if err := r.seed(id); err != nil {
+		return err
+	}
+	if err := r.next.DeleteNode(id); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.record(Event{Type: NodeRemoved, NodeID: id})
+}
+
+// seed takes a node the projection doesn't know from the next storage. The
+// events which build it are applied to the projection and tracked by the
+// store, but neither stored nor published.
+func (r *Recorder) seed(id repo.NodeID) error {
+	r.mtx.Lock()
+	_, ok := r.view.Node(id)
+	r.mtx.Unlock()
+	if ok {
+		return nil
+	}
+
+	n, ok, err := r.next.GetNode(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.view.Node(id); ok {
+		return nil
+	}
+	seq, now := r.view.Seq(), time.Now().UTC()
+	seeds := diff(repo.Node{}, n)
+	for i := range seeds {
+		seeds[i].Seq, seeds[i].Time = seq, now
+		r.view.Apply(seeds[i])
+	}
+	if t, ok := r.store.(Tracker); ok {
+		t.Track(seeds...)
+	}
+	r.logger.Log("events", "seed", "node", id, "jobs", len(n.Jobs))
+	return nil
+}
+
+// diff returns events which turn prev into next.
+func diff(prev, next repo.Node) []Event {
+	result := make([]Event, 0)
+
+	if prev.Name != next.Name || prev.IP != next.IP || prev.Port != next.Port || prev.JobsCount != next.JobsCount || prev.State != next.State || prev.Generation != next.Generation {
+		n := next
+		n.Jobs = nil
+		t := NodeUpdated
+		if prev.ID != next.ID {
+			t = NodeRegistered
+		}
+		result = append(result, Event{Type: t, NodeID: next.ID, Node: &n})
+	}
+
+	known := make(map[repo.JobID]repo.Job)
+	for _, j := range prev.Jobs {
+		known[j.ID] = j
+	}
+
+	for _, j := range next.Jobs {
+		j := j
+		old, ok := known[j.ID]
+		delete(known, j.ID)
+		switch {
+		case !ok:
+			result = append(result, Event{Type: JobScheduled, NodeID: next.ID, JobID: j.ID, Job: &j})
+			if j.State() == repo.JobFinished {
+				result = append(result, Event{Type: JobFinished, NodeID: next.ID, JobID: j.ID, Job: &j})
+			}
+		case old.Per == j.Per:
+		case j.State() == repo.JobFinished:
+			result = append(result, Event{Type: JobFinished, NodeID: next.ID, JobID: j.ID, Job: &j})
+		default:
+			result = append(result, Event{Type: JobProgress, NodeID: next.ID, JobID: j.ID, Job: &j})
+		}
+	}
+
+	for _, j := range prev.Jobs {
+		if _, ok := known[j.ID]; ok {
+			result = append(result, Event{Type: JobArchived, NodeID: next.ID, JobID: j.ID})
+		}
+	}
+
+	return result
+}
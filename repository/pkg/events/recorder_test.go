@@ -0,0 +1,126 @@
+package events_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+	"repository/pkg/storage/inmem"
+)
+
+func TestRecorder(t *testing.T) {
+	store := events.NewMemStore()
+	r, err := events.NewRecorder(inmem.New(), store, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := r.NewNode(repo.Node{Name: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := repo.Job{ID: repo.JobID{UUID: uuid.New()}}, repo.Job{ID: repo.JobID{UUID: uuid.New()}}
+	with := func(jobs ...repo.Job) repo.Node {
+		return repo.Node{ID: id, Name: "node-1", JobsCount: len(jobs), Jobs: jobs}
+	}
+	progress := func(j repo.Job, per float32) repo.Job {
+		j.Per = per
+		return j
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		want  []events.Type
+	}{
+		{"new jobs", func() error { return r.SaveNode(with(a, b)) }, []events.Type{events.NodeUpdated, events.JobScheduled, events.JobScheduled}},
+		{"unchanged", func() error { return r.SaveNode(with(a, b)) }, nil},
+		{"progress", func() error { return r.SaveNode(with(progress(a, 50), b)) }, []events.Type{events.JobProgress}},
+		{"patch", func() error {
+			return r.PatchNode(repo.Node{ID: id, Name: "node-1", JobsCount: 2, Jobs: []repo.Job{progress(b, 100)}})
+		}, []events.Type{events.JobFinished}},
+		{"disappeared", func() error { return r.SaveNode(with(progress(b, 100))) }, []events.Type{events.NodeUpdated, events.JobArchived}},
+		{"deleted", func() error { return r.DeleteNode(id) }, []events.Type{events.NodeRemoved}},
+	}
+	for _, tt := range tests {
+		before, _ := store.All()
+		if err := tt.write(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		after, _ := store.All()
+		var got []events.Type
+		for _, e := range after[len(before):] {
+			got = append(got, e.Type)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	if nodes := r.Projection(); len(nodes) != 0 {
+		t.Errorf("want no nodes, got %+v", nodes)
+	}
+}
+
+// A node another replica wrote is taken as it is stored, only changes made
+// from then on are recorded.
+func TestRecorderSeed(t *testing.T) {
+	storage := inmem.New()
+	id, err := storage.NewNode(repo.Node{Name: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := repo.Job{ID: repo.JobID{UUID: uuid.New()}, Per: 10}
+	n := repo.Node{ID: id, Name: "node-1", JobsCount: 1, Jobs: []repo.Job{job}}
+	if err := storage.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+
+	store := events.NewMemStore()
+	r, err := events.NewRecorder(storage, store, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Per = 20
+	n.Jobs = []repo.Job{job}
+	if err := r.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+
+	all, _ := store.All()
+	if len(all) != 1 || all[0].Type != events.JobProgress {
+		t.Errorf("want only the progress recorded, got %+v", all)
+	}
+}
+
+// Restored events rebuild the projection, so the nodes they describe are
+// not recorded again when they are saved.
+func TestRecorderRestore(t *testing.T) {
+	store := events.NewMemStore()
+	r, err := events.NewRecorder(inmem.New(), store, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := repo.NodeID{UUID: uuid.New()}
+	n := repo.Node{ID: id, Name: "node-1"}
+	written, err := r.Restore(events.Event{Seq: 7, Type: events.NodeRegistered, NodeID: id, Node: &n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 {
+		t.Fatalf("want 1 event written, got %d", len(written))
+	}
+	if nodes := r.Projection(); len(nodes) != 1 || nodes[0].ID != id {
+		t.Errorf("want node %s in the projection, got %+v", id, nodes)
+	}
+
+	if err := r.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := store.All()
+	if len(all) != 1 || all[0].Seq != 7 {
+		t.Errorf("want only the restored event, got %+v", all)
+	}
+}
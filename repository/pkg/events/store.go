@@ -0,0 +1,240 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemStore keeps events in memory.
+type MemStore struct {
+	mtx    sync.RWMutex
+	events []Event
+}
+
+// NewMemStore returns an empty in memory store.
+func NewMemStore() *MemStore {
+	return &MemStore{events: make([]Event, 0)}
+}
+
+func (ms *MemStore) Append(events ...Event) ([]Event, error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	events = stamp(lastSeq(ms.events), events)
+	ms.events = append(ms.events, events...)
+	return events, nil
+}
+
+// Restore implements Restorer, events are kept ordered by sequence numbers.
+func (ms *MemStore) Restore(events ...Event) ([]Event, error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	written := missing(ms.events, events)
+	ms.events = append(ms.events, written...)
+	sortBySeq(ms.events)
+	return written, nil
+}
+
+func (ms *MemStore) All() ([]Event, error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	result := make([]Event, len(ms.events))
+	copy(result, ms.events)
+	return result, nil
+}
+
+// NopStore numbers events without keeping them. It lets the recorder produce
+// events for the stream when there is no event log.
+type NopStore struct {
+	mtx sync.Mutex
+	seq uint64
+}
+
+// NewNopStore returns a store which keeps no events. Having no last event to
+// continue after, it numbers events from the time it was created, in
+// microseconds, so sequence numbers still grow across restarts.
+func NewNopStore() *NopStore {
+	return &NopStore{seq: uint64(time.Now().UnixNano() / int64(time.Microsecond))}
+}
+
+func (ns *NopStore) Append(events ...Event) ([]Event, error) {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+
+	events = stamp(ns.seq, events)
+	ns.seq = lastSeq(events)
+	return events, nil
+}
+
+// Restore implements Restorer, a store which keeps no events writes none.
+func (ns *NopStore) Restore(events ...Event) ([]Event, error) {
+	return []Event{}, nil
+}
+
+func (ns *NopStore) All() ([]Event, error) {
+	return []Event{}, nil
+}
+
+// FileStore keeps events in a file, one JSON object per line.
+// Every Append is synced to disk before it returns.
+type FileStore struct {
+	mtx  sync.Mutex
+	f    *os.File
+	path string
+	seq  uint64
+}
+
+// NewFileStore opens or creates an event log at the path.
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fs := &FileStore{f: f, path: path}
+
+	events, err := fs.All()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fs.seq = lastSeq(events)
+
+	return fs, nil
+}
+
+func (fs *FileStore) Append(events ...Event) ([]Event, error) {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	events = stamp(fs.seq, events)
+
+	w := bufio.NewWriter(fs.f)
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := fs.f.Sync(); err != nil {
+		return nil, err
+	}
+
+	fs.seq = lastSeq(events)
+	return events, nil
+}
+
+// Restore implements Restorer, restored events are appended to the log and
+// later events are numbered after all of them.
+func (fs *FileStore) Restore(events ...Event) ([]Event, error) {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	stored, err := fs.All()
+	if err != nil {
+		return nil, err
+	}
+	written := missing(stored, events)
+
+	w := bufio.NewWriter(fs.f)
+	enc := json.NewEncoder(w)
+	for _, e := range written {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := fs.f.Sync(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range written {
+		if e.Seq > fs.seq {
+			fs.seq = e.Seq
+		}
+	}
+	return written, nil
+}
+
+// All reads the whole log ordered by sequence numbers, restored events may
+// have been appended after later ones. A partially written last line, left
+// by a crash in the middle of Append, is ignored.
+func (fs *FileStore) All() ([]Event, error) {
+	f, err := os.Open(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make([]Event, 0)
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			break
+		}
+		result = append(result, e)
+	}
+	sortBySeq(result)
+	return result, s.Err()
+}
+
+// Close closes the log file.
+func (fs *FileStore) Close() error {
+	return fs.f.Close()
+}
+
+func lastSeq(events []Event) uint64 {
+	if len(events) == 0 {
+		return 0
+	}
+	return events[len(events)-1].Seq
+}
+
+// stamp numbers events after seq and sets their time.
+func stamp(seq uint64, events []Event) []Event {
+	result := make([]Event, len(events))
+	now := time.Now().UTC()
+	for i, e := range events {
+		seq++
+		e.Seq = seq
+		if e.Time.IsZero() {
+			e.Time = now
+		}
+		result[i] = e
+	}
+	return result
+}
+
+// missing returns the events whose sequence numbers are neither stored nor
+// repeated earlier in events.
+func missing(stored, events []Event) []Event {
+	seen := make(map[uint64]bool, len(stored))
+	for _, e := range stored {
+		seen[e.Seq] = true
+	}
+	result := make([]Event, 0, len(events))
+	for _, e := range events {
+		if seen[e.Seq] {
+			continue
+		}
+		seen[e.Seq] = true
+		result = append(result, e)
+	}
+	return result
+}
+
+func sortBySeq(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+}
@@ -0,0 +1,222 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	repo "repository/pkg/model"
+)
+
+// Names of events on the stream, the last token of their subjects.
+const (
+	StreamRegistered  = "registered"
+	StreamUpdated     = "updated"
+	StreamRemoved     = "removed"
+	StreamCheckFailed = "check_failed"
+	StreamCreated     = "created"
+	StreamScheduled   = "scheduled"
+	StreamProgress    = "progress"
+	StreamFinished    = "finished"
+	StreamFailed      = "failed"
+	StreamCancelled   = "cancelled"
+	StreamArchived    = "archived"
+	StreamRescheduled = "rescheduled"
+)
+
+// Publisher sends an encoded event on a subject.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Stream is a Store middleware which publishes every stored event for
+// systems outside the repository. Node events go to nodes.<node>.<event>,
+// job events to jobs.<job>.<event>, where a job which has no ID yet, because
+// it was only submitted or failed to start, is named by the request ID.
+//
+// A job is scheduled once: the repository learning about a job it started
+// itself is not published again. A job handed back by a node which shut down
+// is rescheduled, the event names the new job which replaces it. Progress of a job is published at most
+// once per progress interval. Jobs which disappear or whose node is removed
+// before they finish are cancelled.
+type Stream struct {
+	next     Store
+	pub      Publisher
+	progress time.Duration
+	logger   log.Logger
+
+	mtx sync.Mutex
+	// open are the jobs which didn't finish, with their nodes.
+	open map[repo.JobID]repo.NodeID
+	// reported is when the progress of an open job was last published.
+	reported map[repo.JobID]time.Time
+}
+
+// NewStream returns a Stream which appends events to the next store and
+// publishes them. Jobs which are still open are taken from the stored events.
+func NewStream(next Store, pub Publisher, progress time.Duration, logger log.Logger) (*Stream, error) {
+	s := &Stream{
+		next:     next,
+		pub:      pub,
+		progress: progress,
+		logger:   logger,
+		open:     make(map[repo.JobID]repo.NodeID),
+		reported: make(map[repo.JobID]time.Time),
+	}
+
+	events, err := next.All()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		s.track(e)
+	}
+	return s, nil
+}
+
+// Append implements Store, events are published once they are stored.
+func (s *Stream) Append(events ...Event) ([]Event, error) {
+	stored, err := s.next.Append(events...)
+	if err != nil {
+		return stored, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, e := range stored {
+		s.publish(e)
+	}
+	return stored, nil
+}
+
+// All implements Store.
+func (s *Stream) All() ([]Event, error) {
+	return s.next.All()
+}
+
+// Restore implements Restorer, restored events are history and aren't
+// published. Open jobs are taken from all stored events again.
+func (s *Stream) Restore(events ...Event) ([]Event, error) {
+	r, ok := s.next.(Restorer)
+	if !ok {
+		return []Event{}, nil
+	}
+	written, err := r.Restore(events...)
+	if err != nil || len(written) == 0 {
+		return written, err
+	}
+	all, err := s.next.All()
+	if err != nil {
+		return written, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.open = make(map[repo.JobID]repo.NodeID)
+	s.reported = make(map[repo.JobID]time.Time)
+	for _, e := range all {
+		s.track(e)
+	}
+	return written, nil
+}
+
+// Track implements Tracker, jobs which are still open are remembered without
+// publishing anything.
+func (s *Stream) Track(events ...Event) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, e := range events {
+		s.track(e)
+	}
+}
+
+func (s *Stream) publish(e Event) {
+	switch e.Type {
+	case NodeRegistered:
+		s.send(nodeSubject(e.NodeID, StreamRegistered), e)
+	case NodeUpdated:
+		s.send(nodeSubject(e.NodeID, StreamUpdated), e)
+	case NodeCheckFailed:
+		s.send(nodeSubject(e.NodeID, StreamCheckFailed), e)
+	case NodeRemoved:
+		for job, node := range s.open {
+			if node == e.NodeID {
+				s.send(jobSubject(e, job, StreamCancelled), Event{Seq: e.Seq, Time: e.Time, Type: JobArchived, NodeID: node, JobID: job})
+			}
+		}
+		s.send(nodeSubject(e.NodeID, StreamRemoved), e)
+	case JobSubmitted:
+		s.send(jobSubject(e, e.JobID, StreamCreated), e)
+	case JobScheduled:
+		if _, ok := s.open[e.JobID]; !ok {
+			s.send(jobSubject(e, e.JobID, StreamScheduled), e)
+		}
+	case JobProgress:
+		if last, ok := s.reported[e.JobID]; !ok || e.Time.Sub(last) >= s.progress {
+			s.reported[e.JobID] = e.Time
+			s.send(jobSubject(e, e.JobID, StreamProgress), e)
+		}
+	case JobFinished:
+		s.send(jobSubject(e, e.JobID, StreamFinished), e)
+	case JobFailed:
+		s.send(jobSubject(e, e.JobID, StreamFailed), e)
+	case JobRescheduled:
+		s.send(jobSubject(e, e.JobID, StreamRescheduled), e)
+	case JobArchived:
+		if _, ok := s.open[e.JobID]; ok {
+			s.send(jobSubject(e, e.JobID, StreamCancelled), e)
+		} else {
+			s.send(jobSubject(e, e.JobID, StreamArchived), e)
+		}
+	}
+	s.track(e)
+}
+
+// track keeps the open jobs up to date with the event.
+func (s *Stream) track(e Event) {
+	switch e.Type {
+	case NodeRemoved:
+		for job, node := range s.open {
+			if node == e.NodeID {
+				s.close(job)
+			}
+		}
+	case JobScheduled, JobProgress:
+		if e.Job == nil || e.Job.State() != repo.JobFinished {
+			s.open[e.JobID] = e.NodeID
+		}
+	case JobFinished, JobArchived, JobFailed, JobRescheduled:
+		s.close(e.JobID)
+	}
+}
+
+func (s *Stream) close(job repo.JobID) {
+	delete(s.open, job)
+	delete(s.reported, job)
+}
+
+// send publishes the event, the event is stored already, so a failure is
+// only logged.
+func (s *Stream) send(subject string, e Event) {
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = s.pub.Publish(subject, data)
+	}
+	if err != nil {
+		s.logger.Log("events", "Stream", "subject", subject, "err", err)
+	}
+}
+
+func nodeSubject(id repo.NodeID, name string) string {
+	return "nodes." + id.String() + "." + name
+}
+
+func jobSubject(e Event, id repo.JobID, name string) string {
+	key := id.String()
+	if id == (repo.JobID{}) && e.Request != "" {
+		key = e.Request
+	}
+	return "jobs." + key + "." + name
+}
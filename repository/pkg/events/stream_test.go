@@ -0,0 +1,110 @@
+package events_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+
+	"repository/pkg/events"
+	repo "repository/pkg/model"
+)
+
+func TestStream(t *testing.T) {
+	start := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	node := repo.NodeID{UUID: uuid.New()}
+	job, handedBack, newJob := repo.JobID{UUID: uuid.New()}, repo.JobID{UUID: uuid.New()}, repo.JobID{UUID: uuid.New()}
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	nodes, jobs := "nodes."+node.String()+".", "jobs."+job.String()+"."
+
+	tests := []struct {
+		name  string
+		event events.Event
+		want  []string
+	}{
+		{"registered", events.Event{Type: events.NodeRegistered, NodeID: node, Node: &repo.Node{}}, []string{nodes + "registered"}},
+		{"created", events.Event{Type: events.JobSubmitted, Request: "req-1"}, []string{"jobs.req-1.created"}},
+		{"scheduled", events.Event{Type: events.JobScheduled, Request: "req-1", NodeID: node, JobID: job}, []string{jobs + "scheduled"}},
+		{"scheduled again", events.Event{Type: events.JobScheduled, NodeID: node, JobID: job}, nil},
+		{"progress", events.Event{Type: events.JobProgress, NodeID: node, JobID: job, Time: at(time.Second)}, []string{jobs + "progress"}},
+		{"progress too soon", events.Event{Type: events.JobProgress, NodeID: node, JobID: job, Time: at(time.Second + time.Millisecond)}, nil},
+		{"progress later", events.Event{Type: events.JobProgress, NodeID: node, JobID: job, Time: at(2 * time.Second)}, []string{jobs + "progress"}},
+		{"finished", events.Event{Type: events.JobFinished, NodeID: node, JobID: job}, []string{jobs + "finished"}},
+		{"failed to start", events.Event{Type: events.JobFailed, Request: "req-2", NodeID: node, Err: "broken"}, []string{"jobs.req-2.failed"}},
+		{"handed back", events.Event{Type: events.JobScheduled, NodeID: node, JobID: handedBack}, []string{"jobs." + handedBack.String() + ".scheduled"}},
+		{"check failed", events.Event{Type: events.NodeCheckFailed, NodeID: node, Err: "timeout"}, []string{nodes + "check_failed"}},
+		{"removed", events.Event{Type: events.NodeRemoved, NodeID: node}, []string{"jobs." + handedBack.String() + ".cancelled", nodes + "removed"}},
+		{"rescheduled", events.Event{Type: events.JobRescheduled, NodeID: node, JobID: handedBack, Rescheduled: &newJob}, []string{"jobs." + handedBack.String() + ".rescheduled"}},
+	}
+
+	pub := &publisher{}
+	s, err := events.NewStream(events.NewMemStore(), pub, time.Second, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		pub.subjects = nil
+		if _, err := s.Append(tt.event); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pub.subjects, tt.want) {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, pub.subjects)
+		}
+	}
+}
+
+// Restored events are history: nothing is published, but jobs they leave
+// open are cancelled with their node.
+func TestStreamRestore(t *testing.T) {
+	node, job := repo.NodeID{UUID: uuid.New()}, repo.JobID{UUID: uuid.New()}
+	pub := &publisher{}
+	s, err := events.NewStream(events.NewMemStore(), pub, time.Second, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := []events.Event{
+		{Seq: 1, Type: events.NodeRegistered, NodeID: node, Node: &repo.Node{}},
+		{Seq: 2, Type: events.JobScheduled, NodeID: node, JobID: job},
+	}
+	for i, want := range []int{2, 0} {
+		written, err := s.Restore(history...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(written) != want {
+			t.Errorf("restore %d: want %d events written, got %d", i+1, want, len(written))
+		}
+	}
+	if pub.subjects != nil {
+		t.Errorf("want nothing published, got %v", pub.subjects)
+	}
+
+	stored, err := s.Append(events.Event{Type: events.NodeRemoved, NodeID: node})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].Seq != 3 {
+		t.Errorf("want seq 3, got %d", stored[0].Seq)
+	}
+	want := []string{"jobs." + job.String() + ".cancelled", "nodes." + node.String() + ".removed"}
+	if !reflect.DeepEqual(pub.subjects, want) {
+		t.Errorf("want %v, got %v", want, pub.subjects)
+	}
+}
+
+// publisher remembers subjects of published events.
+type publisher struct {
+	mtx      sync.Mutex
+	subjects []string
+}
+
+func (p *publisher) Publish(subject string, data []byte) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.subjects = append(p.subjects, subject)
+	return nil
+}
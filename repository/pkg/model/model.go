@@ -6,15 +6,64 @@ import (
 	"github.com/google/uuid"
 )
 
-
 // Node represents an executer instance machine
 type Node struct {
-	ID        NodeID `json:"id"`
-	Name      string `json:"name"`
-	IP        string `json:"ip"`
-	Port      string `json:"port"`
-	JobsCount int    `json:"jobscount"`
-	Jobs      []Job  `json:"jobs"`
+	ID         NodeID    `json:"id"`
+	Name       string    `json:"name"`
+	IP         string    `json:"ip"`
+	Port       string    `json:"port"`
+	JobsCount  int       `json:"jobscount"`
+	Jobs       []Job     `json:"jobs"`
+	State      NodeState `json:"state,omitempty"`
+	StateSince time.Time `json:"stateSince,omitempty"`
+	// Generation counts registrations of the node, a worker which registers
+	// again under the same ID starts a new generation.
+	Generation uint64 `json:"generation,omitempty"`
+}
+
+// NodeState is a result of health checks of a node.
+type NodeState string
+
+// Node states. A node is Healthy until a check fails, then it is Suspect.
+// Enough failed checks in a row make it Unhealthy, enough successful ones
+// bring it back to Healthy. A node which stays Unhealthy for too long is
+// Removed: it is no longer checked and is deleted from the storage later.
+// A node which is shutting down is Draining: it finishes its jobs but gets no
+// new ones, and deregisters itself at the end.
+const (
+	NodeHealthy   NodeState = "healthy"
+	NodeSuspect   NodeState = "suspect"
+	NodeUnhealthy NodeState = "unhealthy"
+	NodeRemoved   NodeState = "removed"
+	NodeDraining  NodeState = "draining"
+)
+
+// Available tells whether jobs can be scheduled on the node.
+// Nodes stored before health states were introduced have no state and are
+// treated as healthy.
+func (n Node) Available() bool {
+	return n.State == NodeHealthy || n.State == ""
+}
+
+// MergeJobs replaces known jobs with changed ones and appends new ones.
+// Neither slice is modified.
+func MergeJobs(jobs, changed []Job) []Job {
+	result := make([]Job, len(jobs))
+	copy(result, jobs)
+
+	index := make(map[JobID]int)
+	for i, j := range result {
+		index[j.ID] = i
+	}
+	for _, j := range changed {
+		if i, ok := index[j.ID]; ok {
+			result[i] = j
+			continue
+		}
+		index[j.ID] = len(result)
+		result = append(result, j)
+	}
+	return result
 }
 
 // NodeID is a ID of particular node
@@ -33,3 +82,20 @@ type Job struct {
 	StartTime  time.Time `json:"startTime"`
 	FinishTime time.Time `json:"finishTime"`
 }
+
+// JobState describes a stage of a job's life.
+type JobState string
+
+// Job states.
+const (
+	JobRunning  JobState = "running"
+	JobFinished JobState = "finished"
+)
+
+// State returns a current state of the job.
+func (j Job) State() JobState {
+	if j.Per >= 100 {
+		return JobFinished
+	}
+	return JobRunning
+}
@@ -0,0 +1,288 @@
+// Package natsconn keeps a connection to NATS for the repository and the
+// apiserver. Workers keep their own copy of it.
+//
+// The first connection is retried until it succeeds. Afterwards the client
+// reconnects on its own: subscriptions are restored by it and publishes are
+// buffered while it reconnects, up to the reconnect buffer. Should the client
+// give up on a connection, a new one is made and every subscription is made
+// again on it. The state of the connection is followed through the
+// disconnect, reconnect and closed handlers of the client.
+package natsconn
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/nats-io/go-nats"
+)
+
+// ErrNotConnected is returned while there is no connection to NATS. Calls
+// made while the client reconnects don't fail with it, their publishes are
+// buffered.
+var ErrNotConnected = errors.New("no connection to NATS")
+
+// Config tunes the connection.
+type Config struct {
+	// Name identifies the client to the NATS server.
+	Name string
+	// ReconnectWait is the pause between attempts to connect.
+	ReconnectWait time.Duration
+	// ReconnectBufSize is how many bytes of publishes are buffered while the
+	// client reconnects.
+	ReconnectBufSize int
+}
+
+// DefaultConfig is used for zero fields of a Config.
+var DefaultConfig = Config{
+	ReconnectWait:    time.Second,
+	ReconnectBufSize: nats.DefaultReconnectBufSize,
+}
+
+// Metrics of the connection, nil ones are discarded.
+type Metrics struct {
+	// Connected is 1 while the client is connected and 0 otherwise.
+	Connected metrics.Gauge
+	// Disconnects counts lost connections.
+	Disconnects metrics.Counter
+	// Reconnects counts connections restored by the client.
+	Reconnects metrics.Counter
+}
+
+// Handler returns the handler of messages received on a connection. Replies
+// are sent on the connection, it changes when the client gives up on one. The
+// ServeMsg method of a go-kit NATS subscriber is a Handler.
+type Handler func(nc *nats.Conn) func(msg *nats.Msg)
+
+// Serve returns a Handler for a handler which doesn't reply.
+func Serve(h nats.MsgHandler) Handler {
+	return func(*nats.Conn) func(*nats.Msg) { return h }
+}
+
+type subscription struct {
+	subject string
+	queue   string
+	handler Handler
+}
+
+// Manager keeps a connection to a NATS server and the subscriptions made on
+// it.
+type Manager struct {
+	url     string
+	cfg     Config
+	metrics Metrics
+	logger  log.Logger
+
+	connected int32 // 1 while nc is connected, read by Check
+
+	mtx  sync.RWMutex
+	nc   *nats.Conn
+	subs []subscription
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a manager which connects to the NATS server at url in the
+// background.
+func New(url string, cfg Config, m Metrics, logger log.Logger) *Manager {
+	if cfg.ReconnectWait <= 0 {
+		cfg.ReconnectWait = DefaultConfig.ReconnectWait
+	}
+	if cfg.ReconnectBufSize <= 0 {
+		cfg.ReconnectBufSize = DefaultConfig.ReconnectBufSize
+	}
+	if m.Connected == nil {
+		m.Connected = discard.NewGauge()
+	}
+	if m.Disconnects == nil {
+		m.Disconnects = discard.NewCounter()
+	}
+	if m.Reconnects == nil {
+		m.Reconnects = discard.NewCounter()
+	}
+	mgr := &Manager{
+		url:     url,
+		cfg:     cfg,
+		metrics: m,
+		logger:  log.With(logger, "transport", "NATS"),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	m.Connected.Set(0)
+	go mgr.run()
+	return mgr
+}
+
+// Subscribe receives messages on the subject with the handler, in the queue
+// group unless it is empty. The subscription is made on the current
+// connection and on every later one.
+func (m *Manager) Subscribe(subject, queue string, h Handler) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	s := subscription{subject: subject, queue: queue, handler: h}
+	m.subs = append(m.subs, s)
+	if m.nc == nil {
+		return nil
+	}
+	return subscribe(m.nc, s)
+}
+
+// Conn returns the current connection, nil if there is none yet. It is for
+// subscriptions which the caller makes again itself once they are no longer
+// valid.
+func (m *Manager) Conn() *nats.Conn {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.nc
+}
+
+// Publish sends data on the subject.
+func (m *Manager) Publish(subject string, data []byte) error {
+	nc := m.Conn()
+	if nc == nil {
+		return ErrNotConnected
+	}
+	return nc.Publish(subject, data)
+}
+
+// PublishRequest sends data on the subject, replies go to the reply subject.
+func (m *Manager) PublishRequest(subject, reply string, data []byte) error {
+	nc := m.Conn()
+	if nc == nil {
+		return ErrNotConnected
+	}
+	return nc.PublishRequest(subject, reply, data)
+}
+
+// Request sends data on the subject and waits for a reply for up to timeout.
+func (m *Manager) Request(subject string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	nc := m.Conn()
+	if nc == nil {
+		return nil, ErrNotConnected
+	}
+	return nc.Request(subject, data, timeout)
+}
+
+// Check tells whether the client is connected.
+func (m *Manager) Check() error {
+	if atomic.LoadInt32(&m.connected) == 0 {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Close closes the connection and stops connecting.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+	})
+}
+
+// run keeps a connection until the manager is closed.
+func (m *Manager) run() {
+	defer close(m.done)
+	for {
+		nc, closed, err := m.connect()
+		if err != nil {
+			m.logger.Log("url", m.url, "err", err)
+			select {
+			case <-time.After(m.cfg.ReconnectWait):
+				continue
+			case <-m.stop:
+				return
+			}
+		}
+
+		select {
+		case <-closed:
+			// The client gave up, the subscriptions went with the connection.
+			m.logger.Log("url", m.url, "message", "connection closed, connecting again", "err", nc.LastError())
+			m.mtx.Lock()
+			m.nc = nil
+			m.mtx.Unlock()
+		case <-m.stop:
+			m.mtx.Lock()
+			m.nc = nil
+			m.mtx.Unlock()
+			nc.Close()
+			m.setConnected(false)
+			return
+		}
+	}
+}
+
+// connect makes a new connection and the subscriptions on it. The returned
+// channel is closed once the client gives up on the connection.
+func (m *Manager) connect() (*nats.Conn, chan struct{}, error) {
+	closed := make(chan struct{})
+	nc, err := nats.Connect(m.url,
+		nats.Name(m.cfg.Name),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(m.cfg.ReconnectWait),
+		nats.ReconnectBufSize(m.cfg.ReconnectBufSize),
+		nats.DisconnectHandler(func(nc *nats.Conn) {
+			if m.setConnected(false) {
+				m.metrics.Disconnects.Add(1)
+				m.logger.Log("url", m.url, "message", "disconnected", "err", nc.LastError())
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			m.setConnected(true)
+			m.metrics.Reconnects.Add(1)
+			m.logger.Log("url", nc.ConnectedUrl(), "message", "reconnected")
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			m.setConnected(false)
+			close(closed)
+		}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mtx.Lock()
+	for _, s := range m.subs {
+		if err := subscribe(nc, s); err != nil {
+			m.mtx.Unlock()
+			nc.Close()
+			return nil, nil, err
+		}
+	}
+	m.nc = nc
+	subs := len(m.subs)
+	m.mtx.Unlock()
+
+	m.setConnected(true)
+	m.logger.Log("url", nc.ConnectedUrl(), "message", "connected", "subscriptions", subs)
+	return nc, closed, nil
+}
+
+// setConnected updates the state of the connection, it tells whether the
+// state changed.
+func (m *Manager) setConnected(connected bool) bool {
+	var v int32
+	if connected {
+		v = 1
+	}
+	if atomic.SwapInt32(&m.connected, v) == v {
+		return false
+	}
+	m.metrics.Connected.Set(float64(v))
+	return true
+}
+
+func subscribe(nc *nats.Conn, s subscription) error {
+	if s.queue == "" {
+		_, err := nc.Subscribe(s.subject, s.handler(nc))
+		return err
+	}
+	_, err := nc.QueueSubscribe(s.subject, s.queue, s.handler(nc))
+	return err
+}
@@ -0,0 +1,141 @@
+// Package natsserver runs a NATS server inside the repository.
+//
+// Small installations and local development don't need a NATS server of
+// their own: the repository serves NATS to itself, to the workers and to the
+// apiserver. The server keeps no state besides JetStream, which is enabled
+// when a store directory is given.
+package natsserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// ErrNotReady is returned when the server doesn't accept connections in time.
+var ErrNotReady = errors.New("natsserver: server is not ready for connections")
+
+// Config of an embedded server.
+type Config struct {
+	// Name of the server, shown to clients and in its monitoring.
+	Name string
+	// Addr is the address the server listens on for clients, port -1 picks
+	// a random one.
+	Addr string
+	// Advertise is the address clients are told to reach the server at, it
+	// defaults to the listen address.
+	Advertise string
+	// StoreDir keeps the streams of JetStream, JetStream is disabled if empty.
+	StoreDir string
+	// Debug logs the protocol of the server.
+	Debug bool
+	// ReadyTimeout is how long Start waits for the server to accept
+	// connections.
+	ReadyTimeout time.Duration
+}
+
+// DefaultReadyTimeout is used when Config.ReadyTimeout is zero.
+const DefaultReadyTimeout = 10 * time.Second
+
+// Server is a running NATS server.
+type Server struct {
+	ns     *server.Server
+	cfg    Config
+	logger log.Logger
+}
+
+// Start starts a server and waits until it accepts connections.
+func Start(cfg Config, logger log.Logger) (*Server, error) {
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("natsserver: bad port %q", port)
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	if cfg.ReadyTimeout <= 0 {
+		cfg.ReadyTimeout = DefaultReadyTimeout
+	}
+
+	opts := &server.Options{
+		ServerName:      cfg.Name,
+		Host:            host,
+		Port:            p,
+		ClientAdvertise: cfg.Advertise,
+		JetStream:       cfg.StoreDir != "",
+		StoreDir:        cfg.StoreDir,
+		// Signals are handled by the repository, which shuts the server
+		// down on its way out.
+		NoSigs: true,
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		return nil, err
+	}
+	logger = log.With(logger, "component", "nats-server")
+	ns.SetLogger(serverLogger{logger}, cfg.Debug, false)
+
+	go ns.Start()
+	if !ns.ReadyForConnections(cfg.ReadyTimeout) {
+		ns.Shutdown()
+		return nil, ErrNotReady
+	}
+	s := &Server{ns: ns, cfg: cfg, logger: logger}
+	logger.Log("addr", cfg.Addr, "advertise", s.Advertised(), "jetstream", opts.JetStream, "message", "embedded NATS server is ready")
+	return s, nil
+}
+
+// ClientURL returns the URL the repository itself connects to, over the
+// loopback interface if the server listens on all of them.
+func (s *Server) ClientURL() string {
+	addr, ok := s.ns.Addr().(*net.TCPAddr)
+	if !ok {
+		return s.ns.ClientURL()
+	}
+	host := addr.IP.String()
+	if addr.IP.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "nats://" + net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
+
+// Advertised returns the URL workers and the apiserver connect to.
+func (s *Server) Advertised() string {
+	if s.cfg.Advertise == "" {
+		return s.ClientURL()
+	}
+	return "nats://" + s.cfg.Advertise
+}
+
+// Shutdown closes the connections of all clients and stops the server.
+func (s *Server) Shutdown() {
+	s.ns.Shutdown()
+	s.ns.WaitForShutdown()
+	s.logger.Log("message", "embedded NATS server stopped")
+}
+
+// serverLogger passes the log of the server to a Go kit logger, warnings
+// and errors under the err key.
+type serverLogger struct {
+	logger log.Logger
+}
+
+func (l serverLogger) Noticef(format string, v ...interface{}) { l.logf("message", format, v) }
+func (l serverLogger) Warnf(format string, v ...interface{})   { l.logf("err", format, v) }
+func (l serverLogger) Fatalf(format string, v ...interface{})  { l.logf("err", format, v) }
+func (l serverLogger) Errorf(format string, v ...interface{})  { l.logf("err", format, v) }
+func (l serverLogger) Debugf(format string, v ...interface{})  { l.logf("message", format, v) }
+func (l serverLogger) Tracef(format string, v ...interface{})  { l.logf("message", format, v) }
+
+func (l serverLogger) logf(key, format string, v []interface{}) {
+	l.logger.Log(key, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"repository/pkg/availability"
+	"repository/pkg/model"
+)
+
+// GetAvailability returns availability of all nodes known to the history,
+// including recently deleted ones, with at most incidents last incidents each.
+func (r Repo) GetAvailability(ctx context.Context, incidents int) ([]availability.Report, error) {
+	return r.history.Reports(incidents, time.Now().UTC()), nil
+}
+
+// GetNodeAvailability returns availability of a single node.
+func (r Repo) GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (availability.Report, error) {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		return availability.Report{}, ErrUnknownNode
+	}
+	report, ok := r.history.Report(model.NodeID{UUID: id}, incidents, time.Now().UTC())
+	if !ok {
+		return availability.Report{}, ErrUnknownNode
+	}
+	return report, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"repository/pkg/deadletter"
+)
+
+// ErrNoDeadLetters shows that the repository keeps no dead letters.
+var ErrNoDeadLetters = errors.New("dead letters are not kept")
+
+// ListDeadLetters returns up to limit NATS messages which failed, the newest
+// first, or all of them if limit isn't positive.
+func (r Repo) ListDeadLetters(ctx context.Context, limit int) ([]deadletter.Letter, error) {
+	if r.letters == nil {
+		return nil, ErrNoDeadLetters
+	}
+	return r.letters.List(limit), nil
+}
+
+// ReplayDeadLetter sends the message of a dead letter again.
+func (r Repo) ReplayDeadLetter(ctx context.Context, id string) error {
+	if r.letters == nil {
+		return ErrNoDeadLetters
+	}
+	return r.letters.Replay(id)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"repository/pkg/events"
+	"repository/pkg/model"
+)
+
+// rescheduleAttempts is how many times a job handed back by a node is tried
+// to be started again, workers limit how often NewJob is called.
+const rescheduleAttempts = 5
+
+// rescheduleTimeout bounds starting again all the jobs handed back by a node.
+const rescheduleTimeout = time.Minute
+
+// DrainNode marks a node which is shutting down Draining. It gets no new jobs
+// while it finishes the running ones.
+func (r Repo) DrainNode(ctx context.Context, nodeID string) error {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		return ErrUnknownNode
+	}
+	unlock := r.locks.lock(model.NodeID{UUID: id})
+	defer unlock()
+
+	n, err := r.knownNode(nodeID)
+	if err != nil {
+		return err
+	}
+	if n.State == model.NodeDraining {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	n.State = model.NodeDraining
+	n.StateSince = now
+	n.Jobs = nil // other jobs of the node are kept
+	if err := r.s.PatchNode(n); err != nil {
+		return err
+	}
+	r.history.StateChanged(n.ID, model.NodeDraining, now)
+	return nil
+}
+
+// DeregisterNode deletes a node which shuts down. The jobs it didn't finish
+// are handed back and started again on other nodes in the background, so the
+// worker doesn't wait for them; it returns how many were handed back. Every
+// job started again is recorded as JobRescheduled with the ID of the new job,
+// one which can't be started as JobFailed.
+func (r Repo) DeregisterNode(ctx context.Context, nodeID string, unfinished []model.Job) (int, error) {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		return 0, ErrUnknownNode
+	}
+	unlock := r.locks.lock(model.NodeID{UUID: id})
+	defer unlock()
+
+	n, err := r.knownNode(nodeID)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.deleteNode("DeregisterNode", n.ID); err != nil {
+		return 0, err
+	}
+	if len(unfinished) > 0 {
+		go r.rescheduleAll(n.ID, unfinished)
+	}
+	return len(unfinished), nil
+}
+
+// rescheduleAll starts the jobs handed back by a node again, one by one.
+func (r Repo) rescheduleAll(nodeID model.NodeID, jobs []model.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), rescheduleTimeout)
+	defer cancel()
+
+	for _, j := range jobs {
+		id, err := r.reschedule(ctx)
+		if err != nil {
+			r.logger.Log("method", "DeregisterNode", "node", nodeID.String(), "job", j.ID.String(), "err", err)
+			r.journal.Record(events.Event{Type: events.JobFailed, NodeID: nodeID, JobID: j.ID, Err: err.Error()})
+			continue
+		}
+		r.logger.Log("method", "DeregisterNode", "node", nodeID.String(), "job", j.ID.String(), "rescheduled", id)
+		newID, _ := uuid.Parse(id)
+		r.journal.Record(events.Event{Type: events.JobRescheduled, NodeID: nodeID, JobID: j.ID, Rescheduled: &model.JobID{UUID: newID}})
+	}
+}
+
+// reschedule starts a new job, it retries with a growing pause while the
+// context allows.
+func (r Repo) reschedule(ctx context.Context) (string, error) {
+	var err error
+	for attempt := 1; attempt <= rescheduleAttempts; attempt++ {
+		var id string
+		if id, err = r.NewJob(ctx); err == nil {
+			return id, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+	return "", err
+}
+
+// knownNode returns a stored node by its ID, ErrUnknownNode if there is none.
+func (r Repo) knownNode(nodeID string) (model.Node, error) {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		return model.Node{}, ErrUnknownNode
+	}
+	n, ok, err := r.s.GetNode(model.NodeID{UUID: id})
+	switch {
+	case err != nil:
+		return model.Node{}, err
+	case !ok:
+		return model.Node{}, ErrUnknownNode
+	}
+	return n, nil
+}
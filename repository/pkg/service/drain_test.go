@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+
+	"repository/pkg/availability"
+	"repository/pkg/connpool"
+	"repository/pkg/dispatch"
+	"repository/pkg/events"
+	"repository/pkg/model"
+	"repository/pkg/storage/inmem"
+)
+
+func TestDrainNode(t *testing.T) {
+	r, _ := testRepo(&dispatcher{})
+	job := model.Job{ID: model.JobID{UUID: uuid.New()}, Per: 10}
+	id := addNode(t, r, job)
+
+	for i := 0; i < 2; i++ {
+		if err := r.DrainNode(context.Background(), id.String()); err != nil {
+			t.Fatal(err)
+		}
+		n, _, _ := r.s.GetNode(id)
+		if n.State != model.NodeDraining || len(n.Jobs) != 1 {
+			t.Errorf("drain %d: want the node draining with its job, got %+v", i+1, n)
+		}
+	}
+
+	for _, nodeID := range []string{"node-1", uuid.New().String()} {
+		if err := r.DrainNode(context.Background(), nodeID); err != ErrUnknownNode {
+			t.Errorf("%s: want %v, got %v", nodeID, ErrUnknownNode, err)
+		}
+	}
+}
+
+// The node is deleted right away, the jobs it hands back are started again
+// in the background.
+func TestDeregisterNode(t *testing.T) {
+	d := &dispatcher{}
+	r, journal := testRepo(d)
+	jobs := []model.Job{
+		{ID: model.JobID{UUID: uuid.New()}, Per: 10},
+		{ID: model.JobID{UUID: uuid.New()}, Per: 20},
+	}
+	id := addNode(t, r, jobs...)
+
+	n, err := r.DeregisterNode(context.Background(), id.String(), jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(jobs) {
+		t.Errorf("want %d jobs handed back, got %d", len(jobs), n)
+	}
+	if _, ok, _ := r.s.GetNode(id); ok {
+		t.Error("want the node deleted")
+	}
+	if _, err := r.DeregisterNode(context.Background(), id.String(), nil); err != ErrUnknownNode {
+		t.Errorf("want %v, got %v", ErrUnknownNode, err)
+	}
+
+	rescheduled := journal.wait(t, events.JobRescheduled, id, len(jobs))
+	queued := d.queued()
+	for i, e := range rescheduled {
+		if e.NodeID != id || e.JobID != jobs[i].ID {
+			t.Errorf("want job %s of node %s, got %+v", jobs[i].ID, id, e)
+		}
+		if e.Rescheduled == nil || e.Rescheduled.String() != queued[i] {
+			t.Errorf("want new job %s, got %v", queued[i], e.Rescheduled)
+		}
+	}
+}
+
+// A job is tried to be started again a few times, then it fails.
+func TestReschedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     events.Type
+		calls    int
+	}{
+		{"first", 0, events.JobRescheduled, 1},
+		{"retried", 2, events.JobRescheduled, 3},
+		{"failed", rescheduleAttempts, events.JobFailed, rescheduleAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &dispatcher{failures: tt.failures}
+			r, journal := testRepo(d)
+			job := model.Job{ID: model.JobID{UUID: uuid.New()}, Per: 10}
+			id := addNode(t, r, job)
+
+			if _, err := r.DeregisterNode(context.Background(), id.String(), []model.Job{job}); err != nil {
+				t.Fatal(err)
+			}
+			e := journal.wait(t, tt.want, id, 1)[0]
+			if e.JobID != job.ID {
+				t.Errorf("want job %s, got %s", job.ID, e.JobID)
+			}
+			if calls := d.count(); calls != tt.calls {
+				t.Errorf("want %d attempts, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+// testRepo returns a repository which queues new jobs to the dispatcher.
+func testRepo(d Dispatcher) (Repo, *journal) {
+	j := &journal{}
+	return Repo{
+		s:          inmem.New(),
+		journal:    j,
+		tracker:    newHealthTracker(),
+		locks:      newNodeLocks(),
+		pool:       connpool.New(connpool.DefaultConfig, connpool.DiscardMetrics, log.NewNopLogger()),
+		dispatcher: d,
+		history:    availability.New(10, time.Hour),
+		logger:     log.NewNopLogger(),
+	}, j
+}
+
+func addNode(t *testing.T, r Repo, jobs ...model.Job) model.NodeID {
+	t.Helper()
+	id, err := r.s.NewNode(model.Node{Name: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := model.Node{ID: id, Name: "node-1", State: model.NodeHealthy, Generation: 1, JobsCount: len(jobs), Jobs: jobs}
+	if err := r.s.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// journal keeps recorded events.
+type journal struct {
+	mtx    sync.Mutex
+	events []events.Event
+}
+
+func (j *journal) Record(evs ...events.Event) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.events = append(j.events, evs...)
+	return nil
+}
+
+func (j *journal) All() ([]events.Event, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return append([]events.Event(nil), j.events...), nil
+}
+
+// wait returns the first n recorded events of the type and node once there
+// are n.
+func (j *journal) wait(t *testing.T, typ events.Type, node model.NodeID, n int) []events.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		all, _ := j.All()
+		var result []events.Event
+		for _, e := range all {
+			if e.Type == typ && e.NodeID == node {
+				result = append(result, e)
+			}
+		}
+		if len(result) >= n {
+			return result[:n]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d %s events, got %+v", n, typ, all)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// dispatcher queues jobs after failing the given number of times.
+type dispatcher struct {
+	mtx      sync.Mutex
+	failures int
+	calls    int
+	jobs     []string
+}
+
+func (d *dispatcher) Dispatch(ctx context.Context, jobID, request string) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.calls++
+	if d.calls <= d.failures {
+		return errors.New("queue is full")
+	}
+	d.jobs = append(d.jobs, jobID)
+	return nil
+}
+
+func (d *dispatcher) Pending() ([]dispatch.Job, error) {
+	return nil, nil
+}
+
+func (d *dispatcher) Restore(jobs ...dispatch.Job) (int, error) {
+	return 0, nil
+}
+
+func (d *dispatcher) count() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.calls
+}
+
+func (d *dispatcher) queued() []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return append([]string(nil), d.jobs...)
+}
@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/google/uuid"
+
+	"repository/pkg/events"
+	"repository/pkg/model"
+)
+
+// HealthPolicy configures how nodes are checked by CheckNodes.
+type HealthPolicy struct {
+	// Interval between two checks of a node.
+	Interval time.Duration
+	// Timeout of a single check, including the dial.
+	Timeout time.Duration
+	// UnhealthyThreshold is a number of failed checks in a row which make a node Unhealthy.
+	UnhealthyThreshold int
+	// HealthyThreshold is a number of successful checks in a row which make
+	// a Suspect or Unhealthy node Healthy again.
+	HealthyThreshold int
+	// RemoveAfter is how long a node stays Unhealthy before it is Removed,
+	// and how long a Removed node stays visible before it is deleted.
+	RemoveAfter time.Duration
+	// Concurrency is a maximum number of nodes checked at the same time.
+	Concurrency int
+	// SweepTimeout limits a sweep over all nodes. Nodes which are not checked
+	// before the deadline are checked in the next sweep.
+	SweepTimeout time.Duration
+}
+
+// DefaultHealthPolicy tolerates short network blips of a few seconds.
+var DefaultHealthPolicy = HealthPolicy{
+	Interval:           1 * time.Second,
+	Timeout:            1 * time.Second,
+	UnhealthyThreshold: 3,
+	HealthyThreshold:   2,
+	RemoveAfter:        1 * time.Minute,
+	Concurrency:        16,
+	SweepTimeout:       5 * time.Second,
+}
+
+// SweepMetrics describe sweeps of CheckNodes. Duration is a duration of a
+// sweep, Lag is how late the last sweep started, both in seconds. Jobs is the
+// number of jobs of all nodes by the "state" label, counted after a sweep.
+type SweepMetrics struct {
+	Duration metrics.Histogram
+	Lag      metrics.Gauge
+	Jobs     metrics.Gauge
+}
+
+// healthCounts counts consecutive results of checks of a node.
+type healthCounts struct {
+	failures  int
+	successes int
+	lastBeat  time.Time // zero for nodes which don't send heartbeats
+	cursor    string    // jobs of the node are stored up to the cursor
+}
+
+// healthTracker keeps counters of all checked nodes. Counters live in memory
+// only, after a restart nodes keep their stored state and counting starts over.
+type healthTracker struct {
+	mtx    sync.Mutex
+	counts map[model.NodeID]*healthCounts
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{counts: make(map[model.NodeID]*healthCounts)}
+}
+
+// observe records a result of a check and returns the new state of the node.
+func (t *healthTracker) observe(p HealthPolicy, n model.Node, ok bool, now time.Time) model.NodeState {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	c := t.get(n.ID)
+
+	state := n.State
+	if state == "" {
+		state = model.NodeHealthy
+	}
+
+	if ok {
+		c.failures = 0
+		c.successes++
+		if state == model.NodeDraining {
+			return state
+		}
+		if state != model.NodeHealthy && c.successes >= p.HealthyThreshold {
+			return model.NodeHealthy
+		}
+		return state
+	}
+
+	c.successes = 0
+	c.failures++
+	switch state {
+	case model.NodeHealthy, model.NodeSuspect, model.NodeDraining:
+		if c.failures >= p.UnhealthyThreshold {
+			return model.NodeUnhealthy
+		}
+		return model.NodeSuspect
+	case model.NodeUnhealthy:
+		if now.Sub(n.StateSince) >= p.RemoveAfter {
+			return model.NodeRemoved
+		}
+	}
+	return state
+}
+
+// beat records a heartbeat of the node.
+func (t *healthTracker) beat(id model.NodeID, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.get(id).lastBeat = now
+}
+
+// lastBeat returns time of the last heartbeat of the node.
+func (t *healthTracker) lastBeat(id model.NodeID) time.Time {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.get(id).lastBeat
+}
+
+// cursor returns the GetJobsSince cursor of the node, empty if jobs of the
+// node weren't fetched yet.
+func (t *healthTracker) cursor(id model.NodeID) string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.get(id).cursor
+}
+
+// setCursor remembers the cursor after the jobs it covers are stored.
+func (t *healthTracker) setCursor(id model.NodeID, cursor string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.get(id).cursor = cursor
+}
+
+func (t *healthTracker) get(id model.NodeID) *healthCounts {
+	c, ok := t.counts[id]
+	if !ok {
+		c = &healthCounts{}
+		t.counts[id] = c
+	}
+	return c
+}
+
+// reset drops counters of all nodes.
+func (t *healthTracker) reset() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.counts = make(map[model.NodeID]*healthCounts)
+}
+
+// forget drops counters of a deleted node.
+func (t *healthTracker) forget(id model.NodeID) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.counts, id)
+}
+
+// countJobs sets the Jobs gauge to the number of jobs of all nodes by state.
+func (r Repo) countJobs() {
+	for _, state := range []model.JobState{model.JobRunning, model.JobFinished} {
+		jobs, err := r.s.JobsByState(state)
+		if err != nil {
+			r.logger.Log("method", "CheckNodes", "state", string(state), "err", err)
+			continue
+		}
+		r.sweepMetrics.Jobs.With("state", string(state)).Set(float64(len(jobs)))
+	}
+}
+
+// sweep checks all nodes, at most Concurrency of them at the same time.
+func (r Repo) sweep(start time.Time) {
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(r.health.SweepTimeout))
+	defer cancel()
+
+	nodes, err := r.s.GetAllNodes()
+	if err != nil {
+		r.logger.Log("method", "CheckNodes", "err", err)
+		return
+	}
+
+	concurrency := r.health.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	ids := make([]model.NodeID, 0, len(nodes))
+	skipped := 0
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+		r.history.Track(n, start)
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			skipped++
+			continue
+		}
+		wg.Add(1)
+		go func(n model.Node) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			r.checkNode(ctx, n)
+		}(n)
+	}
+	wg.Wait()
+
+	if skipped > 0 {
+		r.logger.Log("method", "CheckNodes", "skipped", skipped, "err", ctx.Err())
+	}
+	// Nodes might have been deleted by somebody else.
+	r.pool.Retain(ids)
+}
+
+// checkNode checks the node once and stores the result.
+func (r Repo) checkNode(ctx context.Context, n model.Node) {
+	now := time.Now().UTC()
+
+	if n.State == model.NodeRemoved {
+		if now.Sub(n.StateSince) >= r.health.RemoveAfter {
+			// A node registered again meanwhile is kept.
+			unlock := r.locks.lock(n.ID)
+			defer unlock()
+			if fresh, ok, err := r.s.GetNode(n.ID); err == nil && ok && fresh.Generation == n.Generation && fresh.State == model.NodeRemoved {
+				r.deleteNode("CheckNodes", n.ID)
+			}
+		}
+		return
+	}
+
+	var (
+		jobsCount int
+		delta     jobsDelta
+		probed    bool
+		err       error
+	)
+	if last := r.tracker.lastBeat(n.ID); !last.IsZero() {
+		// The node reports itself with heartbeats, it is only checked that
+		// they keep coming.
+		if now.Sub(last) <= r.health.Interval+r.health.Timeout {
+			return
+		}
+		err = ErrNoHeartbeat
+	} else {
+		checkCtx, cancel := context.WithTimeout(ctx, r.health.Timeout)
+		defer cancel()
+		jobsCount, delta, err = r.probe(checkCtx, n, r.tracker.cursor(n.ID))
+		if err != nil && ctx.Err() != nil {
+			// The sweep ran out of time, that says nothing about the node.
+			return
+		}
+		probed = err == nil
+	}
+
+	// The node is read again under its lock, a heartbeat, a drain or a new
+	// registration stored while it was probed is not overwritten.
+	unlock := r.locks.lock(n.ID)
+	defer unlock()
+	if err == ErrNoHeartbeat && time.Since(r.tracker.lastBeat(n.ID)) <= r.health.Interval+r.health.Timeout {
+		return
+	}
+	fresh, ok, gerr := r.s.GetNode(n.ID)
+	switch {
+	case gerr != nil:
+		r.logger.Log("method", "CheckNodes", "node", n.ID.String(), "err", gerr)
+		return
+	case !ok || fresh.Generation != n.Generation:
+		return
+	}
+	n = fresh
+
+	if err != nil {
+		r.logger.Log("method", "CheckNodes", "node", n.ID.String(), "err", err)
+	}
+
+	state := r.tracker.observe(r.health, n, err == nil, now)
+	changed := state != n.State
+	if changed {
+		r.logger.Log("method", "CheckNodes", "node", n.ID.String(), "state", state, "was", n.State)
+		n.State = state
+		n.StateSince = now
+		r.history.StateChanged(n.ID, state, now)
+	}
+	if err != nil {
+		r.history.CheckFailed(n.ID, err.Error(), now)
+		// The failure is logged after the new state of the node is stored,
+		// so a replay of the log opens the incident first.
+		defer r.journal.Record(events.Event{Type: events.NodeCheckFailed, Time: now, NodeID: n.ID, Err: err.Error()})
+	}
+	if probed {
+		changed = changed || jobsCount != n.JobsCount || len(delta.jobs) > 0
+		n.JobsCount = jobsCount
+	}
+
+	switch {
+	case probed && delta.full:
+		n.Jobs = delta.jobs
+		err = r.s.SaveNode(n)
+	case changed:
+		// Only the changed jobs are written, others stay as they are stored.
+		n.Jobs = delta.jobs
+		err = r.s.PatchNode(n)
+	default:
+		return
+	}
+	if err != nil {
+		r.logger.Log("method", "CheckNodes", "node", n.ID.String(), "err", err)
+		return
+	}
+	if probed {
+		r.tracker.setCursor(n.ID, delta.cursor)
+	}
+}
+
+// Heartbeat is a successful check of the node reported by the node itself.
+// It updates the number of running jobs and the jobs, which are either all
+// jobs of the node or only the changed ones. Changed jobs are patched, the
+// rest of the stored jobs is left untouched.
+func (r Repo) Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []model.Job, full bool) error {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		return ErrUnknownNode
+	}
+	unlock := r.locks.lock(model.NodeID{UUID: id})
+	defer unlock()
+
+	n, ok, err := r.s.GetNode(model.NodeID{UUID: id})
+	switch {
+	case err != nil:
+		return err
+	case !ok || n.State == model.NodeRemoved:
+		// The worker has to register again.
+		return ErrUnknownNode
+	}
+
+	now := time.Now().UTC()
+	r.tracker.beat(n.ID, now)
+	if state := r.tracker.observe(r.health, n, true, now); state != n.State {
+		r.logger.Log("method", "Heartbeat", "node", n.ID.String(), "state", state, "was", n.State)
+		n.State = state
+		n.StateSince = now
+		r.history.StateChanged(n.ID, state, now)
+	}
+
+	n.JobsCount = jobsCount
+	n.Jobs = jobs
+	if full {
+		return r.s.SaveNode(n)
+	}
+	return r.s.PatchNode(n)
+}
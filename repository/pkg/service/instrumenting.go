@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/deadletter"
 	repo "repository/pkg/model"
 
 	"github.com/go-kit/kit/metrics"
@@ -31,10 +35,10 @@ type instrumentingMiddleware struct {
 	next          Service
 }
 
-func (mw instrumentingMiddleware) RegisterNode(ctx context.Context, name string, IP string, port string) (string, error) {
-	id, err := mw.next.RegisterNode(ctx, name, IP, port)
+func (mw instrumentingMiddleware) RegisterNode(ctx context.Context, nodeID string, name string, IP string, port string) (string, uint64, error) {
+	id, generation, err := mw.next.RegisterNode(ctx, nodeID, name, IP, port)
 	mw.registerNodes.Add(1)
-	return id, err
+	return id, generation, err
 }
 
 func (mw instrumentingMiddleware) GetAllNodes(ctx context.Context) ([]repo.Node, error) {
@@ -48,3 +52,39 @@ func (mw instrumentingMiddleware) NewJob(ctx context.Context) (string, error) {
 	mw.newJobs.Add(1)
 	return id, err
 }
+
+func (mw instrumentingMiddleware) Export(ctx context.Context) ([]byte, error) {
+	return mw.next.Export(ctx)
+}
+
+func (mw instrumentingMiddleware) Import(ctx context.Context, data []byte) (backup.Stats, error) {
+	return mw.next.Import(ctx, data)
+}
+
+func (mw instrumentingMiddleware) Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []repo.Job, full bool) error {
+	return mw.next.Heartbeat(ctx, nodeID, jobsCount, jobs, full)
+}
+
+func (mw instrumentingMiddleware) GetAvailability(ctx context.Context, incidents int) ([]availability.Report, error) {
+	return mw.next.GetAvailability(ctx, incidents)
+}
+
+func (mw instrumentingMiddleware) GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (availability.Report, error) {
+	return mw.next.GetNodeAvailability(ctx, nodeID, incidents)
+}
+
+func (mw instrumentingMiddleware) DrainNode(ctx context.Context, nodeID string) error {
+	return mw.next.DrainNode(ctx, nodeID)
+}
+
+func (mw instrumentingMiddleware) DeregisterNode(ctx context.Context, nodeID string, unfinished []repo.Job) (int, error) {
+	return mw.next.DeregisterNode(ctx, nodeID, unfinished)
+}
+
+func (mw instrumentingMiddleware) ListDeadLetters(ctx context.Context, limit int) ([]deadletter.Letter, error) {
+	return mw.next.ListDeadLetters(ctx, limit)
+}
+
+func (mw instrumentingMiddleware) ReplayDeadLetter(ctx context.Context, id string) error {
+	return mw.next.ReplayDeadLetter(ctx, id)
+}
@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/deadletter"
 	repo "repository/pkg/model"
 
 	"github.com/go-kit/kit/log"
@@ -20,11 +24,11 @@ type loggingMiddleware struct {
 	next   Service
 }
 
-func (mw loggingMiddleware) RegisterNode(ctx context.Context, name string, ip string, port string) (id string, err error) {
+func (mw loggingMiddleware) RegisterNode(ctx context.Context, nodeID string, name string, ip string, port string) (id string, generation uint64, err error) {
 	defer func() {
-		mw.logger.Log("method", "registerNode", "id", id, "name", name, "ip", ip, "port", port, "err", err)
+		mw.logger.Log("method", "registerNode", "id", id, "generation", generation, "name", name, "ip", ip, "port", port, "err", err)
 	}()
-	return mw.next.RegisterNode(ctx, name, ip, port)
+	return mw.next.RegisterNode(ctx, nodeID, name, ip, port)
 }
 
 func (mw loggingMiddleware) GetAllNodes(ctx context.Context) (nodes []repo.Node, err error) {
@@ -40,3 +44,69 @@ func (mw loggingMiddleware) NewJob(ctx context.Context) (ID string, err error) {
 	}()
 	return mw.next.NewJob(ctx)
 }
+
+func (mw loggingMiddleware) Export(ctx context.Context) (data []byte, err error) {
+	defer func() {
+		mw.logger.Log("method", "export", "len(data)", len(data), "err", err)
+	}()
+	return mw.next.Export(ctx)
+}
+
+func (mw loggingMiddleware) Import(ctx context.Context, data []byte) (stats backup.Stats, err error) {
+	defer func() {
+		mw.logger.Log("method", "import", "nodes", stats.Nodes, "jobs", stats.Jobs, "events", stats.Events, "err", err)
+	}()
+	return mw.next.Import(ctx, data)
+}
+
+// Heartbeat is logged on errors only, every worker sends one each second.
+func (mw loggingMiddleware) Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []repo.Job, full bool) (err error) {
+	defer func() {
+		if err != nil {
+			mw.logger.Log("method", "heartbeat", "node", nodeID, "jobsCount", jobsCount, "len(jobs)", len(jobs), "full", full, "err", err)
+		}
+	}()
+	return mw.next.Heartbeat(ctx, nodeID, jobsCount, jobs, full)
+}
+
+func (mw loggingMiddleware) GetAvailability(ctx context.Context, incidents int) (reports []availability.Report, err error) {
+	defer func() {
+		mw.logger.Log("method", "getAvailability", "incidents", incidents, "len(reports)", len(reports), "err", err)
+	}()
+	return mw.next.GetAvailability(ctx, incidents)
+}
+
+func (mw loggingMiddleware) GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (report availability.Report, err error) {
+	defer func() {
+		mw.logger.Log("method", "getNodeAvailability", "node", nodeID, "incidents", incidents, "err", err)
+	}()
+	return mw.next.GetNodeAvailability(ctx, nodeID, incidents)
+}
+
+func (mw loggingMiddleware) DrainNode(ctx context.Context, nodeID string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "drainNode", "node", nodeID, "err", err)
+	}()
+	return mw.next.DrainNode(ctx, nodeID)
+}
+
+func (mw loggingMiddleware) DeregisterNode(ctx context.Context, nodeID string, unfinished []repo.Job) (rescheduled int, err error) {
+	defer func() {
+		mw.logger.Log("method", "deregisterNode", "node", nodeID, "len(unfinished)", len(unfinished), "rescheduled", rescheduled, "err", err)
+	}()
+	return mw.next.DeregisterNode(ctx, nodeID, unfinished)
+}
+
+func (mw loggingMiddleware) ListDeadLetters(ctx context.Context, limit int) (letters []deadletter.Letter, err error) {
+	defer func() {
+		mw.logger.Log("method", "listDeadLetters", "limit", limit, "len(letters)", len(letters), "err", err)
+	}()
+	return mw.next.ListDeadLetters(ctx, limit)
+}
+
+func (mw loggingMiddleware) ReplayDeadLetter(ctx context.Context, id string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "replayDeadLetter", "letter", id, "err", err)
+	}()
+	return mw.next.ReplayDeadLetter(ctx, id)
+}
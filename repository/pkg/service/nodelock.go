@@ -0,0 +1,49 @@
+package service
+
+import (
+	"sync"
+
+	"repository/pkg/model"
+)
+
+// nodeLocks serializes changes of a node. Heartbeats, checks, drains and
+// registrations all read a node, change it and store it again; without the
+// lock one of them could store a state it read before another one stored
+// its own, a heartbeat could turn a Draining node Healthy. Writes of all
+// replicas are served by the leader, so a lock in memory is enough.
+type nodeLocks struct {
+	mtx   sync.Mutex
+	locks map[model.NodeID]*nodeLock
+}
+
+type nodeLock struct {
+	sync.Mutex
+	refs int // holders and waiters, the lock is dropped at zero
+}
+
+func newNodeLocks() *nodeLocks {
+	return &nodeLocks{locks: make(map[model.NodeID]*nodeLock)}
+}
+
+// lock locks the node and returns a function which unlocks it.
+func (l *nodeLocks) lock(id model.NodeID) func() {
+	l.mtx.Lock()
+	nl, ok := l.locks[id]
+	if !ok {
+		nl = &nodeLock{}
+		l.locks[id] = nl
+	}
+	nl.refs++
+	l.mtx.Unlock()
+
+	nl.Lock()
+	return func() {
+		nl.Unlock()
+
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		if nl.refs--; nl.refs == 0 {
+			delete(l.locks, id)
+		}
+	}
+}
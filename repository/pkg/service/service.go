@@ -1,39 +1,160 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
-	stdopentracing "github.com/opentracing/opentracing-go"
-	"google.golang.org/grpc"
+	"github.com/go-kit/kit/metrics/discard"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
-	workertransport "worker/pkg/transport"
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/connpool"
+	"repository/pkg/deadletter"
+	"repository/pkg/events"
 	"repository/pkg/model"
+	workermodel "worker/pkg/model"
 )
 
+// workerHealthService is the name of the worker service in grpc.health.v1.
+const workerHealthService = "pb.worker.Worker"
+
 // Service describes a service that represents repository.
 type Service interface {
-	RegisterNode(ctx context.Context, name string, IP string, port string) (string, error)
+	RegisterNode(ctx context.Context, id string, name string, IP string, port string) (string, uint64, error)
 	GetAllNodes(ctx context.Context) ([]model.Node, error)
 	NewJob(ctx context.Context) (string, error)
+	Export(ctx context.Context) ([]byte, error)
+	Import(ctx context.Context, data []byte) (backup.Stats, error)
+	Heartbeat(ctx context.Context, nodeID string, jobsCount int, jobs []model.Job, full bool) error
+	GetAvailability(ctx context.Context, incidents int) ([]availability.Report, error)
+	GetNodeAvailability(ctx context.Context, nodeID string, incidents int) (availability.Report, error)
+	DrainNode(ctx context.Context, nodeID string) error
+	DeregisterNode(ctx context.Context, nodeID string, unfinished []model.Job) (int, error)
+	ListDeadLetters(ctx context.Context, limit int) ([]deadletter.Letter, error)
+	ReplayDeadLetter(ctx context.Context, id string) error
 }
 
 // Storage stores nodes
 type Storage interface {
 	NewNode(n model.Node) (model.NodeID, error)
 	SaveNode(n model.Node) error
+	// PatchNode stores the node and upserts only n.Jobs, other jobs of the
+	// node are kept.
+	PatchNode(n model.Node) error
 	GetAllNodes() ([]model.Node, error)
-	DeleteNode(model.NodeID)
+	// GetNode returns a node with its jobs, ok is false if there is none.
+	GetNode(id model.NodeID) (n model.Node, ok bool, err error)
+	DeleteNode(model.NodeID) error
+	// JobsByState returns jobs of all nodes which are in the state.
+	JobsByState(state model.JobState) ([]model.Job, error)
+}
+
+// Leadership tells whether this replica leads the others. Only the leader
+// checks nodes, see the election package.
+type Leadership interface {
+	IsLeader() bool
+}
+
+// Dispatcher queues new jobs for workers which take them over NATS. Without
+// a dispatcher NewJob picks a node and calls it.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, jobID, request string) error
+	// The queued jobs are part of backups.
+	backup.Queue
+}
+
+// DeadLetters keeps NATS messages which failed, see the deadletter package.
+type DeadLetters interface {
+	List(limit int) []deadletter.Letter
+	Replay(id string) error
 }
 
+// Checker is implemented by storages which can tell whether they are usable
+// right now, it drives the gRPC health service of the repository.
+type Checker interface {
+	Check() error
+}
+
+// Config wires the parts a Repo works with besides its storage. Zero fields
+// are taken from their defaults, except Dispatcher and Letters, which stay
+// off.
+type Config struct {
+	// Journal records the life of nodes and jobs, events.Discard by default.
+	Journal events.Journal
+	// Health tells how nodes are checked, DefaultHealthPolicy by default.
+	Health HealthPolicy
+	// Pool keeps connections to workers.
+	Pool *connpool.Pool
+	// Dispatcher queues new jobs for workers, without it NewJob calls a
+	// free node.
+	Dispatcher Dispatcher
+	// History keeps the health of nodes for availability reports.
+	History *availability.History
+	// Letters are the failed NATS messages to inspect and replay.
+	Letters DeadLetters
+	// Leader tells whether the replica checks nodes, a replica on its own
+	// always does.
+	Leader Leadership
+	// Sweep describes the health check sweeps, discarded by default.
+	Sweep SweepMetrics
+}
+
+// soleReplica leads because there are no other replicas.
+type soleReplica struct{}
+
+func (soleReplica) IsLeader() bool { return true }
+
 // New returns a basic Service with all of the expected middlewares wired in.
-func New(s Storage, logger log.Logger, registerNodes, getAllNodes, newJobs metrics.Counter) Service {
-	
-	repo := Repo{s, logger}
+// Nodes are kept in the storage s, the rest is wired from cfg. Nodes are
+// checked in the background from now on.
+func New(s Storage, cfg Config, logger log.Logger, registerNodes, getAllNodes, newJobs metrics.Counter) Service {
+	if cfg.Journal == nil {
+		cfg.Journal = events.Discard
+	}
+	if cfg.Health == (HealthPolicy{}) {
+		cfg.Health = DefaultHealthPolicy
+	}
+	if cfg.Pool == nil {
+		cfg.Pool = connpool.New(connpool.DefaultConfig, connpool.DiscardMetrics, logger)
+	}
+	if cfg.History == nil {
+		cfg.History = availability.New(availability.DefaultMaxIncidents, availability.DefaultRetention)
+	}
+	if cfg.Leader == nil {
+		cfg.Leader = soleReplica{}
+	}
+	if cfg.Sweep.Duration == nil {
+		cfg.Sweep.Duration = discard.NewHistogram()
+	}
+	if cfg.Sweep.Lag == nil {
+		cfg.Sweep.Lag = discard.NewGauge()
+	}
+	if cfg.Sweep.Jobs == nil {
+		cfg.Sweep.Jobs = discard.NewGauge()
+	}
+
+	repo := Repo{
+		s:            s,
+		journal:      cfg.Journal,
+		health:       cfg.Health,
+		tracker:      newHealthTracker(),
+		locks:        newNodeLocks(),
+		pool:         cfg.Pool,
+		dispatcher:   cfg.Dispatcher,
+		history:      cfg.History,
+		letters:      cfg.Letters,
+		leader:       cfg.Leader,
+		sweepMetrics: cfg.Sweep,
+		logger:       logger,
+	}
 
 	var svc Service
 	{
@@ -52,29 +173,97 @@ var (
 	// ErrRepoUnevailable allows say that something wrong happens with a connection to DB
 	ErrRepoUnevailable = errors.New("can't connect to a storage service")
 
+	// ErrInvalidNodeID shows that a node registers with an ID which isn't a UUID
+	ErrInvalidNodeID = errors.New("invalid node ID")
+
 	// ErrNodeAlreadyExist prevents users add a node with dublicate name
 	ErrNodeAlreadyExist = errors.New("node with same name already registered in repo")
 
 	// ErrEmptyRepo shows that a repo is empty
 	ErrEmptyRepo = errors.New("empty repository")
+
+	// ErrNoHealthyNodes shows that all nodes in a repo fail health checks
+	ErrNoHealthyNodes = errors.New("no healthy nodes in repository")
+
+	// ErrUnknownNode shows that a node isn't registered in repo
+	ErrUnknownNode = errors.New("unknown node")
+
+	// ErrNoHeartbeat shows that a node stopped sending heartbeats
+	ErrNoHeartbeat = errors.New("no heartbeats from node")
+
+	// ErrNodeNotServing shows that a node reports NOT_SERVING over grpc.health.v1
+	ErrNodeNotServing = errors.New("node is not serving")
 )
 
 // Repo implements Service interface
 type Repo struct {
-	s      Storage
-	logger log.Logger
-}
+	s          Storage
+	journal    events.Journal
+	health     HealthPolicy
+	tracker    *healthTracker
+	locks      *nodeLocks
+	pool       *connpool.Pool
+	dispatcher Dispatcher
+	history    *availability.History
+	letters    DeadLetters
+	leader     Leadership
 
+	sweepMetrics SweepMetrics
+	logger       log.Logger
+}
 
-func (r Repo) RegisterNode(ctx context.Context, name string, IP string, port string) (string, error) {
+// RegisterNode adds a node to the repository. A node which passes its own ID
+// keeps it: if the repository still knows the node, the registration starts
+// a new generation of it, healthy and without jobs until they are synced
+// again, otherwise the node is added under that ID.
+func (r Repo) RegisterNode(ctx context.Context, id string, name string, IP string, port string) (string, uint64, error) {
 	node := model.Node{
-		Name: name,
-		IP:   IP,
-		Port: port,
+		Name:       name,
+		IP:         IP,
+		Port:       port,
+		State:      model.NodeHealthy,
+		StateSince: time.Now().UTC(),
+		Generation: 1,
 	}
 
-	id, err := r.s.NewNode(node)
-	return id.String(), err
+	if id != "" {
+		uid, err := uuid.Parse(id)
+		if err != nil {
+			return "", 0, ErrInvalidNodeID
+		}
+		node.ID = model.NodeID{UUID: uid}
+		unlock := r.locks.lock(node.ID)
+		defer unlock()
+		prev, ok, err := r.s.GetNode(node.ID)
+		if err != nil {
+			return "", 0, err
+		}
+		if ok {
+			generation, err := r.reregister(prev, node)
+			return id, generation, err
+		}
+	}
+
+	nodeID, err := r.s.NewNode(node)
+	if err != nil {
+		return "", 0, err
+	}
+	r.history.Registered(nodeID, name, node.StateSince)
+	return nodeID.String(), node.Generation, nil
+}
+
+// reregister replaces a known node with its new generation. Counters and the
+// jobs cursor of the old one are dropped, so the jobs are synced in full.
+// It returns the new generation.
+func (r Repo) reregister(prev model.Node, node model.Node) (uint64, error) {
+	node.Generation = prev.Generation + 1
+	if err := r.s.SaveNode(node); err != nil {
+		return 0, err
+	}
+	r.tracker.forget(node.ID)
+	r.history.StateChanged(node.ID, node.State, node.StateSince)
+	r.logger.Log("method", "RegisterNode", "node", node.ID.String(), "generation", node.Generation, "was", prev.State)
+	return node.Generation, nil
 }
 
 func (r Repo) GetAllNodes(ctx context.Context) ([]model.Node, error) {
@@ -82,7 +271,7 @@ func (r Repo) GetAllNodes(ctx context.Context) ([]model.Node, error) {
 }
 
 // FindFree returns a node with with low jobs running level
-func (r Repo) FindFree(ctx context.Context) ( string, string, string, string, error) {
+func (r Repo) FindFree(ctx context.Context) (string, string, string, string, error) {
 	nodes, err := r.s.GetAllNodes()
 	if err != nil {
 		return "", "", "", "", err
@@ -92,6 +281,18 @@ func (r Repo) FindFree(ctx context.Context) ( string, string, string, string, er
 		return "", "", "", "", ErrEmptyRepo
 	}
 
+	// Jobs are scheduled on healthy nodes only.
+	available := make([]model.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Available() {
+			available = append(available, n)
+		}
+	}
+	if len(available) == 0 {
+		return "", "", "", "", ErrNoHealthyNodes
+	}
+	nodes = available
+
 	num := 0 //number of node with low jobs
 
 	for k, n := range nodes {
@@ -106,89 +307,213 @@ func (r Repo) FindFree(ctx context.Context) ( string, string, string, string, er
 	return nodes[num].ID.String(), nodes[num].Name, nodes[num].IP, nodes[num].Port, nil
 }
 
-// NewJob starts new job on a free node
+// NewJob starts new job on a free node, or queues it for the dispatcher.
+// Only queued jobs can be made durable: a job started directly is lost to the
+// caller if the repository crashes during the call, though a job the worker
+// started anyway is synced from the worker later.
 func (r Repo) NewJob(ctx context.Context) (string, error) {
-	// id, name, IP, port, err := r.FindFree()
+	request := uuid.New().String()
+	if r.dispatcher != nil {
+		return r.dispatchJob(ctx, request)
+	}
+	r.journal.Record(events.Event{Type: events.JobSubmitted, Request: request})
+
+	jID, nodeID, err := r.newJob(ctx)
+	if err != nil {
+		r.journal.Record(events.Event{Type: events.JobFailed, Request: request, NodeID: nodeID, Err: err.Error()})
+		return "", err
+	}
+
+	jobID, _ := uuid.Parse(jID)
+	r.journal.Record(events.Event{Type: events.JobScheduled, Request: request, NodeID: nodeID, JobID: model.JobID{UUID: jobID}})
+
+	return jID, nil
+}
+
+// dispatchJob queues a job under a new ID, it is scheduled once a worker
+// takes it.
+func (r Repo) dispatchJob(ctx context.Context, request string) (string, error) {
+	jobID := model.JobID{UUID: uuid.New()}
+	r.journal.Record(events.Event{Type: events.JobSubmitted, Request: request, JobID: jobID})
+
+	if err := r.dispatcher.Dispatch(ctx, jobID.String(), request); err != nil {
+		r.journal.Record(events.Event{Type: events.JobFailed, Request: request, JobID: jobID, Err: err.Error()})
+		return "", err
+	}
+	return jobID.String(), nil
+}
+
+func (r Repo) newJob(ctx context.Context) (string, model.NodeID, error) {
 	id, name, IP, port, err := r.FindFree(ctx)
+	if err != nil {
+		return "", model.NodeID{}, err
+	}
 	r.logger.Log("method", "NewJob", "connecting to ", id+" "+name)
 
-	grpcAddr := IP + port
+	nodeUUID, _ := uuid.Parse(id)
+	nodeID := model.NodeID{UUID: nodeUUID}
+
 	ctx, close := context.WithTimeout(ctx, time.Second)
 	defer close()
-	conn, err := grpc.DialContext(ctx, grpcAddr, grpc.WithInsecure(), grpc.WithBlock())
+	svc, err := r.pool.Get(ctx, nodeID, IP+port)
 	if err != nil {
 		r.logger.Log("method", "NewJob", "err", err)
+		return "", nodeID, err
 	}
-	defer conn.Close()
-
-	otTracer := stdopentracing.GlobalTracer() // no-op
-	svc := workertransport.NewGRPCClient(conn, otTracer, r.logger)
 
 	jID, err := svc.NewJob(ctx)
 	r.logger.Log("method", "NewJob", "job ID", jID)
 
-	return jID, err
+	return jID, nodeID, err
+}
+
+// Export dumps all nodes, their jobs, the history of jobs and the jobs which
+// wait for a worker.
+func (r Repo) Export(ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := backup.Export(&buf, r.s, r.journal, r.queue()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Import restores a dump made by Export.
+func (r Repo) Import(ctx context.Context, data []byte) (backup.Stats, error) {
+	return backup.Import(bytes.NewReader(data), r.s, r.journal, r.queue())
+}
+
+// queue returns the queue of the dispatcher, nil if jobs aren't dispatched.
+func (r Repo) queue() backup.Queue {
+	if r.dispatcher == nil {
+		return nil
+	}
+	return r.dispatcher
 }
 
 // CheckNodes starts checking each node in the repository and save current number of running jobs
+// and the health state of the node according to the health policy.
 // checkNodesClose is a channel that should be close for stopping checking proccess.
+// A sweep which takes longer than the interval delays the next one, the delay
+// is exported as the sweep lag. Followers skip sweeps, they would fight the
+// leader over the same nodes.
 func (r Repo) CheckNodes(checkNodesClose chan struct{}) error {
-	ticker := time.NewTicker(1 * time.Second)
-	go func() {
-		for range ticker.C {
-			nodes, _ := r.s.GetAllNodes()
-			for _, n := range nodes {
-				// r.logger.Log("method", "CheckNodes", "node", n.ID.String()+" "+n.IP+n.Port)
-
-				grpcAddr := n.IP + n.Port
-				ctx, close := context.WithTimeout(context.Background(), time.Second)
-				defer close()
-				conn, err := grpc.DialContext(ctx, grpcAddr, grpc.WithInsecure(), grpc.WithBlock())
-				if err != nil {
-					r.logger.Log("method", "CheckNodes", "err", err)
-					r.s.DeleteNode(n.ID)
-					r.logger.Log("method", "CheckNodes", "action", "node deleted from the repository")
-				} else {
-					defer conn.Close()
-					otTracer := stdopentracing.GlobalTracer() // no-op
-					svc := workertransport.NewGRPCClient(conn, otTracer, r.logger)
-
-					jobsCount, err := svc.Ping(ctx)
-					if err != nil {
-						r.logger.Log("method", "CheckNodes", "err", err)
-						r.s.DeleteNode(n.ID)
-						r.logger.Log("method", "CheckNodes", "action", "node deleted from the repository")
-					} else {
-						// r.logger.Log("method", "CheckNodes", "jobsCount", jobsCount)
-						// save a updated node
-						n.JobsCount = jobsCount
-						r.s.SaveNode(n)
-					}
-					jobs, err := svc.GetJobs(ctx)
-					if err != nil {
-						r.logger.Log("method", "CheckNodes", "err", err)
-					} else {
-						r.logger.Log("method", "CheckNodes", "jobs", len(jobs))
-						js := make([]model.Job, 0)
-						for _, j := range jobs {
-							id, _ := uuid.Parse(j.ID.String())
-							job := model.Job{
-								ID:         model.JobID{id},
-								Per:        j.Per,
-								Duration:   float32(j.Duration.Seconds()),
-								StartTime:  j.StartTime,
-								FinishTime: j.FinishTime,
-							}
-							js = append(js, job)
-						}
-						n.Jobs = js
-						r.s.SaveNode(n)
-					}
-				}
-			}
+	next := time.Now().Add(r.health.Interval)
+	leading := false
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-checkNodesClose:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		if !r.leader.IsLeader() {
+			leading = false
+			next = start.Add(r.health.Interval)
+			continue
+		}
+		if !leading {
+			// Counters of an earlier term are stale, another replica checked
+			// the nodes meanwhile.
+			r.tracker.reset()
+			leading = true
+		}
+		r.sweepMetrics.Lag.Set(start.Sub(next).Seconds())
+		r.sweep(start)
+		r.sweepMetrics.Duration.Observe(time.Since(start).Seconds())
+		r.countJobs()
+
+		next = start.Add(r.health.Interval)
+	}
+}
+
+// probe asks the node for its health, pings it and fetches jobs changed
+// after the cursor. It returns all jobs if the node doesn't know the cursor,
+// which is reported by full.
+func (r Repo) probe(ctx context.Context, n model.Node, cursor string) (jobsCount int, delta jobsDelta, err error) {
+	if err := r.checkServing(ctx, n); err != nil {
+		return 0, delta, err
+	}
+
+	svc, err := r.pool.Get(ctx, n.ID, n.IP+n.Port)
+	if err != nil {
+		return 0, delta, err
+	}
+
+	jobsCount, err = svc.Ping(ctx)
+	if err != nil {
+		return 0, delta, err
+	}
+	d, err := svc.GetJobsSince(ctx, cursor)
+	if err != nil {
+		return 0, delta, err
+	}
+
+	delta = jobsDelta{
+		jobs:   convertJobs(d.Jobs),
+		cursor: d.Cursor,
+		full:   d.Full,
+	}
+	return jobsCount, delta, nil
+}
+
+// checkServing asks the node over grpc.health.v1 whether the worker service
+// is serving. Workers which don't implement the health service are taken as
+// serving, Ping alone tells about them. A draining worker stops serving on
+// purpose, it is still probed for its jobs.
+func (r Repo) checkServing(ctx context.Context, n model.Node) error {
+	hc, err := r.pool.Health(ctx, n.ID, n.IP+n.Port)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: workerHealthService})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		return nil
+	case err != nil:
+		return err
+	case resp.Status != healthpb.HealthCheckResponse_SERVING && n.State != model.NodeDraining:
+		return ErrNodeNotServing
+	}
+	return nil
+}
+
+// jobsDelta is a worker's reply to GetJobsSince in terms of the repository.
+type jobsDelta struct {
+	jobs   []model.Job
+	cursor string
+	full   bool
+}
+
+func convertJobs(jobs []workermodel.Job) []model.Job {
+	js := make([]model.Job, 0)
+	for _, j := range jobs {
+		id, _ := uuid.Parse(j.ID.String())
+		job := model.Job{
+			ID:         model.JobID{UUID: id},
+			Per:        j.Per,
+			Duration:   float32(j.Duration.Seconds()),
+			StartTime:  j.StartTime,
+			FinishTime: j.FinishTime,
 		}
-	}()
-	<-checkNodesClose
-	ticker.Stop()
+		js = append(js, job)
+	}
+	return js
+}
+
+// deleteNode deletes the node and forgets everything the repository keeps
+// about it in memory. The method is the one logged, the caller holds the lock
+// of the node.
+func (r Repo) deleteNode(method string, id model.NodeID) error {
+	if err := r.s.DeleteNode(id); err != nil {
+		r.logger.Log("method", method, "node", id.String(), "err", err)
+		return err
+	}
+	r.tracker.forget(id)
+	r.pool.Evict(id)
+	r.history.Deleted(id, time.Now().UTC())
+	r.logger.Log("method", method, "node", id.String(), "action", "node deleted from the repository")
 	return nil
 }
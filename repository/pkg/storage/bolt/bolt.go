@@ -0,0 +1,270 @@
+package bolt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+
+	repo "repository/pkg/model"
+)
+
+// Buckets layout:
+//
+//	nodes         nodeID -> node
+//	jobs          nodeID|jobID -> job
+//	jobs_by_state state|nodeID|jobID -> nothing
+var (
+	nodesBucket       = []byte("nodes")
+	jobsBucket        = []byte("jobs")
+	jobsByStateBucket = []byte("jobs_by_state")
+)
+
+// NodeStorage implements an embedded storage of nodes in a single bbolt file.
+// Every method runs in its own transaction, so a crash never leaves a node
+// with a half written set of jobs.
+type NodeStorage struct {
+	db     *bolt.DB
+	logger log.Logger
+}
+
+// New opens or creates a bbolt file at the path.
+func New(path string, logger log.Logger) (*NodeStorage, func(), error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{nodesBucket, jobsBucket, jobsByStateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	logger.Log("db", "bolt", "path", path, "message", "storage is opened")
+
+	ns := &NodeStorage{
+		db:     db,
+		logger: logger,
+	}
+	closeFn := func() {
+		if err := ns.db.Close(); err != nil {
+			ns.logger.Log("db", "bolt", "message", "can't close the storage", "err", err)
+		}
+	}
+
+	return ns, closeFn, nil
+}
+
+func jobKey(nodeID repo.NodeID, jobID repo.JobID) []byte {
+	k := make([]byte, 0, 32)
+	k = append(k, nodeID.UUID[:]...)
+	return append(k, jobID.UUID[:]...)
+}
+
+func stateKey(state repo.JobState, nodeID repo.NodeID, jobID repo.JobID) []byte {
+	return append([]byte{stateCodes[state]}, jobKey(nodeID, jobID)...)
+}
+
+func (ns *NodeStorage) NewNode(n repo.Node) (repo.NodeID, error) {
+	if n.ID.UUID == uuid.Nil {
+		n.ID = repo.NodeID{UUID: uuid.New()}
+	}
+	n.JobsCount = 0
+
+	err := ns.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).Put(n.ID.UUID[:], encodeNode(n))
+	})
+	if err != nil {
+		return repo.NodeID{}, err
+	}
+	return n.ID, nil
+}
+
+// SaveNode replaces a node and its jobs. Jobs which disappeared from the node
+// are removed together with their index entries.
+func (ns *NodeStorage) SaveNode(n repo.Node) error {
+	return ns.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(nodesBucket).Put(n.ID.UUID[:], encodeNode(n)); err != nil {
+			return err
+		}
+		if err := deleteJobs(tx, n.ID); err != nil {
+			return err
+		}
+
+		jobs := tx.Bucket(jobsBucket)
+		index := tx.Bucket(jobsByStateBucket)
+		for _, j := range n.Jobs {
+			if err := jobs.Put(jobKey(n.ID, j.ID), encodeJob(j)); err != nil {
+				return err
+			}
+			if err := index.Put(stateKey(j.State(), n.ID, j.ID), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PatchNode replaces a node and the given jobs, other jobs are kept. Index
+// entries of jobs which changed their state are moved.
+func (ns *NodeStorage) PatchNode(n repo.Node) error {
+	return ns.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(nodesBucket).Put(n.ID.UUID[:], encodeNode(n)); err != nil {
+			return err
+		}
+
+		jobs := tx.Bucket(jobsBucket)
+		index := tx.Bucket(jobsByStateBucket)
+		for _, j := range n.Jobs {
+			key := jobKey(n.ID, j.ID)
+			if v := jobs.Get(key); v != nil {
+				prev, err := decodeJob(j.ID, v)
+				if err != nil {
+					return err
+				}
+				if err := index.Delete(stateKey(prev.State(), n.ID, j.ID)); err != nil {
+					return err
+				}
+			}
+			if err := jobs.Put(key, encodeJob(j)); err != nil {
+				return err
+			}
+			if err := index.Put(stateKey(j.State(), n.ID, j.ID), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ns *NodeStorage) GetAllNodes() ([]repo.Node, error) {
+	result := make([]repo.Node, 0)
+
+	err := ns.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).ForEach(func(k, v []byte) error {
+			var id repo.NodeID
+			copy(id.UUID[:], k)
+			n, err := decodeNode(id, v)
+			if err != nil {
+				return err
+			}
+			if n.Jobs, err = nodeJobs(tx, id); err != nil {
+				return err
+			}
+			result = append(result, n)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (ns *NodeStorage) GetNode(id repo.NodeID) (repo.Node, bool, error) {
+	var (
+		n  repo.Node
+		ok bool
+	)
+	err := ns.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(nodesBucket).Get(id.UUID[:])
+		if v == nil {
+			return nil
+		}
+		var err error
+		if n, err = decodeNode(id, v); err != nil {
+			return err
+		}
+		n.Jobs, err = nodeJobs(tx, id)
+		ok = err == nil
+		return err
+	})
+	if err != nil {
+		return repo.Node{}, false, err
+	}
+	return n, ok, nil
+}
+
+func (ns *NodeStorage) DeleteNode(id repo.NodeID) error {
+	return ns.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteJobs(tx, id); err != nil {
+			return err
+		}
+		return tx.Bucket(nodesBucket).Delete(id.UUID[:])
+	})
+}
+
+// JobsByState returns jobs of all nodes which are in the state.
+// It reads the jobs_by_state index instead of scanning all jobs.
+func (ns *NodeStorage) JobsByState(state repo.JobState) ([]repo.Job, error) {
+	result := make([]repo.Job, 0)
+
+	err := ns.db.View(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		prefix := []byte{stateCodes[state]}
+		c := tx.Bucket(jobsByStateBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			v := jobs.Get(k[1:])
+			if v == nil {
+				continue
+			}
+			var id repo.JobID
+			copy(id.UUID[:], k[17:])
+			j, err := decodeJob(id, v)
+			if err != nil {
+				return err
+			}
+			result = append(result, j)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func nodeJobs(tx *bolt.Tx, id repo.NodeID) ([]repo.Job, error) {
+	result := make([]repo.Job, 0)
+	prefix := id.UUID[:]
+	c := tx.Bucket(jobsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var jobID repo.JobID
+		copy(jobID.UUID[:], k[16:])
+		j, err := decodeJob(jobID, v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, j)
+	}
+	return result, nil
+}
+
+// deleteJobs removes all jobs of a node and their index entries.
+func deleteJobs(tx *bolt.Tx, id repo.NodeID) error {
+	jobs, err := nodeJobs(tx, id)
+	if err != nil {
+		return err
+	}
+	jb := tx.Bucket(jobsBucket)
+	index := tx.Bucket(jobsByStateBucket)
+	for _, j := range jobs {
+		if err := jb.Delete(jobKey(id, j.ID)); err != nil {
+			return err
+		}
+		if err := index.Delete(stateKey(j.State(), id, j.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
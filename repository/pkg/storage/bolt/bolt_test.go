@@ -0,0 +1,75 @@
+package bolt_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+
+	repo "repository/pkg/model"
+	"repository/pkg/storage/bolt"
+	"repository/pkg/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	s, closeFn, err := bolt.New(filepath.Join(t.TempDir(), "repository.db"), log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	storagetest.Test(t, s)
+}
+
+// Nodes, jobs and the state index survive closing the file.
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repository.db")
+	running := repo.Job{ID: repo.JobID{UUID: uuid.New()}, Per: 50}
+	finished := repo.Job{ID: repo.JobID{UUID: uuid.New()}, Per: 100}
+
+	s, closeFn, err := bolt.New(path, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.NewNode(repo.Node{Name: "node-1", IP: "127.0.0.1", Port: ":8081"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := repo.Node{ID: id, Name: "node-1", IP: "127.0.0.1", Port: ":8081", JobsCount: 2, State: repo.NodeHealthy, Generation: 3, Jobs: []repo.Job{running, finished}}
+	if err := s.SaveNode(n); err != nil {
+		t.Fatal(err)
+	}
+	closeFn()
+
+	s, closeFn, err = bolt.New(path, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	got, ok, err := s.GetNode(id)
+	if err != nil || !ok {
+		t.Fatalf("want node %s, got %v, %v", id, ok, err)
+	}
+	if got.Name != n.Name || got.State != n.State || got.Generation != n.Generation || len(got.Jobs) != 2 {
+		t.Errorf("want %+v, got %+v", n, got)
+	}
+
+	tests := []struct {
+		state repo.JobState
+		want  repo.JobID
+	}{
+		{repo.JobRunning, running.ID},
+		{repo.JobFinished, finished.ID},
+	}
+	for _, tt := range tests {
+		jobs, err := s.JobsByState(tt.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 1 || jobs[0].ID != tt.want {
+			t.Errorf("%s: want job %s, got %+v", tt.state, tt.want, jobs)
+		}
+	}
+}
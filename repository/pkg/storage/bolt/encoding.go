@@ -0,0 +1,211 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	repo "repository/pkg/model"
+)
+
+// Records are prefixed with a format version, so the layout can be changed
+// later without migrating a whole file at once.
+const formatVersion byte = 1
+
+// nodeFormatVersion 2 adds the health state of a node, version 1 records
+// are read as nodes without a state. Version 3 adds the generation, older
+// records are read as generation 0.
+const nodeFormatVersion byte = 3
+
+var errCorrupted = errors.New("corrupted record")
+
+// stateCodes maps job states to one byte codes used in index keys.
+var stateCodes = map[repo.JobState]byte{
+	repo.JobRunning:  1,
+	repo.JobFinished: 2,
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (e *encoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutVarint(b[:], v)]...)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) float32(f float32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], math.Float32bits(f))
+	e.buf = append(e.buf, b[:]...)
+}
+
+// time writes a presence byte first, a zero time.Time can't be represented
+// by UnixNano.
+func (e *encoder) time(t time.Time) {
+	if t.IsZero() {
+		e.buf = append(e.buf, 0)
+		return
+	}
+	e.buf = append(e.buf, 1)
+	e.varint(t.UnixNano())
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 1 {
+		d.err = errCorrupted
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) string() string {
+	l := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < l {
+		d.err = errCorrupted
+		return ""
+	}
+	s := string(d.buf[:l])
+	d.buf = d.buf[l:]
+	return s
+}
+
+func (d *decoder) float32() float32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 4 {
+		d.err = errCorrupted
+		return 0
+	}
+	f := math.Float32frombits(binary.BigEndian.Uint32(d.buf))
+	d.buf = d.buf[4:]
+	return f
+}
+
+func (d *decoder) time() time.Time {
+	if d.byte() == 0 {
+		return time.Time{}
+	}
+	nsec := d.varint()
+	if d.err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec).UTC()
+}
+
+// version reads a format version which is not greater than max.
+func (d *decoder) version(max byte) byte {
+	v := d.byte()
+	if d.err == nil && (v == 0 || v > max) {
+		d.err = errors.New("unsupported record format version")
+	}
+	return v
+}
+
+// encodeNode encodes a node without its jobs, they are stored separately.
+func encodeNode(n repo.Node) []byte {
+	e := encoder{buf: make([]byte, 0, 64)}
+	e.buf = append(e.buf, nodeFormatVersion)
+	e.string(n.Name)
+	e.string(n.IP)
+	e.string(n.Port)
+	e.varint(int64(n.JobsCount))
+	e.string(string(n.State))
+	e.time(n.StateSince)
+	e.uvarint(n.Generation)
+	return e.buf
+}
+
+func decodeNode(id repo.NodeID, b []byte) (repo.Node, error) {
+	d := decoder{buf: b}
+	v := d.version(nodeFormatVersion)
+	n := repo.Node{
+		ID:        id,
+		Name:      d.string(),
+		IP:        d.string(),
+		Port:      d.string(),
+		JobsCount: int(d.varint()),
+	}
+	if v >= 2 {
+		n.State = repo.NodeState(d.string())
+		n.StateSince = d.time()
+	}
+	if v >= 3 {
+		n.Generation = d.uvarint()
+	}
+	return n, d.err
+}
+
+func encodeJob(j repo.Job) []byte {
+	e := encoder{buf: make([]byte, 0, 32)}
+	e.buf = append(e.buf, formatVersion)
+	e.float32(j.Per)
+	e.float32(j.Duration)
+	e.time(j.StartTime)
+	e.time(j.FinishTime)
+	return e.buf
+}
+
+func decodeJob(id repo.JobID, b []byte) (repo.Job, error) {
+	d := decoder{buf: b}
+	d.version(formatVersion)
+	j := repo.Job{
+		ID:         id,
+		Per:        d.float32(),
+		Duration:   d.float32(),
+		StartTime:  d.time(),
+		FinishTime: d.time(),
+	}
+	return j, d.err
+}
@@ -0,0 +1,122 @@
+// Package faulty provides a service.Storage decorator which makes any storage
+// slow and unreliable, to see how the repository copes with a flaky database.
+package faulty
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	repo "repository/pkg/model"
+	"repository/pkg/service"
+)
+
+// ErrInjected is returned by failed calls unless Config.Err is set.
+var ErrInjected = errors.New("faulty: injected storage error")
+
+// Config describes faults injected into every call.
+type Config struct {
+	// Latency is added to every call, Jitter is a random extra up to its value.
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is a probability from 0 to 1 that a call fails without
+	// reaching the next storage.
+	ErrorRate float64
+	// Err is returned by failed calls, ErrInjected if nil.
+	Err error
+	// Seed of the random source, the current time if zero.
+	Seed int64
+}
+
+// Storage injects faults into calls of the next storage.
+type Storage struct {
+	next service.Storage
+	cfg  Config
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+// New wraps the next storage.
+func New(next service.Storage, cfg Config) *Storage {
+	if cfg.Err == nil {
+		cfg.Err = ErrInjected
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	return &Storage{
+		next: next,
+		cfg:  cfg,
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+func (s *Storage) NewNode(n repo.Node) (repo.NodeID, error) {
+	if err := s.inject(); err != nil {
+		return repo.NodeID{}, err
+	}
+	return s.next.NewNode(n)
+}
+
+func (s *Storage) SaveNode(n repo.Node) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.next.SaveNode(n)
+}
+
+func (s *Storage) PatchNode(n repo.Node) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.next.PatchNode(n)
+}
+
+func (s *Storage) GetAllNodes() ([]repo.Node, error) {
+	if err := s.inject(); err != nil {
+		return nil, err
+	}
+	return s.next.GetAllNodes()
+}
+
+func (s *Storage) GetNode(id repo.NodeID) (repo.Node, bool, error) {
+	if err := s.inject(); err != nil {
+		return repo.Node{}, false, err
+	}
+	return s.next.GetNode(id)
+}
+
+func (s *Storage) DeleteNode(id repo.NodeID) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.next.DeleteNode(id)
+}
+
+func (s *Storage) JobsByState(state repo.JobState) ([]repo.Job, error) {
+	if err := s.inject(); err != nil {
+		return nil, err
+	}
+	return s.next.JobsByState(state)
+}
+
+// inject sleeps for the configured latency and decides whether the call fails.
+func (s *Storage) inject() error {
+	s.mtx.Lock()
+	delay := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(s.cfg.Jitter)))
+	}
+	fail := s.cfg.ErrorRate > 0 && s.rand.Float64() < s.cfg.ErrorRate
+	s.mtx.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if fail {
+		return s.cfg.Err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package faulty_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	repo "repository/pkg/model"
+	"repository/pkg/storage/faulty"
+	"repository/pkg/storage/inmem"
+	"repository/pkg/storage/storagetest"
+)
+
+// Slow calls still have to behave like the storage they wrap.
+func TestConformance(t *testing.T) {
+	storagetest.Test(t, faulty.New(inmem.New(), faulty.Config{
+		Latency: time.Millisecond,
+		Jitter:  time.Millisecond,
+	}))
+}
+
+func TestErrorRate(t *testing.T) {
+	next := inmem.New()
+	id, err := next.NewNode(repo.Node{Name: "node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  faulty.Config
+		want error
+	}{
+		{"injected", faulty.Config{ErrorRate: 1}, faulty.ErrInjected},
+		{"custom", faulty.Config{ErrorRate: 1, Err: errBroken}, errBroken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := faulty.New(next, tt.cfg)
+			calls := map[string]error{}
+			_, calls["NewNode"] = s.NewNode(repo.Node{Name: "other"})
+			calls["SaveNode"] = s.SaveNode(repo.Node{ID: id})
+			calls["PatchNode"] = s.PatchNode(repo.Node{ID: id})
+			_, calls["GetAllNodes"] = s.GetAllNodes()
+			_, _, calls["GetNode"] = s.GetNode(id)
+			calls["DeleteNode"] = s.DeleteNode(id)
+			_, calls["JobsByState"] = s.JobsByState(repo.JobRunning)
+			for method, err := range calls {
+				if err != tt.want {
+					t.Errorf("%s: want %v, got %v", method, tt.want, err)
+				}
+			}
+		})
+	}
+
+	// Failed calls never reach the next storage.
+	nodes, err := next.GetAllNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != id {
+		t.Errorf("want only node %s, got %v", id, nodes)
+	}
+}
+
+// Jitter only adds to the latency of a call.
+func TestLatency(t *testing.T) {
+	cfg := faulty.Config{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond}
+	s := faulty.New(inmem.New(), cfg)
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if _, err := s.GetAllNodes(); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d < cfg.Latency {
+			t.Errorf("call %d: want at least %v, took %v", i, cfg.Latency, d)
+		}
+	}
+}
+
+// A seeded storage fails the same calls every time.
+func TestSeed(t *testing.T) {
+	failures := func() []bool {
+		s := faulty.New(inmem.New(), faulty.Config{ErrorRate: 0.5, Seed: 42})
+		result := make([]bool, 20)
+		for i := range result {
+			_, err := s.GetAllNodes()
+			result[i] = err != nil
+		}
+		return result
+	}
+	a, b := failures(), failures()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d: failed %v, then %v", i, a[i], b[i])
+		}
+	}
+}
+
+var errBroken = errors.New("broken")
@@ -2,25 +2,26 @@ package gorm
 
 import (
 	"time"
-	
-	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
-	
+
 	repo "repository/pkg/model"
 	"repository/pkg/service"
 )
 
 // Node represents an executer instance machine
 type Node struct {
-	ID        string `gorm:"primary_key"`
-	Name      string
-	IP        string
-	Port      string
-	JobsCount int
-	Jobs      []Job `gorm:"foreignkey:NodeID"`
+	ID         string `gorm:"primary_key"`
+	Name       string
+	IP         string
+	Port       string
+	JobsCount  int
+	Jobs       []Job `gorm:"foreignkey:NodeID"`
+	State      string
+	StateSince time.Time
+	Generation uint64
 }
 
 type Job struct {
@@ -32,20 +33,27 @@ type Job struct {
 	NodeID     string
 }
 
-// NodeStorage implements mySQL storage for nodes
+// NodeStorage implements SQL storage for nodes. MySQL and PostgreSQL are supported.
 type NodeStorage struct {
-	DB     *gorm.DB
-	DSN    string
-	logger log.Logger
+	DB      *gorm.DB
+	Dialect string
+	DSN     string
+	logger  log.Logger
 }
 
-// New create in memory repository for storing nodes
-func New(dsn string, logger log.Logger) (*NodeStorage, func()) {
+// New create SQL repository for storing nodes. The dialect is either mysql or postgres.
+func New(dialect string, dsn string, logger log.Logger) (*NodeStorage, func(), error) {
+
+	dsn, err := normalizeDSN(dialect, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	ns := &NodeStorage{
-		DB:     nil,
-		DSN:    dsn,
-		logger: logger,
+		DB:      nil,
+		Dialect: dialect,
+		DSN:     dsn,
+		logger:  logger,
 	}
 
 	closeCh := make(chan struct{}, 1)
@@ -54,7 +62,7 @@ func New(dsn string, logger log.Logger) (*NodeStorage, func()) {
 		closeCh <- struct{}{}
 	}
 
-	return ns, closeFn
+	return ns, closeFn, nil
 }
 
 func (ns *NodeStorage) connectToDB(closeCh chan struct{}) {
@@ -62,21 +70,22 @@ func (ns *NodeStorage) connectToDB(closeCh chan struct{}) {
 	go func() {
 		for range ticker.C {
 			if ns.DB == nil {
-				db, err := gorm.Open("mysql", ns.DSN)
+				db, err := gorm.Open(ns.Dialect, ns.DSN)
 				if err != nil {
-					ns.logger.Log("db", "mysql", "message", "got an error", "err", err)
+					ns.logger.Log("db", ns.Dialect, "message", "got an error", "err", err)
+				} else if err := migrate(db, ns.Dialect); err != nil {
+					ns.logger.Log("db", ns.Dialect, "message", "migration failed", "err", err)
+					db.Close()
 				} else {
-					ns.logger.Log("db", "mysql", "message", "connection is established")
+					ns.logger.Log("db", ns.Dialect, "message", "connection is established")
 					ns.DB = db
-					ns.DB.AutoMigrate(&Node{})
-					ns.DB.AutoMigrate(&Job{})
 				}
 
 			}
 			if ns.DB != nil {
 				err := ns.DB.DB().Ping()
 				if err != nil {
-					ns.logger.Log("db", "mysql", "message", "lost connection to the db", "err", err)
+					ns.logger.Log("db", ns.Dialect, "message", "lost connection to the db", "err", err)
 				}
 			}
 		}
@@ -86,35 +95,60 @@ func (ns *NodeStorage) connectToDB(closeCh chan struct{}) {
 	ticker.Stop()
 }
 
-func (ns *NodeStorage) NewNode(n repo.Node) (repo.NodeID, error) {
-	if ns.DB != nil {
-
-		id := uuid.New()
-		node := Node{
-			ID:        id.String(),
-			Name:      n.Name,
-			IP:        n.IP,
-			Port:      n.Port,
-			JobsCount: 0,
-		}
+// Check tells whether the database is connected and answers.
+func (ns *NodeStorage) Check() error {
+	if ns.DB == nil {
+		return service.ErrRepoUnevailable
+	}
+	return ns.DB.DB().Ping()
+}
 
-		ns.DB.Create(&node)
-		return repo.NodeID{id}, nil
-	} else {
+func (ns *NodeStorage) NewNode(n repo.Node) (repo.NodeID, error) {
+	if ns.DB == nil {
 		return repo.NodeID{}, service.ErrRepoUnevailable
 	}
+
+	id := n.ID.UUID
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	node := Node{
+		ID:         id.String(),
+		Name:       n.Name,
+		IP:         n.IP,
+		Port:       n.Port,
+		JobsCount:  0,
+		State:      string(n.State),
+		StateSince: n.StateSince,
+		Generation: n.Generation,
+	}
+
+	if err := ns.DB.Create(&node).Error; err != nil {
+		return repo.NodeID{}, err
+	}
+	return repo.NodeID{UUID: id}, nil
 }
+
+// SaveNode stores the node with exactly the given jobs, jobs which are no
+// longer reported by the node are deleted.
 func (ns *NodeStorage) SaveNode(n repo.Node) error {
+	if ns.DB == nil {
+		return service.ErrRepoUnevailable
+	}
 
 	node := Node{
-		ID:        n.ID.String(),
-		Name:      n.Name,
-		IP:        n.IP,
-		Port:      n.Port,
-		JobsCount: n.JobsCount,
-		Jobs:      []Job{},
+		ID:         n.ID.String(),
+		Name:       n.Name,
+		IP:         n.IP,
+		Port:       n.Port,
+		JobsCount:  n.JobsCount,
+		Jobs:       []Job{},
+		State:      string(n.State),
+		StateSince: n.StateSince,
+		Generation: n.Generation,
 	}
 
+	ids := make([]string, 0, len(n.Jobs))
 	for _, j := range n.Jobs {
 		node.Jobs = append(node.Jobs, Job{
 			ID:         j.ID.String(),
@@ -123,62 +157,179 @@ func (ns *NodeStorage) SaveNode(n repo.Node) error {
 			StartTime:  j.StartTime,
 			FinishTime: j.FinishTime,
 		})
+		ids = append(ids, j.ID.String())
+	}
+
+	tx := ns.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Save(&node).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	vanished := tx.Where("node_id = ?", node.ID)
+	if len(ids) > 0 {
+		vanished = vanished.Where("id NOT IN (?)", ids)
+	}
+	if err := vanished.Delete(Job{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// PatchNode stores the node and upserts the given jobs, other jobs of the
+// node are left alone.
+func (ns *NodeStorage) PatchNode(n repo.Node) error {
+	if ns.DB == nil {
+		return service.ErrRepoUnevailable
 	}
 
-	ns.DB.Save(&node)
+	node := Node{
+		ID:         n.ID.String(),
+		Name:       n.Name,
+		IP:         n.IP,
+		Port:       n.Port,
+		JobsCount:  n.JobsCount,
+		State:      string(n.State),
+		StateSince: n.StateSince,
+		Generation: n.Generation,
+	}
 
-	return nil
+	tx := ns.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Save(&node).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, j := range n.Jobs {
+		job := Job{
+			ID:         j.ID.String(),
+			Per:        j.Per,
+			Duration:   j.Duration,
+			StartTime:  j.StartTime,
+			FinishTime: j.FinishTime,
+			NodeID:     node.ID,
+		}
+		if err := tx.Save(&job).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
 }
 
 func (ns *NodeStorage) GetAllNodes() ([]repo.Node, error) {
+	if ns.DB == nil {
+		return nil, service.ErrRepoUnevailable
+	}
 
 	nodes := []Node{}
+	if err := ns.DB.Preload("Jobs").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+
 	result := []repo.Node{}
+	for _, n := range nodes {
+		result = append(result, n.model())
+	}
 
-	// err := ns.DB.DB().Ping()
-	// if err == nil {
-	if ns.DB != nil {
-		if err := ns.DB.DB().Ping(); err == nil {
-			ns.DB.Preload("Jobs").Find(&nodes)
-			for _, n := range nodes {
-				id, _ := uuid.Parse(n.ID)
-
-				jobs := []repo.Job{}
-				for _, j := range n.Jobs {
-					id, _ := uuid.Parse(j.ID)
-					jobs = append(jobs, repo.Job{
-						ID:         repo.JobID{id},
-						Per:        j.Per,
-						Duration:   j.Duration,
-						StartTime:  j.StartTime,
-						FinishTime: j.FinishTime,
-					})
-				}
+	return result, nil
+}
 
-				result = append(result, repo.Node{
-					ID:        repo.NodeID{id},
-					Name:      n.Name,
-					IP:        n.IP,
-					Port:      n.Port,
-					JobsCount: n.JobsCount,
-					Jobs:      jobs,
-				})
-			}
-		}
+func (ns *NodeStorage) GetNode(id repo.NodeID) (repo.Node, bool, error) {
+	if ns.DB == nil {
+		return repo.Node{}, false, service.ErrRepoUnevailable
+	}
+
+	nodes := []Node{}
+	if err := ns.DB.Preload("Jobs").Where("id = ?", id.String()).Find(&nodes).Error; err != nil {
+		return repo.Node{}, false, err
+	}
+	if len(nodes) == 0 {
+		return repo.Node{}, false, nil
+	}
+	return nodes[0].model(), true, nil
+}
+
+// JobsByState returns jobs of all nodes which are in the state, a job is
+// finished at 100 percent.
+func (ns *NodeStorage) JobsByState(state repo.JobState) ([]repo.Job, error) {
+	if ns.DB == nil {
+		return nil, service.ErrRepoUnevailable
+	}
+
+	query := "per < ?"
+	if state == repo.JobFinished {
+		query = "per >= ?"
+	}
+	jobs := []Job{}
+	if err := ns.DB.Where(query, 100).Find(&jobs).Error; err != nil {
+		return nil, err
 	}
 
+	result := []repo.Job{}
+	for _, j := range jobs {
+		result = append(result, j.model())
+	}
 	return result, nil
 }
 
-func (ns *NodeStorage) DeleteNode(id repo.NodeID) {
-	node := Node{
-		ID: id.String(),
+// model converts a stored node and its jobs to the model.
+func (n Node) model() repo.Node {
+	id, _ := uuid.Parse(n.ID)
+
+	jobs := []repo.Job{}
+	for _, j := range n.Jobs {
+		jobs = append(jobs, j.model())
 	}
 
-	ns.DB.Preload("Jobs").Find(&node)
-	for _, j := range node.Jobs {
-		ns.DB.Delete(&j)
+	return repo.Node{
+		ID:         repo.NodeID{UUID: id},
+		Name:       n.Name,
+		IP:         n.IP,
+		Port:       n.Port,
+		JobsCount:  n.JobsCount,
+		Jobs:       jobs,
+		State:      repo.NodeState(n.State),
+		StateSince: n.StateSince,
+		Generation: n.Generation,
 	}
+}
 
-	ns.DB.Delete(&node)
+// model converts a stored job to the model.
+func (j Job) model() repo.Job {
+	id, _ := uuid.Parse(j.ID)
+	return repo.Job{
+		ID:         repo.JobID{UUID: id},
+		Per:        j.Per,
+		Duration:   j.Duration,
+		StartTime:  j.StartTime,
+		FinishTime: j.FinishTime,
+	}
+}
+
+func (ns *NodeStorage) DeleteNode(id repo.NodeID) error {
+	if ns.DB == nil {
+		return service.ErrRepoUnevailable
+	}
+
+	tx := ns.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Where("node_id = ?", id.String()).Delete(Job{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", id.String()).Delete(Node{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
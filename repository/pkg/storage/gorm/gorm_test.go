@@ -0,0 +1,43 @@
+package gorm_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	store "repository/pkg/storage/gorm"
+	"repository/pkg/storage/storagetest"
+)
+
+// The suite runs against a real database only, set REPOSITORY_TEST_DSN, and
+// REPOSITORY_TEST_DIALECT unless it is MySQL. Every check cleans up after
+// itself, a database in use is fine.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("REPOSITORY_TEST_DSN")
+	if dsn == "" {
+		t.Skip("REPOSITORY_TEST_DSN is not set")
+	}
+	dialect := os.Getenv("REPOSITORY_TEST_DIALECT")
+	if dialect == "" {
+		dialect = store.DialectMySQL
+	}
+
+	s, closeFn, err := store.New(dialect, dsn, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	// The storage connects in the background.
+	deadline := time.Now().Add(30 * time.Second)
+	for s.Check() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("no connection to the database: %v", s.Check())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	storagetest.Test(t, s)
+}
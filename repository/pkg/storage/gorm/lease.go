@@ -0,0 +1,67 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"repository/pkg/election"
+	"repository/pkg/service"
+)
+
+// Lease is a row replicas of the repository compete for, see the election
+// package.
+type Lease struct {
+	Name    string `gorm:"primary_key"`
+	Holder  string
+	Addr    string
+	Expires time.Time
+}
+
+// Acquire implements election.LeaseStore. The lease is taken by a conditional
+// update, which the database runs atomically, so at most one replica gets an
+// expired lease.
+func (ns *NodeStorage) Acquire(name string, holder election.Holder, ttl time.Duration) (election.Holder, error) {
+	if ns.DB == nil {
+		return election.Holder{}, service.ErrRepoUnevailable
+	}
+
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+	res := ns.DB.Model(&Lease{}).
+		Where("name = ? AND (holder = ? OR expires < ?)", name, holder.ID, now).
+		Updates(map[string]interface{}{"holder": holder.ID, "addr": holder.Addr, "expires": expires})
+	if res.Error != nil {
+		return election.Holder{}, res.Error
+	}
+	if res.RowsAffected > 0 {
+		holder.Expires = expires
+		return holder, nil
+	}
+
+	// Either somebody else holds the lease, or there is no lease yet, or
+	// MySQL didn't count a renewal within the same second as a change.
+	l := Lease{}
+	err := ns.DB.Where("name = ?", name).First(&l).Error
+	if gorm.IsRecordNotFoundError(err) {
+		l = Lease{Name: name, Holder: holder.ID, Addr: holder.Addr, Expires: expires}
+		// A replica which loses the race to create the row gets an error
+		// and sees the winner next time.
+		if err := ns.DB.Create(&l).Error; err != nil {
+			return election.Holder{}, err
+		}
+	} else if err != nil {
+		return election.Holder{}, err
+	}
+	return election.Holder{ID: l.Holder, Addr: l.Addr, Expires: l.Expires}, nil
+}
+
+// Release implements election.LeaseStore.
+func (ns *NodeStorage) Release(name string, holderID string) error {
+	if ns.DB == nil {
+		return service.ErrRepoUnevailable
+	}
+	return ns.DB.Model(&Lease{}).
+		Where("name = ? AND holder = ?", name, holderID).
+		Update("expires", time.Now().UTC()).Error
+}
@@ -0,0 +1,104 @@
+package gorm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// schemaMigration stores versions of migrations already applied to a database.
+type schemaMigration struct {
+	Version   int `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// migration changes a database schema from one version to the next one.
+type migration struct {
+	version int
+	name    string
+	up      func(db *gorm.DB, dialect string) error
+}
+
+// migrations must be appended only, already released versions are never changed.
+var migrations = []migration{
+	{1, "create nodes and jobs", func(db *gorm.DB, _ string) error {
+		return db.AutoMigrate(&Node{}, &Job{}).Error
+	}},
+	{2, "uuid identifiers", func(db *gorm.DB, dialect string) error {
+		columns := []struct{ table, column string }{
+			{"nodes", "id"},
+			{"jobs", "id"},
+			{"jobs", "node_id"},
+		}
+		for _, c := range columns {
+			if err := alterColumnType(db, dialect, c.table, c.column, uuidColumnType(dialect)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{3, "index jobs by node", func(db *gorm.DB, _ string) error {
+		return db.Model(&Job{}).AddIndex("idx_jobs_node_id", "node_id").Error
+	}},
+	{4, "node health state", func(db *gorm.DB, _ string) error {
+		// AutoMigrate only adds missing columns, databases created by the
+		// first migration of a newer release already have them.
+		return db.AutoMigrate(&Node{}).Error
+	}},
+	{5, "leader leases", func(db *gorm.DB, _ string) error {
+		return db.AutoMigrate(&Lease{}).Error
+	}},
+	{6, "node generation", func(db *gorm.DB, _ string) error {
+		return db.AutoMigrate(&Node{}).Error
+	}},
+}
+
+// migrate applies all migrations which haven't been applied to a database yet.
+// Each migration runs in its own transaction where the dialect allows that.
+func migrate(db *gorm.DB, dialect string) error {
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return err
+	}
+
+	applied := []schemaMigration{}
+	if err := db.Find(&applied).Error; err != nil {
+		return err
+	}
+	done := make(map[int]bool)
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+		tx := db.Begin()
+		if err := m.up(tx, dialect); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %v", m.version, m.name, err)
+		}
+		if err := tx.Create(&schemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alterColumnType changes a column type. Postgres needs an explicit cast for
+// columns which already hold data.
+func alterColumnType(db *gorm.DB, dialect, table, column, typ string) error {
+	switch dialect {
+	case DialectPostgres:
+		return db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", table, column, typ, column, typ)).Error
+	case DialectMySQL:
+		return db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, column, typ)).Error
+	}
+	return ErrUnknownDialect
+}
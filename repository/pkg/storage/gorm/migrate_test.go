@@ -0,0 +1,87 @@
+package gorm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// Released migrations are numbered from 1 without gaps, in the order they
+// are applied.
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %d (%s): want version %d", m.version, m.name, i+1)
+		}
+	}
+}
+
+// Migrations run in order, the ones already applied are skipped, and a
+// failed one is applied again next time.
+func TestMigrate(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Every connection has its own in-memory database.
+	db.DB().SetMaxOpenConns(1)
+
+	var ran []int
+	fail := errors.New("broken")
+	step := func(version int, err error) migration {
+		return migration{version, "step", func(db *gorm.DB, _ string) error {
+			if err != nil {
+				return err
+			}
+			ran = append(ran, version)
+			return nil
+		}}
+	}
+	defer func(released []migration) { migrations = released }(migrations)
+
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&schemaMigration{Version: 2, Name: "step"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		migrations []migration
+		err        bool
+		ran        []int
+		applied    []int
+	}{
+		{"pending", []migration{step(1, nil), step(2, nil), step(3, nil)}, false, []int{1, 3}, []int{1, 2, 3}},
+		{"applied", []migration{step(1, nil), step(2, nil), step(3, nil)}, false, nil, []int{1, 2, 3}},
+		{"failed", []migration{step(1, nil), step(2, nil), step(3, nil), step(4, fail), step(5, nil)}, true, nil, []int{1, 2, 3}},
+		{"fixed", []migration{step(1, nil), step(2, nil), step(3, nil), step(4, nil), step(5, nil)}, false, []int{4, 5}, []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		ran, migrations = nil, tt.migrations
+		err := migrate(db, DialectPostgres)
+		if (err != nil) != tt.err {
+			t.Fatalf("%s: want error %v, got %v", tt.name, tt.err, err)
+		}
+		if !reflect.DeepEqual(ran, tt.ran) {
+			t.Errorf("%s: want %v run, got %v", tt.name, tt.ran, ran)
+		}
+
+		var rows []schemaMigration
+		if err := db.Order("version").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		applied := []int{}
+		for _, r := range rows {
+			applied = append(applied, r.Version)
+		}
+		if !reflect.DeepEqual(applied, tt.applied) {
+			t.Errorf("%s: want %v applied, got %v", tt.name, tt.applied, applied)
+		}
+	}
+}
@@ -8,8 +8,8 @@ import (
 )
 
 // New create in memory repository for storing nodes
-func New() NodeStorage {
-	return NodeStorage{
+func New() *NodeStorage {
+	return &NodeStorage{
 		nodes: make(map[repo.NodeID]repo.Node),
 	}
 }
@@ -20,40 +20,86 @@ type NodeStorage struct {
 	nodes map[repo.NodeID]repo.Node
 }
 
-func (ns NodeStorage) NewNode(n repo.Node) (repo.NodeID, error) {
+func (ns *NodeStorage) NewNode(n repo.Node) (repo.NodeID, error) {
 	ns.mtx.Lock()
 	defer ns.mtx.Unlock()
 
-	id := uuid.New()
-	n.ID = repo.NodeID{id}
+	if n.ID.UUID == uuid.Nil {
+		n.ID = repo.NodeID{UUID: uuid.New()}
+	}
 	n.JobsCount = 0
 	ns.nodes[n.ID] = n
 
 	return n.ID, nil
 }
-func (ns NodeStorage) SaveNode(n repo.Node) error {
+func (ns *NodeStorage) SaveNode(n repo.Node) error {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+
+	ns.nodes[n.ID] = copyNode(n)
+
+	return nil
+}
+
+func (ns *NodeStorage) PatchNode(n repo.Node) error {
 	ns.mtx.Lock()
 	defer ns.mtx.Unlock()
 
+	n.Jobs = repo.MergeJobs(ns.nodes[n.ID].Jobs, n.Jobs)
 	ns.nodes[n.ID] = n
 
 	return nil
 }
 
-func (ns NodeStorage) GetAllNodes() ([]repo.Node, error) {
+func (ns *NodeStorage) GetAllNodes() ([]repo.Node, error) {
 	ns.mtx.RLock()
 	defer ns.mtx.RUnlock()
 
 	result := make([]repo.Node, 0)
 	for id := range ns.nodes {
-		result = append(result, ns.nodes[id])
+		result = append(result, copyNode(ns.nodes[id]))
+	}
+
+	return result, nil
+}
+
+func (ns *NodeStorage) GetNode(id repo.NodeID) (repo.Node, bool, error) {
+	ns.mtx.RLock()
+	defer ns.mtx.RUnlock()
+
+	n, ok := ns.nodes[id]
+	if !ok {
+		return repo.Node{}, false, nil
 	}
+	return copyNode(n), true, nil
+}
+
+func (ns *NodeStorage) JobsByState(state repo.JobState) ([]repo.Job, error) {
+	ns.mtx.RLock()
+	defer ns.mtx.RUnlock()
 
+	result := make([]repo.Job, 0)
+	for _, n := range ns.nodes {
+		for _, j := range n.Jobs {
+			if j.State() == state {
+				result = append(result, j)
+			}
+		}
+	}
 	return result, nil
 }
 
-func (ns NodeStorage) DeleteNode(id repo.NodeID) {
+func (ns *NodeStorage) DeleteNode(id repo.NodeID) error {
 	ns.mtx.Lock()
 	defer ns.mtx.Unlock()
 	delete(ns.nodes, id)
+	return nil
+}
+
+// copyNode makes sure callers never share jobs with the storage.
+func copyNode(n repo.Node) repo.Node {
+	jobs := make([]repo.Job, len(n.Jobs))
+	copy(jobs, n.Jobs)
+	n.Jobs = jobs
+	return n
 }
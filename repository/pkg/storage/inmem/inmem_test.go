@@ -0,0 +1,12 @@
+package inmem_test
+
+import (
+	"testing"
+
+	"repository/pkg/storage/inmem"
+	"repository/pkg/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Test(t, inmem.New())
+}
@@ -0,0 +1,483 @@
+// Package storagecheck is a conformance suite for service.Storage
+// implementations. Every check cleans up the nodes it creates, so the suite can
+// be run against a database which is already in use, for example by
+// cmd/storagecheck. Go tests run it through package storagetest.
+package storagecheck
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	repo "repository/pkg/model"
+	"repository/pkg/service"
+)
+
+// Check is a single conformance check.
+type Check struct {
+	Name string
+	Run  func(s service.Storage) error
+}
+
+// Checks lists all conformance checks in the order they are run.
+var Checks = []Check{
+	{"NewNode assigns unique IDs", checkNewNodeIDs},
+	{"NewNode stores a node without jobs", checkNewNodeStored},
+	{"NewNode keeps a given ID and generation", checkNewNodeGivenID},
+	{"SaveNode stores jobs", checkSaveNodeJobs},
+	{"SaveNode updates jobs", checkSaveNodeUpdatesJobs},
+	{"SaveNode removes vanished jobs", checkSaveNodeRemovesJobs},
+	{"PatchNode keeps other jobs", checkPatchNodeKeepsJobs},
+	{"GetAllNodes returns copies", checkGetAllNodesCopies},
+	{"GetNode returns a node with its jobs", checkGetNode},
+	{"DeleteNode removes a node", checkDeleteNode},
+	{"DeleteNode keeps other nodes", checkDeleteNodeKeepsOthers},
+	{"JobsByState follows jobs", checkJobsByState},
+}
+
+// Run runs all checks against the storage and returns an error for every failed check.
+func Run(s service.Storage) []error {
+	errs := make([]error, 0)
+	for _, c := range Checks {
+		if err := c.Run(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", c.Name, err))
+		}
+	}
+	return errs
+}
+
+func newNode(s service.Storage) (repo.Node, error) {
+	n := repo.Node{
+		Name: "storagetest-" + uuid.New().String(),
+		IP:   "127.0.0.1",
+		Port: ":8082",
+	}
+	id, err := s.NewNode(n)
+	n.ID = id
+	return n, err
+}
+
+// newJob returns a job with times rounded to seconds, that is the best
+// precision all SQL engines keep.
+func newJob(per float32) repo.Job {
+	start := time.Now().UTC().Truncate(time.Second)
+	return repo.Job{
+		ID:        repo.JobID{UUID: uuid.New()},
+		Per:       per,
+		Duration:  1,
+		StartTime: start,
+	}
+}
+
+func compareJobs(want, got []repo.Job) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("want %d jobs, got %d", len(want), len(got))
+	}
+	byID := make(map[repo.JobID]repo.Job)
+	for _, j := range got {
+		byID[j.ID] = j
+	}
+	for _, w := range want {
+		g, ok := byID[w.ID]
+		switch {
+		case !ok:
+			return fmt.Errorf("job %s is missing", w.ID)
+		case g.Per != w.Per || g.Duration != w.Duration:
+			return fmt.Errorf("job %s: want per=%v duration=%v, got per=%v duration=%v", w.ID, w.Per, w.Duration, g.Per, g.Duration)
+		case !g.StartTime.Equal(w.StartTime) || !g.FinishTime.Equal(w.FinishTime):
+			return fmt.Errorf("job %s: want start=%v finish=%v, got start=%v finish=%v", w.ID, w.StartTime, w.FinishTime, g.StartTime, g.FinishTime)
+		}
+	}
+	return nil
+}
+
+func checkNewNodeIDs(s service.Storage) error {
+	a, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(a.ID)
+	b, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(b.ID)
+
+	if a.ID.UUID == uuid.Nil || b.ID.UUID == uuid.Nil {
+		return fmt.Errorf("empty ID returned")
+	}
+	if a.ID == b.ID {
+		return fmt.Errorf("the same ID %s returned twice", a.ID)
+	}
+	return nil
+}
+
+func checkNewNodeGivenID(s service.Storage) error {
+	want := repo.NodeID{UUID: uuid.New()}
+	id, err := s.NewNode(repo.Node{
+		ID:         want,
+		Name:       "storagetest-" + uuid.New().String(),
+		IP:         "127.0.0.1",
+		Port:       ":8082",
+		Generation: 3,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(id)
+
+	if id != want {
+		return fmt.Errorf("want ID %s, got %s", want, id)
+	}
+	got, ok, err := s.GetNode(want)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is missing", want)
+	case got.Generation != 3:
+		return fmt.Errorf("want generation 3, got %d", got.Generation)
+	}
+	return nil
+}
+
+func checkNewNodeStored(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	case got.Name != n.Name || got.IP != n.IP || got.Port != n.Port:
+		return fmt.Errorf("want %s %s%s, got %s %s%s", n.Name, n.IP, n.Port, got.Name, got.IP, got.Port)
+	case got.JobsCount != 0 || len(got.Jobs) != 0:
+		return fmt.Errorf("new node has %d jobs count and %d jobs", got.JobsCount, len(got.Jobs))
+	}
+	return nil
+}
+
+func checkSaveNodeJobs(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	n.JobsCount = 2
+	n.Jobs = []repo.Job{newJob(10), newJob(20)}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	case got.JobsCount != n.JobsCount:
+		return fmt.Errorf("want jobs count %d, got %d", n.JobsCount, got.JobsCount)
+	}
+	return compareJobs(n.Jobs, got.Jobs)
+}
+
+func checkSaveNodeUpdatesJobs(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	j := newJob(10)
+	n.JobsCount = 1
+	n.Jobs = []repo.Job{j}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	j.Per = 100
+	j.Duration = 4
+	j.FinishTime = j.StartTime.Add(4 * time.Second)
+	n.JobsCount = 0
+	n.Jobs = []repo.Job{j}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	case got.JobsCount != 0:
+		return fmt.Errorf("want jobs count 0, got %d", got.JobsCount)
+	}
+	return compareJobs(n.Jobs, got.Jobs)
+}
+
+func checkPatchNodeKeepsJobs(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	a, b := newJob(10), newJob(20)
+	n.JobsCount = 2
+	n.Jobs = []repo.Job{a, b}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	b.Per = 100
+	b.Duration = 4
+	b.FinishTime = b.StartTime.Add(4 * time.Second)
+	c := newJob(30)
+	n.JobsCount = 2
+	n.Jobs = []repo.Job{b, c}
+	if err := s.PatchNode(n); err != nil {
+		return err
+	}
+
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	case got.JobsCount != n.JobsCount:
+		return fmt.Errorf("want jobs count %d, got %d", n.JobsCount, got.JobsCount)
+	}
+	return compareJobs([]repo.Job{a, b, c}, got.Jobs)
+}
+
+func checkDeleteNode(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	n.Jobs = []repo.Job{newJob(50)}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	if err := s.DeleteNode(n.ID); err != nil {
+		return err
+	}
+
+	_, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case ok:
+		return fmt.Errorf("node %s is still returned by GetNode", n.ID)
+	}
+	return nil
+}
+
+func checkSaveNodeRemovesJobs(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	kept := newJob(10)
+	n.Jobs = []repo.Job{kept, newJob(20), newJob(30)}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	n.Jobs = []repo.Job{kept}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	}
+	if err := compareJobs(n.Jobs, got.Jobs); err != nil {
+		return err
+	}
+
+	n.Jobs = []repo.Job{}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+	got, _, err = s.GetNode(n.ID)
+	if err != nil {
+		return err
+	}
+	return compareJobs(n.Jobs, got.Jobs)
+}
+
+func checkGetAllNodesCopies(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	n.Jobs = []repo.Job{newJob(10)}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	nodes, err := s.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	for _, got := range nodes {
+		if got.ID != n.ID {
+			continue
+		}
+		if len(got.Jobs) != 1 {
+			return fmt.Errorf("want 1 job, got %d", len(got.Jobs))
+		}
+		got.Jobs[0].Per = 99
+	}
+
+	again, _, err := s.GetNode(n.ID)
+	if err != nil {
+		return err
+	}
+	return compareJobs(n.Jobs, again.Jobs)
+}
+
+func checkGetNode(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	n.Jobs = []repo.Job{newJob(10), newJob(20)}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+
+	got, ok, err := s.GetNode(n.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is not returned by GetNode", n.ID)
+	case got.ID != n.ID || got.Name != n.Name:
+		return fmt.Errorf("want node %s %q, got %s %q", n.ID, n.Name, got.ID, got.Name)
+	}
+	if err := compareJobs(n.Jobs, got.Jobs); err != nil {
+		return err
+	}
+
+	unknown := repo.NodeID{UUID: uuid.New()}
+	if _, ok, err := s.GetNode(unknown); err != nil || ok {
+		return fmt.Errorf("unknown node %s: want not found, got ok=%v err=%v", unknown, ok, err)
+	}
+	return nil
+}
+
+func checkDeleteNodeKeepsOthers(s service.Storage) error {
+	a, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	a.Jobs = []repo.Job{newJob(10)}
+	if err := s.SaveNode(a); err != nil {
+		return err
+	}
+
+	b, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(b.ID)
+	b.Jobs = []repo.Job{newJob(20)}
+	if err := s.SaveNode(b); err != nil {
+		return err
+	}
+
+	if err := s.DeleteNode(a.ID); err != nil {
+		return err
+	}
+
+	got, ok, err := s.GetNode(b.ID)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("node %s is gone after deleting %s", b.ID, a.ID)
+	}
+	return compareJobs(b.Jobs, got.Jobs)
+}
+
+func checkJobsByState(s service.Storage) error {
+	n, err := newNode(s)
+	if err != nil {
+		return err
+	}
+	defer s.DeleteNode(n.ID)
+
+	running, finished := newJob(10), newJob(100)
+	n.Jobs = []repo.Job{running, finished}
+	if err := s.SaveNode(n); err != nil {
+		return err
+	}
+	if err := checkStates(s, n.Jobs, []repo.Job{running}, []repo.Job{finished}); err != nil {
+		return err
+	}
+
+	// A job which finishes moves to the other state.
+	done := running
+	done.Per = 100
+	n.Jobs = []repo.Job{done}
+	if err := s.PatchNode(n); err != nil {
+		return err
+	}
+	if err := checkStates(s, []repo.Job{done, finished}, nil, []repo.Job{done, finished}); err != nil {
+		return fmt.Errorf("after a job finished: %v", err)
+	}
+
+	if err := s.DeleteNode(n.ID); err != nil {
+		return err
+	}
+	if err := checkStates(s, []repo.Job{done, finished}, nil, nil); err != nil {
+		return fmt.Errorf("after the node was deleted: %v", err)
+	}
+	return nil
+}
+
+// checkStates compares the jobs of the node, of all jobs in the storage, with
+// the running and finished ones.
+func checkStates(s service.Storage, jobs, running, finished []repo.Job) error {
+	ids := make(map[repo.JobID]bool)
+	for _, j := range jobs {
+		ids[j.ID] = true
+	}
+	for state, want := range map[repo.JobState][]repo.Job{repo.JobRunning: running, repo.JobFinished: finished} {
+		all, err := s.JobsByState(state)
+		if err != nil {
+			return err
+		}
+		got := make([]repo.Job, 0)
+		for _, j := range all {
+			if ids[j.ID] {
+				got = append(got, j)
+			}
+		}
+		if err := compareJobs(want, got); err != nil {
+			return fmt.Errorf("%s jobs: %v", state, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Package storagetest runs the storagecheck conformance suite in go tests of
+// storages. It imports package testing, so only _test files may import it.
+package storagetest
+
+import (
+	"testing"
+
+	"repository/pkg/service"
+	"repository/pkg/storage/storagecheck"
+)
+
+// Test runs every check as a subtest of t.
+func Test(t *testing.T, s service.Storage) {
+	for _, c := range storagecheck.Checks {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			if err := c.Run(s); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/nats-io/go-nats"
+	"github.com/sony/gobreaker"
+
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	natstransport "github.com/go-kit/kit/transport/nats"
+
+	"repository/pkg/deadletter"
+	"repository/pkg/dispatch"
+	"repository/pkg/endpoint"
+	"repository/pkg/service"
+)
+
+// DeadLetters takes NATS messages which failed, see the deadletter package.
+type DeadLetters interface {
+	Send(subject, stage string, err error, payload []byte)
+}
+
+type natsMessageKey struct{}
+
+// receivedMessage is a message as it was received, before a trace envelope
+// was unwrapped, so that a replay continues the trace.
+type receivedMessage struct {
+	subject string
+	data    []byte
+}
+
+// keepNATSMessage is a transport/nats.RequestFunc which keeps the subject and
+// the data of the message in the context for dead letters. It comes before
+// the functions which change the message.
+func keepNATSMessage(ctx context.Context, msg *nats.Msg) context.Context {
+	return context.WithValue(ctx, natsMessageKey{}, receivedMessage{subject: msg.Subject, data: msg.Data})
+}
+
+// sendDeadLetter sends the message kept in the context to letters.
+func sendDeadLetter(ctx context.Context, letters DeadLetters, stage string, err error) {
+	if m, ok := ctx.Value(natsMessageKey{}).(receivedMessage); ok {
+		letters.Send(m.subject, stage, err, m.data)
+	}
+}
+
+// deadLetterDecoder returns a transport/nats.DecodeRequestFunc which sends
+// messages dec fails to decode to letters. The subscriber replies the error
+// only to requests, published messages would be lost otherwise.
+func deadLetterDecoder(letters DeadLetters, dec natstransport.DecodeRequestFunc) natstransport.DecodeRequestFunc {
+	if letters == nil {
+		return dec
+	}
+	return func(ctx context.Context, msg *nats.Msg) (interface{}, error) {
+		request, err := dec(ctx, msg)
+		if err != nil {
+			sendDeadLetter(ctx, letters, deadletter.StageDecode, err)
+		}
+		return request, err
+	}
+}
+
+// deadLetterEndpoint returns an endpoint which sends messages to letters
+// when e fails, with an error or with a failed response, unless the error is
+// a reply of the protocol.
+func deadLetterEndpoint(letters DeadLetters, e kitendpoint.Endpoint) kitendpoint.Endpoint {
+	if letters == nil {
+		return e
+	}
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := e(ctx, request)
+		failed := err
+		if f, ok := response.(endpoint.Failer); ok && failed == nil {
+			failed = f.Failed()
+		}
+		if failed != nil && !protocolError(failed) {
+			sendDeadLetter(ctx, letters, deadletter.StageEndpoint, failed)
+		}
+		return response, err
+	}
+}
+
+// protocolErrors are answers the caller acts on, a worker registers again
+// after ErrUnknownNode, or limits it has to back off from. A message failed
+// with one of them was handled, it isn't a dead letter.
+var protocolErrors = []error{
+	service.ErrUnknownNode,
+	service.ErrInvalidNodeID,
+	service.ErrNodeAlreadyExist,
+	service.ErrEmptyRepo,
+	service.ErrNoHealthyNodes,
+	service.ErrNoDeadLetters,
+	deadletter.ErrUnknownLetter,
+	dispatch.ErrQueueFull,
+	ratelimit.ErrLimited,
+	gobreaker.ErrOpenState,
+	gobreaker.ErrTooManyRequests,
+}
+
+// protocolError tells whether err is one of protocolErrors. Errors are
+// compared by their messages, the ones of calls forwarded to the leader
+// come back as text.
+func protocolError(err error) bool {
+	for _, e := range protocolErrors {
+		if err.Error() == e.Error() {
+			return true
+		}
+	}
+	return false
+}
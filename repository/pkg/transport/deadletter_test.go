@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/nats-io/go-nats"
+
+	"repository/pkg/deadletter"
+	"repository/pkg/endpoint"
+	"repository/pkg/service"
+	workermodel "worker/pkg/model"
+)
+
+func TestDeadLetterEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		response interface{}
+		err      error
+		sent     bool
+	}{
+		{"success", endpoint.HeartbeatResponse{}, nil, false},
+		{"unknown node", endpoint.HeartbeatResponse{Err: service.ErrUnknownNode}, nil, false},
+		{"forwarded unknown node", endpoint.HeartbeatResponse{Err: errors.New(service.ErrUnknownNode.Error())}, nil, false},
+		{"limited", nil, ratelimit.ErrLimited, false},
+		{"storage", endpoint.HeartbeatResponse{Err: service.ErrRepoUnevailable}, nil, true},
+		{"internal", nil, errors.New("boom"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			letters := &letterBox{}
+			e := deadLetterEndpoint(letters, func(context.Context, interface{}) (interface{}, error) {
+				return tt.response, tt.err
+			})
+			ctx := keepNATSMessage(context.Background(), &nats.Msg{Subject: workermodel.HeartbeatSubject, Data: []byte("{}")})
+			e(ctx, nil)
+
+			switch {
+			case !tt.sent && len(letters.sent) > 0:
+				t.Errorf("want no letter, got %+v", letters.sent)
+			case tt.sent && len(letters.sent) != 1:
+				t.Errorf("want a letter, got %d", len(letters.sent))
+			case tt.sent && (letters.sent[0].Subject != workermodel.HeartbeatSubject || letters.sent[0].Stage != deadletter.StageEndpoint || string(letters.sent[0].Payload) != "{}"):
+				t.Errorf("want the heartbeat at the endpoint stage, got %+v", letters.sent[0])
+			}
+		})
+	}
+}
+
+type letterBox struct {
+	sent []deadletter.Letter
+}
+
+func (b *letterBox) Send(subject, stage string, err error, payload []byte) {
+	b.sent = append(b.sent, deadletter.Letter{Subject: subject, Stage: stage, Err: err.Error(), Payload: payload})
+}
@@ -11,6 +11,7 @@ import (
 	stdopentracing "github.com/opentracing/opentracing-go"
 
 	timestamp "github.com/golang/protobuf/ptypes"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
 
 	"github.com/go-kit/kit/circuitbreaker"
 	kitendpoint "github.com/go-kit/kit/endpoint"
@@ -22,16 +23,31 @@ import (
 
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 
-	"repository/pkg/endpoint"
-	"repository/pkg/service"
 	pb "repository/pb"
+	"repository/pkg/availability"
+	"repository/pkg/backup"
+	"repository/pkg/deadletter"
+	"repository/pkg/endpoint"
 	repo "repository/pkg/model"
+	"repository/pkg/service"
 )
 
 type grpcServer struct {
 	registerNode grpctransport.Handler
 	getAllNodes  grpctransport.Handler
 	newJob       grpctransport.Handler
+	export       grpctransport.Handler
+	import_      grpctransport.Handler
+	heartbeat    grpctransport.Handler
+
+	getAvailability     grpctransport.Handler
+	getNodeAvailability grpctransport.Handler
+
+	drainNode      grpctransport.Handler
+	deregisterNode grpctransport.Handler
+
+	listDeadLetters  grpctransport.Handler
+	replayDeadLetter grpctransport.Handler
 }
 
 // NewGRPCServer makes a set of endpoints available as a gRPC AddServer.
@@ -59,6 +75,60 @@ func NewGRPCServer(endpoints endpoint.EndpointSet, otTracer stdopentracing.Trace
 			encodeGRPCNewJobResponse,
 			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "NewJob", logger)))...,
 		),
+		export: grpctransport.NewServer(
+			endpoints.ExportEndpoint,
+			decodeGRPCExportRequest,
+			encodeGRPCExportResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "Export", logger)))...,
+		),
+		import_: grpctransport.NewServer(
+			endpoints.ImportEndpoint,
+			decodeGRPCImportRequest,
+			encodeGRPCImportResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "Import", logger)))...,
+		),
+		heartbeat: grpctransport.NewServer(
+			endpoints.HeartbeatEndpoint,
+			decodeGRPCHeartbeatRequest,
+			encodeGRPCHeartbeatResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "Heartbeat", logger)))...,
+		),
+		getAvailability: grpctransport.NewServer(
+			endpoints.GetAvailabilityEndpoint,
+			decodeGRPCGetAvailabilityRequest,
+			encodeGRPCGetAvailabilityResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "GetAvailability", logger)))...,
+		),
+		getNodeAvailability: grpctransport.NewServer(
+			endpoints.GetNodeAvailabilityEndpoint,
+			decodeGRPCGetNodeAvailabilityRequest,
+			encodeGRPCGetNodeAvailabilityResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "GetNodeAvailability", logger)))...,
+		),
+		drainNode: grpctransport.NewServer(
+			endpoints.DrainNodeEndpoint,
+			decodeGRPCDrainNodeRequest,
+			encodeGRPCDrainNodeResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "DrainNode", logger)))...,
+		),
+		deregisterNode: grpctransport.NewServer(
+			endpoints.DeregisterNodeEndpoint,
+			decodeGRPCDeregisterNodeRequest,
+			encodeGRPCDeregisterNodeResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "DeregisterNode", logger)))...,
+		),
+		listDeadLetters: grpctransport.NewServer(
+			endpoints.ListDeadLettersEndpoint,
+			decodeGRPCListDeadLettersRequest,
+			encodeGRPCListDeadLettersResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "ListDeadLetters", logger)))...,
+		),
+		replayDeadLetter: grpctransport.NewServer(
+			endpoints.ReplayDeadLetterEndpoint,
+			decodeGRPCReplayDeadLetterRequest,
+			encodeGRPCReplayDeadLetterResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "ReplayDeadLetter", logger)))...,
+		),
 	}
 }
 
@@ -86,17 +156,103 @@ func (s *grpcServer) NewJob(ctx context.Context, req *pb.NewJobRequest) (*pb.New
 	return rep.(*pb.NewJobReply), nil
 }
 
+func (s *grpcServer) Export(ctx context.Context, req *pb.ExportRequest) (*pb.ExportReply, error) {
+	_, rep, err := s.export.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.ExportReply), nil
+}
+
+func (s *grpcServer) Import(ctx context.Context, req *pb.ImportRequest) (*pb.ImportReply, error) {
+	_, rep, err := s.import_.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.ImportReply), nil
+}
+
+func (s *grpcServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatReply, error) {
+	_, rep, err := s.heartbeat.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.HeartbeatReply), nil
+}
+
+func (s *grpcServer) GetAvailability(ctx context.Context, req *pb.GetAvailabilityRequest) (*pb.GetAvailabilityReply, error) {
+	_, rep, err := s.getAvailability.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.GetAvailabilityReply), nil
+}
+
+func (s *grpcServer) GetNodeAvailability(ctx context.Context, req *pb.GetNodeAvailabilityRequest) (*pb.GetNodeAvailabilityReply, error) {
+	_, rep, err := s.getNodeAvailability.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.GetNodeAvailabilityReply), nil
+}
+
+func (s *grpcServer) DrainNode(ctx context.Context, req *pb.DrainNodeRequest) (*pb.DrainNodeReply, error) {
+	_, rep, err := s.drainNode.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.DrainNodeReply), nil
+}
+
+func (s *grpcServer) DeregisterNode(ctx context.Context, req *pb.DeregisterNodeRequest) (*pb.DeregisterNodeReply, error) {
+	_, rep, err := s.deregisterNode.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.DeregisterNodeReply), nil
+}
+
+func (s *grpcServer) ListDeadLetters(ctx context.Context, req *pb.ListDeadLettersRequest) (*pb.ListDeadLettersReply, error) {
+	_, rep, err := s.listDeadLetters.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.ListDeadLettersReply), nil
+}
+
+func (s *grpcServer) ReplayDeadLetter(ctx context.Context, req *pb.ReplayDeadLetterRequest) (*pb.ReplayDeadLetterReply, error) {
+	_, rep, err := s.replayDeadLetter.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.ReplayDeadLetterReply), nil
+}
+
+// Outgoing requests of a client to all methods of the repository are limited
+// to DefaultClientRate per second, after a burst of clientBurst.
+const (
+	DefaultClientRate = rate.Limit(1)
+	clientBurst       = 100
+)
+
 // NewGRPCClient returns an RepoService backed by a gRPC server at the other end
 // of the conn. The caller is responsible for constructing the conn, and
 // eventually closing the underlying transport. We bake-in certain middlewares,
 // implementing the client library pattern.
 func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, logger log.Logger) service.Service {
+	return NewGRPCClientWithRate(conn, DefaultClientRate, otTracer, logger)
+}
+
+// NewGRPCClientWithRate is NewGRPCClient with outgoing requests limited to
+// limit per second, rate.Inf doesn't limit them. A replica forwarding to the
+// leader passes on requests of every worker, it isn't limited.
+func NewGRPCClientWithRate(conn *grpc.ClientConn, limit rate.Limit, otTracer stdopentracing.Tracer, logger log.Logger) service.Service {
 	// We construct a single ratelimiter middleware, to limit the total outgoing
 	// QPS from this client to all methods on the remote instance. We also
 	// construct per-endpoint circuitbreaker middlewares to demonstrate how
 	// that's done, although they could easily be combined into a single breaker
 	// for the entire remote instance, too.
-	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), 100))
+	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(limit, clientBurst))
 
 	// global client middlewares
 	options := []grpctransport.ClientOption{}
@@ -162,6 +318,177 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, logger
 		}))(newJobEndpoint)
 	}
 
+	var exportEndpoint kitendpoint.Endpoint
+	{
+		exportEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"Export",
+			encodeGRPCExportRequest,
+			decodeGRPCExportResponse,
+			pb.ExportReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		exportEndpoint = opentracing.TraceClient(otTracer, "Export")(exportEndpoint)
+		exportEndpoint = limiter(exportEndpoint)
+		exportEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Export",
+			Timeout: 30 * time.Second,
+		}))(exportEndpoint)
+	}
+
+	var importEndpoint kitendpoint.Endpoint
+	{
+		importEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"Import",
+			encodeGRPCImportRequest,
+			decodeGRPCImportResponse,
+			pb.ImportReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		importEndpoint = opentracing.TraceClient(otTracer, "Import")(importEndpoint)
+		importEndpoint = limiter(importEndpoint)
+		importEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Import",
+			Timeout: 30 * time.Second,
+		}))(importEndpoint)
+	}
+
+	var heartbeatEndpoint kitendpoint.Endpoint
+	{
+		heartbeatEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"Heartbeat",
+			encodeGRPCHeartbeatRequest,
+			decodeGRPCHeartbeatResponse,
+			pb.HeartbeatReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		heartbeatEndpoint = opentracing.TraceClient(otTracer, "Heartbeat")(heartbeatEndpoint)
+		heartbeatEndpoint = limiter(heartbeatEndpoint)
+		heartbeatEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Heartbeat",
+			Timeout: 30 * time.Second,
+		}))(heartbeatEndpoint)
+	}
+
+	var getAvailabilityEndpoint kitendpoint.Endpoint
+	{
+		getAvailabilityEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"GetAvailability",
+			encodeGRPCGetAvailabilityRequest,
+			decodeGRPCGetAvailabilityResponse,
+			pb.GetAvailabilityReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		getAvailabilityEndpoint = opentracing.TraceClient(otTracer, "GetAvailability")(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = limiter(getAvailabilityEndpoint)
+		getAvailabilityEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "GetAvailability",
+			Timeout: 30 * time.Second,
+		}))(getAvailabilityEndpoint)
+	}
+
+	var getNodeAvailabilityEndpoint kitendpoint.Endpoint
+	{
+		getNodeAvailabilityEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"GetNodeAvailability",
+			encodeGRPCGetNodeAvailabilityRequest,
+			decodeGRPCGetNodeAvailabilityResponse,
+			pb.GetNodeAvailabilityReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		getNodeAvailabilityEndpoint = opentracing.TraceClient(otTracer, "GetNodeAvailability")(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = limiter(getNodeAvailabilityEndpoint)
+		getNodeAvailabilityEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "GetNodeAvailability",
+			Timeout: 30 * time.Second,
+		}))(getNodeAvailabilityEndpoint)
+	}
+
+	var drainNodeEndpoint kitendpoint.Endpoint
+	{
+		drainNodeEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"DrainNode",
+			encodeGRPCDrainNodeRequest,
+			decodeGRPCDrainNodeResponse,
+			pb.DrainNodeReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		drainNodeEndpoint = opentracing.TraceClient(otTracer, "DrainNode")(drainNodeEndpoint)
+		drainNodeEndpoint = limiter(drainNodeEndpoint)
+		drainNodeEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "DrainNode",
+			Timeout: 30 * time.Second,
+		}))(drainNodeEndpoint)
+	}
+
+	var deregisterNodeEndpoint kitendpoint.Endpoint
+	{
+		deregisterNodeEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"DeregisterNode",
+			encodeGRPCDeregisterNodeRequest,
+			decodeGRPCDeregisterNodeResponse,
+			pb.DeregisterNodeReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		deregisterNodeEndpoint = opentracing.TraceClient(otTracer, "DeregisterNode")(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = limiter(deregisterNodeEndpoint)
+		deregisterNodeEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "DeregisterNode",
+			Timeout: 30 * time.Second,
+		}))(deregisterNodeEndpoint)
+	}
+
+	var listDeadLettersEndpoint kitendpoint.Endpoint
+	{
+		listDeadLettersEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"ListDeadLetters",
+			encodeGRPCListDeadLettersRequest,
+			decodeGRPCListDeadLettersResponse,
+			pb.ListDeadLettersReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		listDeadLettersEndpoint = opentracing.TraceClient(otTracer, "ListDeadLetters")(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = limiter(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "ListDeadLetters",
+			Timeout: 30 * time.Second,
+		}))(listDeadLettersEndpoint)
+	}
+
+	var replayDeadLetterEndpoint kitendpoint.Endpoint
+	{
+		replayDeadLetterEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.repo.Repo",
+			"ReplayDeadLetter",
+			encodeGRPCReplayDeadLetterRequest,
+			decodeGRPCReplayDeadLetterResponse,
+			pb.ReplayDeadLetterReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		replayDeadLetterEndpoint = opentracing.TraceClient(otTracer, "ReplayDeadLetter")(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = limiter(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "ReplayDeadLetter",
+			Timeout: 30 * time.Second,
+		}))(replayDeadLetterEndpoint)
+	}
+
 	// Returning the endpoint.EndpointSet as a service.Service relies on the
 	// endpoint.EndpointSet implementing the Service methods. That's just a simple bit
 	// of glue code.
@@ -169,6 +496,18 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, logger
 		RegisterNodeEndpoint: registerNodeEndpoint,
 		GetAllNodesEndpoint:  getAllNodesEndpoint,
 		NewJobEndpoint:       newJobEndpoint,
+		ExportEndpoint:       exportEndpoint,
+		ImportEndpoint:       importEndpoint,
+		HeartbeatEndpoint:    heartbeatEndpoint,
+
+		GetAvailabilityEndpoint:     getAvailabilityEndpoint,
+		GetNodeAvailabilityEndpoint: getNodeAvailabilityEndpoint,
+
+		DrainNodeEndpoint:      drainNodeEndpoint,
+		DeregisterNodeEndpoint: deregisterNodeEndpoint,
+
+		ListDeadLettersEndpoint:  listDeadLettersEndpoint,
+		ReplayDeadLetterEndpoint: replayDeadLetterEndpoint,
 	}
 }
 
@@ -198,28 +537,28 @@ func err2str(err error) string {
 // user-domain RegisterNode request to a gRPC RegisterNode request. Primarily useful in a client.
 func encodeGRPCRegisterNodeRequest(_ context.Context, request interface{}) (interface{}, error) {
 	req := request.(endpoint.RegisterNodeRequest)
-	return &pb.RegisterNodeRequest{Name: req.Name, NodeIP: req.IP, NodePort: req.Port}, nil
+	return &pb.RegisterNodeRequest{NodeID: req.ID, Name: req.Name, NodeIP: req.IP, NodePort: req.Port}, nil
 }
 
 // decodeGRPCRegisterNodeRequest is a transport/grpc.DecodeRequestFunc that converts a
 // gRPC RegisterNode request to a user-domain RegisterNode request. Primarily useful in a server.
 func decodeGRPCRegisterNodeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
 	req := grpcReq.(*pb.RegisterNodeRequest)
-	return endpoint.RegisterNodeRequest{Name: req.Name, IP: req.NodeIP, Port: req.NodePort}, nil
+	return endpoint.RegisterNodeRequest{ID: req.NodeID, Name: req.Name, IP: req.NodeIP, Port: req.NodePort}, nil
 }
 
 // encodeGRPCRegisterNodeResponse is a transport/grpc.EncodeResponseFunc that converts a
 // user-domain RegisterNode response to a gRPC RegisterNode reply. Primarily useful in a server.
 func encodeGRPCRegisterNodeResponse(_ context.Context, response interface{}) (interface{}, error) {
 	resp := response.(endpoint.RegisterNodeResponse)
-	return &pb.RegisterNodeReply{NodeID: resp.ID, Err: err2str(resp.Err)}, nil
+	return &pb.RegisterNodeReply{NodeID: resp.ID, Generation: resp.Generation, Err: err2str(resp.Err)}, nil
 }
 
 // decodeGRPCRegisterNodeResponse is a transport/grpc.DecodeResponseFunc that converts a
 // gRPC RegisterNode reply to a user-domain RegisterNode response. Primarily useful in a client.
 func decodeGRPCRegisterNodeResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
 	reply := grpcReply.(*pb.RegisterNodeReply)
-	return endpoint.RegisterNodeResponse{ID: reply.NodeID, Err: str2err(reply.Err)}, nil
+	return endpoint.RegisterNodeResponse{ID: reply.NodeID, Generation: reply.Generation, Err: str2err(reply.Err)}, nil
 }
 
 // ********** GetAllNodes **********
@@ -247,28 +586,19 @@ func encodeGRPCGetAllNodesResponse(_ context.Context, response interface{}) (int
 	pbNodes := make([]*pb.Node, 0)
 	for _, n := range resp.Nodes {
 
-		// conver []repo.Job to []*pb.Job
-		pbJobs := make([]*pb.Job, 0)
-		for _, j := range n.Jobs {
-			st, _ := timestamp.TimestampProto(j.StartTime)
-			ft, _ := timestamp.TimestampProto(j.FinishTime)
-			pbJob := &pb.Job{
-				ID:         j.ID.String(),
-				Per:        j.Per,
-				Duration:   j.Duration,
-				StartTime:  st,
-				FinishTime: ft,
-			}
-			pbJobs = append(pbJobs, pbJob)
-		}
+		pbJobs := jobsToPB(n.Jobs)
 
+		since, _ := timestamp.TimestampProto(n.StateSince)
 		pbNode := &pb.Node{
-			ID:        n.ID.String(),
-			Name:      n.Name,
-			IP:        n.IP,
-			Port:      n.Port,
-			JobsCount: int32(n.JobsCount),
-			Jobs:      pbJobs,
+			ID:         n.ID.String(),
+			Name:       n.Name,
+			IP:         n.IP,
+			Port:       n.Port,
+			JobsCount:  int32(n.JobsCount),
+			Jobs:       pbJobs,
+			State:      string(n.State),
+			StateSince: since,
+			Generation: n.Generation,
 		}
 		pbNodes = append(pbNodes, pbNode)
 	}
@@ -284,30 +614,20 @@ func decodeGRPCGetAllNodesResponse(_ context.Context, grpcReply interface{}) (in
 	// conver []*pb.Node to []repo.Node
 	nodes := make([]repo.Node, 0)
 	for _, n := range reply.Nodes {
-		// conver []*pb.Job to []repo.Job
-		jobs := make([]repo.Job, 0)
-		for _, j := range n.Jobs {
-			id, _ := uuid.Parse(j.ID)
-			st, _ := timestamp.Timestamp(j.StartTime)
-			ft, _ := timestamp.Timestamp(j.FinishTime)
-			job := repo.Job{
-				ID:         repo.JobID{id},
-				Per:        j.Per,
-				Duration:   j.Duration,
-				StartTime:  st,
-				FinishTime: ft,
-			}
-			jobs = append(jobs, job)
-		}
+		jobs := jobsFromPB(n.Jobs)
 
 		id, _ := uuid.Parse(n.ID)
+		since, _ := timestamp.Timestamp(n.StateSince)
 		node := repo.Node{
-			ID:        repo.NodeID{id},
-			Name:      n.Name,
-			IP:        n.IP,
-			Port:      n.Port,
-			JobsCount: int(n.JobsCount),
-			Jobs:      jobs,
+			ID:         repo.NodeID{UUID: id},
+			Name:       n.Name,
+			IP:         n.IP,
+			Port:       n.Port,
+			JobsCount:  int(n.JobsCount),
+			Jobs:       jobs,
+			State:      repo.NodeState(n.State),
+			StateSince: since,
+			Generation: n.Generation,
 		}
 		nodes = append(nodes, node)
 	}
@@ -344,3 +664,441 @@ func decodeGRPCNewJobResponse(_ context.Context, grpcReply interface{}) (interfa
 	reply := grpcReply.(*pb.NewJobReply)
 	return endpoint.NewJobResponse{ID: reply.ID, Err: str2err(reply.Err)}, nil
 }
+
+// ********** Export **********
+
+// encodeGRPCExportRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain Export request to a gRPC Export request. Primarily useful in a client.
+func encodeGRPCExportRequest(_ context.Context, request interface{}) (interface{}, error) {
+	return &pb.ExportRequest{}, nil
+}
+
+// decodeGRPCExportRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC Export request to a user-domain Export request. Primarily useful in a server.
+func decodeGRPCExportRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	return endpoint.ExportRequest{}, nil
+}
+
+// encodeGRPCExportResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain Export response to a gRPC Export reply. Primarily useful in a server.
+func encodeGRPCExportResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ExportResponse)
+	return &pb.ExportReply{Data: resp.Data, Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCExportResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC Export reply to a user-domain Export response. Primarily useful in a client.
+func decodeGRPCExportResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ExportReply)
+	return endpoint.ExportResponse{Data: reply.Data, Err: str2err(reply.Err)}, nil
+}
+
+// ********** Import **********
+
+// encodeGRPCImportRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain Import request to a gRPC Import request. Primarily useful in a client.
+func encodeGRPCImportRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.ImportRequest)
+	return &pb.ImportRequest{Data: req.Data}, nil
+}
+
+// decodeGRPCImportRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC Import request to a user-domain Import request. Primarily useful in a server.
+func decodeGRPCImportRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ImportRequest)
+	return endpoint.ImportRequest{Data: req.Data}, nil
+}
+
+// encodeGRPCImportResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain Import response to a gRPC Import reply. Primarily useful in a server.
+func encodeGRPCImportResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ImportResponse)
+	return &pb.ImportReply{
+		Nodes:  int32(resp.Stats.Nodes),
+		Jobs:   int32(resp.Stats.Jobs),
+		Events: int32(resp.Stats.Events),
+		Queued: int32(resp.Stats.Queued),
+		Err:    err2str(resp.Err),
+	}, nil
+}
+
+// decodeGRPCImportResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC Import reply to a user-domain Import response. Primarily useful in a client.
+func decodeGRPCImportResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ImportReply)
+	stats := backup.Stats{
+		Nodes:  int(reply.Nodes),
+		Jobs:   int(reply.Jobs),
+		Events: int(reply.Events),
+		Queued: int(reply.Queued),
+	}
+	return endpoint.ImportResponse{Stats: stats, Err: str2err(reply.Err)}, nil
+}
+
+// ********** Heartbeat **********
+
+// encodeGRPCHeartbeatRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain Heartbeat request to a gRPC Heartbeat request. Primarily useful in a client.
+func encodeGRPCHeartbeatRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.HeartbeatRequest)
+	return &pb.HeartbeatRequest{
+		NodeID:    req.NodeID,
+		JobsCount: int32(req.JobsCount),
+		Jobs:      jobsToPB(req.Jobs),
+		Full:      req.Full,
+	}, nil
+}
+
+// decodeGRPCHeartbeatRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC Heartbeat request to a user-domain Heartbeat request. Primarily useful in a server.
+func decodeGRPCHeartbeatRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.HeartbeatRequest)
+	return endpoint.HeartbeatRequest{
+		NodeID:    req.NodeID,
+		JobsCount: int(req.JobsCount),
+		Jobs:      jobsFromPB(req.Jobs),
+		Full:      req.Full,
+	}, nil
+}
+
+// encodeGRPCHeartbeatResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain Heartbeat response to a gRPC Heartbeat reply. Primarily useful in a server.
+func encodeGRPCHeartbeatResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.HeartbeatResponse)
+	return &pb.HeartbeatReply{Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCHeartbeatResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC Heartbeat reply to a user-domain Heartbeat response. Primarily useful in a client.
+func decodeGRPCHeartbeatResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.HeartbeatReply)
+	return endpoint.HeartbeatResponse{Err: str2err(reply.Err)}, nil
+}
+
+// ********** GetAvailability **********
+
+// encodeGRPCGetAvailabilityRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain GetAvailability request to a gRPC GetAvailability request. Primarily useful in a client.
+func encodeGRPCGetAvailabilityRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.GetAvailabilityRequest)
+	return &pb.GetAvailabilityRequest{Incidents: int32(req.Incidents)}, nil
+}
+
+// decodeGRPCGetAvailabilityRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC GetAvailability request to a user-domain GetAvailability request. Primarily useful in a server.
+func decodeGRPCGetAvailabilityRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetAvailabilityRequest)
+	return endpoint.GetAvailabilityRequest{Incidents: int(req.Incidents)}, nil
+}
+
+// encodeGRPCGetAvailabilityResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain GetAvailability response to a gRPC GetAvailability reply. Primarily useful in a server.
+func encodeGRPCGetAvailabilityResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.GetAvailabilityResponse)
+	nodes := make([]*pb.Availability, 0, len(resp.Reports))
+	for _, r := range resp.Reports {
+		nodes = append(nodes, availabilityToPB(r))
+	}
+	return &pb.GetAvailabilityReply{Nodes: nodes, Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCGetAvailabilityResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC GetAvailability reply to a user-domain GetAvailability response. Primarily useful in a client.
+func decodeGRPCGetAvailabilityResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetAvailabilityReply)
+	reports := make([]availability.Report, 0, len(reply.Nodes))
+	for _, a := range reply.Nodes {
+		reports = append(reports, availabilityFromPB(a))
+	}
+	return endpoint.GetAvailabilityResponse{Reports: reports, Err: str2err(reply.Err)}, nil
+}
+
+// ********** GetNodeAvailability **********
+
+// encodeGRPCGetNodeAvailabilityRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain GetNodeAvailability request to a gRPC GetNodeAvailability request. Primarily useful in a client.
+func encodeGRPCGetNodeAvailabilityRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.GetNodeAvailabilityRequest)
+	return &pb.GetNodeAvailabilityRequest{NodeID: req.NodeID, Incidents: int32(req.Incidents)}, nil
+}
+
+// decodeGRPCGetNodeAvailabilityRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC GetNodeAvailability request to a user-domain GetNodeAvailability request. Primarily useful in a server.
+func decodeGRPCGetNodeAvailabilityRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetNodeAvailabilityRequest)
+	return endpoint.GetNodeAvailabilityRequest{NodeID: req.NodeID, Incidents: int(req.Incidents)}, nil
+}
+
+// encodeGRPCGetNodeAvailabilityResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain GetNodeAvailability response to a gRPC GetNodeAvailability reply. Primarily useful in a server.
+func encodeGRPCGetNodeAvailabilityResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.GetNodeAvailabilityResponse)
+	if resp.Err != nil {
+		return &pb.GetNodeAvailabilityReply{Err: err2str(resp.Err)}, nil
+	}
+	return &pb.GetNodeAvailabilityReply{Node: availabilityToPB(resp.Report)}, nil
+}
+
+// decodeGRPCGetNodeAvailabilityResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC GetNodeAvailability reply to a user-domain GetNodeAvailability response. Primarily useful in a client.
+func decodeGRPCGetNodeAvailabilityResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetNodeAvailabilityReply)
+	if reply.Err != "" || reply.Node == nil {
+		return endpoint.GetNodeAvailabilityResponse{Err: str2err(reply.Err)}, nil
+	}
+	return endpoint.GetNodeAvailabilityResponse{Report: availabilityFromPB(reply.Node)}, nil
+}
+
+// ********** DrainNode **********
+
+// encodeGRPCDrainNodeRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain DrainNode request to a gRPC DrainNode request. Primarily useful in a client.
+func encodeGRPCDrainNodeRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.DrainNodeRequest)
+	return &pb.DrainNodeRequest{NodeID: req.NodeID}, nil
+}
+
+// decodeGRPCDrainNodeRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC DrainNode request to a user-domain DrainNode request. Primarily useful in a server.
+func decodeGRPCDrainNodeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DrainNodeRequest)
+	return endpoint.DrainNodeRequest{NodeID: req.NodeID}, nil
+}
+
+// encodeGRPCDrainNodeResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain DrainNode response to a gRPC DrainNode reply. Primarily useful in a server.
+func encodeGRPCDrainNodeResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.DrainNodeResponse)
+	return &pb.DrainNodeReply{Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCDrainNodeResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC DrainNode reply to a user-domain DrainNode response. Primarily useful in a client.
+func decodeGRPCDrainNodeResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.DrainNodeReply)
+	return endpoint.DrainNodeResponse{Err: str2err(reply.Err)}, nil
+}
+
+// ********** DeregisterNode **********
+
+// encodeGRPCDeregisterNodeRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain DeregisterNode request to a gRPC DeregisterNode request. Primarily useful in a client.
+func encodeGRPCDeregisterNodeRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.DeregisterNodeRequest)
+	return &pb.DeregisterNodeRequest{NodeID: req.NodeID, Unfinished: jobsToPB(req.Unfinished)}, nil
+}
+
+// decodeGRPCDeregisterNodeRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC DeregisterNode request to a user-domain DeregisterNode request. Primarily useful in a server.
+func decodeGRPCDeregisterNodeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DeregisterNodeRequest)
+	return endpoint.DeregisterNodeRequest{NodeID: req.NodeID, Unfinished: jobsFromPB(req.Unfinished)}, nil
+}
+
+// encodeGRPCDeregisterNodeResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain DeregisterNode response to a gRPC DeregisterNode reply. Primarily useful in a server.
+func encodeGRPCDeregisterNodeResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.DeregisterNodeResponse)
+	return &pb.DeregisterNodeReply{Rescheduled: int32(resp.Rescheduled), Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCDeregisterNodeResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC DeregisterNode reply to a user-domain DeregisterNode response. Primarily useful in a client.
+func decodeGRPCDeregisterNodeResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.DeregisterNodeReply)
+	return endpoint.DeregisterNodeResponse{Rescheduled: int(reply.Rescheduled), Err: str2err(reply.Err)}, nil
+}
+
+// ********** ListDeadLetters **********
+
+// encodeGRPCListDeadLettersRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain ListDeadLetters request to a gRPC ListDeadLetters request. Primarily useful in a client.
+func encodeGRPCListDeadLettersRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.ListDeadLettersRequest)
+	return &pb.ListDeadLettersRequest{Limit: int32(req.Limit)}, nil
+}
+
+// decodeGRPCListDeadLettersRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC ListDeadLetters request to a user-domain ListDeadLetters request. Primarily useful in a server.
+func decodeGRPCListDeadLettersRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListDeadLettersRequest)
+	return endpoint.ListDeadLettersRequest{Limit: int(req.Limit)}, nil
+}
+
+// encodeGRPCListDeadLettersResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ListDeadLetters response to a gRPC ListDeadLetters reply. Primarily useful in a server.
+func encodeGRPCListDeadLettersResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ListDeadLettersResponse)
+	letters := make([]*pb.DeadLetter, 0, len(resp.Letters))
+	for _, l := range resp.Letters {
+		letters = append(letters, &pb.DeadLetter{
+			ID:      l.ID,
+			Subject: l.Subject,
+			Stage:   l.Stage,
+			Err:     l.Err,
+			Payload: l.Payload,
+			Time:    timeToPB(l.Time),
+			Replica: l.Replica,
+		})
+	}
+	return &pb.ListDeadLettersReply{Letters: letters, Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCListDeadLettersResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC ListDeadLetters reply to a user-domain ListDeadLetters response. Primarily useful in a client.
+func decodeGRPCListDeadLettersResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListDeadLettersReply)
+	letters := make([]deadletter.Letter, 0, len(reply.Letters))
+	for _, l := range reply.Letters {
+		letters = append(letters, deadletter.Letter{
+			ID:      l.ID,
+			Subject: l.Subject,
+			Stage:   l.Stage,
+			Err:     l.Err,
+			Payload: l.Payload,
+			Time:    timeFromPB(l.Time),
+			Replica: l.Replica,
+		})
+	}
+	return endpoint.ListDeadLettersResponse{Letters: letters, Err: str2err(reply.Err)}, nil
+}
+
+// ********** ReplayDeadLetter **********
+
+// encodeGRPCReplayDeadLetterRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain ReplayDeadLetter request to a gRPC ReplayDeadLetter request. Primarily useful in a client.
+func encodeGRPCReplayDeadLetterRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.ReplayDeadLetterRequest)
+	return &pb.ReplayDeadLetterRequest{ID: req.ID}, nil
+}
+
+// decodeGRPCReplayDeadLetterRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC ReplayDeadLetter request to a user-domain ReplayDeadLetter request. Primarily useful in a server.
+func decodeGRPCReplayDeadLetterRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ReplayDeadLetterRequest)
+	return endpoint.ReplayDeadLetterRequest{ID: req.ID}, nil
+}
+
+// encodeGRPCReplayDeadLetterResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ReplayDeadLetter response to a gRPC ReplayDeadLetter reply. Primarily useful in a server.
+func encodeGRPCReplayDeadLetterResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ReplayDeadLetterResponse)
+	return &pb.ReplayDeadLetterReply{Err: err2str(resp.Err)}, nil
+}
+
+// decodeGRPCReplayDeadLetterResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC ReplayDeadLetter reply to a user-domain ReplayDeadLetter response. Primarily useful in a client.
+func decodeGRPCReplayDeadLetterResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ReplayDeadLetterReply)
+	return endpoint.ReplayDeadLetterResponse{Err: str2err(reply.Err)}, nil
+}
+
+func availabilityToPB(r availability.Report) *pb.Availability {
+	incidents := make([]*pb.Incident, 0, len(r.Incidents))
+	for _, i := range r.Incidents {
+		incidents = append(incidents, &pb.Incident{
+			Start:        timeToPB(i.Start),
+			End:          timeToPB(i.End),
+			State:        string(i.State),
+			FailedChecks: int32(i.FailedChecks),
+			Err:          i.Err,
+		})
+	}
+	return &pb.Availability{
+		NodeID:     r.NodeID.String(),
+		Name:       r.Name,
+		State:      string(r.State),
+		Registered: timeToPB(r.Registered),
+		Deleted:    timeToPB(r.Deleted),
+		Uptime:     r.Uptime,
+		Up:         r.Up.Seconds(),
+		Down:       r.Down.Seconds(),
+		Failures:   int32(r.Failures),
+		Mtbf:       r.MTBF.Seconds(),
+		Incidents:  incidents,
+	}
+}
+
+func availabilityFromPB(a *pb.Availability) availability.Report {
+	id, _ := uuid.Parse(a.NodeID)
+	incidents := make([]availability.Incident, 0, len(a.Incidents))
+	for _, i := range a.Incidents {
+		incidents = append(incidents, availability.Incident{
+			Start:        timeFromPB(i.Start),
+			End:          timeFromPB(i.End),
+			State:        repo.NodeState(i.State),
+			FailedChecks: int(i.FailedChecks),
+			Err:          i.Err,
+		})
+	}
+	return availability.Report{
+		NodeID:     repo.NodeID{UUID: id},
+		Name:       a.Name,
+		State:      repo.NodeState(a.State),
+		Registered: timeFromPB(a.Registered),
+		Deleted:    timeFromPB(a.Deleted),
+		Uptime:     a.Uptime,
+		Up:         seconds(a.Up),
+		Down:       seconds(a.Down),
+		Failures:   int(a.Failures),
+		MTBF:       seconds(a.Mtbf),
+		Incidents:  incidents,
+	}
+}
+
+// timeToPB leaves zero times unset.
+func timeToPB(t time.Time) *tspb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	ts, _ := timestamp.TimestampProto(t)
+	return ts
+}
+
+// timeFromPB returns a zero time for unset timestamps.
+func timeFromPB(ts *tspb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	t, _ := timestamp.Timestamp(ts)
+	return t
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// jobsToPB converts []repo.Job to []*pb.Job
+func jobsToPB(jobs []repo.Job) []*pb.Job {
+	pbJobs := make([]*pb.Job, 0, len(jobs))
+	for _, j := range jobs {
+		st, _ := timestamp.TimestampProto(j.StartTime)
+		ft, _ := timestamp.TimestampProto(j.FinishTime)
+		pbJobs = append(pbJobs, &pb.Job{
+			ID:         j.ID.String(),
+			Per:        j.Per,
+			Duration:   j.Duration,
+			StartTime:  st,
+			FinishTime: ft,
+		})
+	}
+	return pbJobs
+}
+
+// jobsFromPB converts []*pb.Job to []repo.Job
+func jobsFromPB(pbJobs []*pb.Job) []repo.Job {
+	jobs := make([]repo.Job, 0, len(pbJobs))
+	for _, j := range pbJobs {
+		id, _ := uuid.Parse(j.ID)
+		st, _ := timestamp.Timestamp(j.StartTime)
+		ft, _ := timestamp.Timestamp(j.FinishTime)
+		jobs = append(jobs, repo.Job{
+			ID:         repo.JobID{UUID: id},
+			Per:        j.Per,
+			Duration:   j.Duration,
+			StartTime:  st,
+			FinishTime: ft,
+		})
+	}
+	return jobs
+}
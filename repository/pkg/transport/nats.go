@@ -1,50 +1,446 @@
 package transport
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/nats-io/go-nats"
 	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/sony/gobreaker"
+	"golang.org/x/time/rate"
 
+	"github.com/go-kit/kit/circuitbreaker"
+	kitendpoint "github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/tracing/opentracing"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
 	natstransport "github.com/go-kit/kit/transport/nats"
 
+	pb "repository/pb"
 	"repository/pkg/endpoint"
+	repo "repository/pkg/model"
+	"repository/pkg/natsconn"
+	"repository/pkg/service"
+	workermodel "worker/pkg/model"
+	"worker/pkg/natstrace"
 )
 
+// ErrNATSUnavailable is reported by the health check while the repository
+// is not connected to NATS.
+var ErrNATSUnavailable = errors.New("no connection to NATS")
+
+// Subjects of the repository operations which workers don't call, those are
+// declared by the worker model next to their messages.
+const (
+	GetAllNodesSubject         = workermodel.RepositorySubjects + "GetAllNodes"
+	NewJobSubject              = workermodel.RepositorySubjects + "NewJob"
+	ExportSubject              = workermodel.RepositorySubjects + "Export"
+	ImportSubject              = workermodel.RepositorySubjects + "Import"
+	GetAvailabilitySubject     = workermodel.RepositorySubjects + "GetAvailability"
+	GetNodeAvailabilitySubject = workermodel.RepositorySubjects + "GetNodeAvailability"
+	ListDeadLettersSubject     = workermodel.RepositorySubjects + "ListDeadLetters"
+	ReplayDeadLetterSubject    = workermodel.RepositorySubjects + "ReplayDeadLetter"
+)
+
+// adminSubjects are the operations which dump, restore or replay the whole
+// repository. They are served over NATS only if the subscribers are made
+// with admin set.
+var adminSubjects = map[string]bool{
+	ExportSubject:           true,
+	ImportSubject:           true,
+	ListDeadLettersSubject:  true,
+	ReplayDeadLetterSubject: true,
+}
+
+// PayloadTooLargeError is returned by the NATS client for a request larger
+// than the NATS server takes, such as a big import, which would never reach
+// the repository. Such calls have to go over gRPC.
+type PayloadTooLargeError struct {
+	Method string
+	Size   int64
+	Max    int64
+}
+
+func (e PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("%s request of %d bytes exceeds the NATS max payload of %d bytes, call it over gRPC", e.Method, e.Size, e.Max)
+}
+
 type NATSSubscribers map[string]*natstransport.Subscriber
 
 // NewNATSSubscribers returns an NATS subscribers that makes a set of endpoints
-// available on predefined paths.
-func NewNATSSubscribers(endpoint endpoint.EndpointSet, otTracer stdopentracing.Tracer, logger log.Logger) NATSSubscribers {
+// available on predefined paths. Messages which fail to decode or whose
+// endpoint fails are sent to letters unless it is nil. Export, Import and the
+// dead letter operations are left out unless admin is set.
+func NewNATSSubscribers(endpoints endpoint.EndpointSet, letters DeadLetters, admin bool, otTracer stdopentracing.Tracer, logger log.Logger) NATSSubscribers {
 
 	var subscribers NATSSubscribers = make(map[string]*natstransport.Subscriber)
 
-	RegisterNodeHandler := natstransport.NewSubscriber(
-		endpoint.RegisterNodeEndpoint,
-		decodeNATSRegisterNodeRequest,
-		EncodeJSONResponse,
-		natstransport.SubscriberBefore(func(ctx context.Context, msg *nats.Msg) context.Context { return ctx }),
-		natstransport.SubscriberAfter(func(ctx context.Context, nc *nats.Conn) context.Context { return ctx }),
-	)
+	// Failed calls of the dead letter operations aren't dead letters
+	// themselves.
+	lettersOf := func(subject string) DeadLetters {
+		if subject == ListDeadLettersSubject || subject == ReplayDeadLetterSubject {
+			return nil
+		}
+		return letters
+	}
+
+	subscribe := func(subject string, e kitendpoint.Endpoint, dec natstransport.DecodeRequestFunc) {
+		if adminSubjects[subject] && !admin {
+			return
+		}
+		letters := lettersOf(subject)
+		subscribers[subject] = natstransport.NewSubscriber(
+			deadLetterEndpoint(letters, e),
+			deadLetterDecoder(letters, dec),
+			EncodeJSONResponse,
+			natstransport.SubscriberBefore(keepNATSMessage, NATSToContext(otTracer, subject)),
+			natstransport.SubscriberErrorLogger(log.With(logger, "subject", subject)),
+		)
+	}
+
+	subscribe(workermodel.RegisterSubject, endpoints.RegisterNodeEndpoint, DecodeJSONRequest(endpoint.RegisterNodeRequest{}))
+	subscribe(GetAllNodesSubject, endpoints.GetAllNodesEndpoint, DecodeJSONRequest(endpoint.GetAllNodesRequest{}))
+	subscribe(NewJobSubject, endpoints.NewJobEndpoint, DecodeJSONRequest(endpoint.NewJobRequest{}))
+	subscribe(ExportSubject, endpoints.ExportEndpoint, DecodeJSONRequest(endpoint.ExportRequest{}))
+	subscribe(ImportSubject, endpoints.ImportEndpoint, DecodeJSONRequest(endpoint.ImportRequest{}))
+	subscribe(GetAvailabilitySubject, endpoints.GetAvailabilityEndpoint, DecodeJSONRequest(endpoint.GetAvailabilityRequest{}))
+	subscribe(GetNodeAvailabilitySubject, endpoints.GetNodeAvailabilityEndpoint, DecodeJSONRequest(endpoint.GetNodeAvailabilityRequest{}))
+
+	// Workers publish heartbeats without waiting for a reply, their jobs are
+	// in the worker's format.
+	subscribe(workermodel.HeartbeatSubject, endpoints.HeartbeatEndpoint, decodeNATSHeartbeatRequest)
+
+	// Workers which shut down drain and deregister themselves.
+	subscribe(workermodel.DrainSubject, endpoints.DrainNodeEndpoint, DecodeJSONRequest(endpoint.DrainNodeRequest{}))
+	subscribe(workermodel.DeregisterSubject, endpoints.DeregisterNodeEndpoint, decodeNATSDeregisterNodeRequest)
+
+	subscribe(ListDeadLettersSubject, endpoints.ListDeadLettersEndpoint, DecodeJSONRequest(endpoint.ListDeadLettersRequest{}))
+	subscribe(ReplayDeadLetterSubject, endpoints.ReplayDeadLetterEndpoint, DecodeJSONRequest(endpoint.ReplayDeadLetterRequest{}))
+
+	// Every operation is served in protobuf as well, on its subject with
+	// ProtoSuffix, with the messages of the gRPC service. Traced requests
+	// come in a pb.TracedMessage.
+	subscribeProto := func(subject string, e kitendpoint.Endpoint, request proto.Message, dec grpctransport.DecodeRequestFunc, reply proto.Message, enc grpctransport.EncodeResponseFunc) {
+		if adminSubjects[subject] && !admin {
+			return
+		}
+		letters := lettersOf(subject)
+		subject = ProtoCodec.Subject(subject)
+		logger := log.With(logger, "subject", subject)
+		subscribers[subject] = natstransport.NewSubscriber(
+			deadLetterEndpoint(letters, e),
+			deadLetterDecoder(letters, decodeProtoRequest(request, dec)),
+			encodeProtoResponse(enc),
+			natstransport.SubscriberBefore(keepNATSMessage, protoNATSToContext(otTracer, subject)),
+			natstransport.SubscriberErrorEncoder(encodeProtoError(reply, logger)),
+			natstransport.SubscriberErrorLogger(logger),
+		)
+	}
 
-	subscribers["RegisterNode"] = RegisterNodeHandler
+	subscribeProto(workermodel.RegisterSubject, endpoints.RegisterNodeEndpoint, &pb.RegisterNodeRequest{}, decodeGRPCRegisterNodeRequest, &pb.RegisterNodeReply{}, encodeGRPCRegisterNodeResponse)
+	subscribeProto(GetAllNodesSubject, endpoints.GetAllNodesEndpoint, &pb.GetAllNodesRequest{}, decodeGRPCGetAllNodesRequest, &pb.GetAllNodesReply{}, encodeGRPCGetAllNodesResponse)
+	subscribeProto(NewJobSubject, endpoints.NewJobEndpoint, &pb.NewJobRequest{}, decodeGRPCNewJobRequest, &pb.NewJobReply{}, encodeGRPCNewJobResponse)
+	subscribeProto(ExportSubject, endpoints.ExportEndpoint, &pb.ExportRequest{}, decodeGRPCExportRequest, &pb.ExportReply{}, encodeGRPCExportResponse)
+	subscribeProto(ImportSubject, endpoints.ImportEndpoint, &pb.ImportRequest{}, decodeGRPCImportRequest, &pb.ImportReply{}, encodeGRPCImportResponse)
+	subscribeProto(GetAvailabilitySubject, endpoints.GetAvailabilityEndpoint, &pb.GetAvailabilityRequest{}, decodeGRPCGetAvailabilityRequest, &pb.GetAvailabilityReply{}, encodeGRPCGetAvailabilityResponse)
+	subscribeProto(GetNodeAvailabilitySubject, endpoints.GetNodeAvailabilityEndpoint, &pb.GetNodeAvailabilityRequest{}, decodeGRPCGetNodeAvailabilityRequest, &pb.GetNodeAvailabilityReply{}, encodeGRPCGetNodeAvailabilityResponse)
+	subscribeProto(workermodel.HeartbeatSubject, endpoints.HeartbeatEndpoint, &pb.HeartbeatRequest{}, decodeGRPCHeartbeatRequest, &pb.HeartbeatReply{}, encodeGRPCHeartbeatResponse)
+	subscribeProto(workermodel.DrainSubject, endpoints.DrainNodeEndpoint, &pb.DrainNodeRequest{}, decodeGRPCDrainNodeRequest, &pb.DrainNodeReply{}, encodeGRPCDrainNodeResponse)
+	subscribeProto(workermodel.DeregisterSubject, endpoints.DeregisterNodeEndpoint, &pb.DeregisterNodeRequest{}, decodeGRPCDeregisterNodeRequest, &pb.DeregisterNodeReply{}, encodeGRPCDeregisterNodeResponse)
+	subscribeProto(ListDeadLettersSubject, endpoints.ListDeadLettersEndpoint, &pb.ListDeadLettersRequest{}, decodeGRPCListDeadLettersRequest, &pb.ListDeadLettersReply{}, encodeGRPCListDeadLettersResponse)
+	subscribeProto(ReplayDeadLetterSubject, endpoints.ReplayDeadLetterEndpoint, &pb.ReplayDeadLetterRequest{}, decodeGRPCReplayDeadLetterRequest, &pb.ReplayDeadLetterReply{}, encodeGRPCReplayDeadLetterResponse)
 
 	return subscribers
 
 }
 
-// decodeHTTPRegisterNodeRequest is a transport/nats.DecodeRequestFunc that decodes a
-// JSON-encoded sum request from the NATS request body. Primarily useful in a
-// server.
-func decodeNATSRegisterNodeRequest(_ context.Context, m *nats.Msg) (interface{}, error) {
-	var req endpoint.RegisterNodeRequest
-	r := bytes.NewReader(m.Data)
-	err := json.NewDecoder(r).Decode(&req)
-	return req, err
+// NewNATSClient returns a Repo service backed by the repository's NATS
+// subscribers. Every call is a request on the subject of its method, in the
+// messages of the codec.
+func NewNATSClient(nc *nats.Conn, codec Codec, otTracer stdopentracing.Tracer, logger log.Logger) service.Service {
+	return NewNATSClientWithRate(nc, codec, DefaultClientRate, otTracer, logger)
+}
+
+// NewNATSClientWithRate is NewNATSClient with outgoing requests limited to
+// limit per second, rate.Inf doesn't limit them.
+func NewNATSClientWithRate(nc *nats.Conn, codec Codec, limit rate.Limit, otTracer stdopentracing.Tracer, logger log.Logger) service.Service {
+	// The same limits as for the gRPC client, a single ratelimiter for all of
+	// the methods and a circuitbreaker per method.
+	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(limit, clientBurst))
+
+	// method builds the endpoint of an operation from its JSON encoders or,
+	// with ProtoCodec, the gRPC ones.
+	method := func(name, subject string,
+		encJSON natstransport.EncodeRequestFunc, response interface{},
+		encGRPC grpctransport.EncodeRequestFunc, reply proto.Message, decGRPC grpctransport.DecodeResponseFunc,
+	) kitendpoint.Endpoint {
+		enc, dec := encodeTraced(otTracer, encJSON), DecodeJSONResponse(response)
+		if codec == ProtoCodec {
+			enc, dec = encodeProtoRequest(otTracer, encGRPC), decodeProtoResponse(reply, decGRPC)
+		}
+		enc = checkPayload(nc, name, enc)
+		var e kitendpoint.Endpoint
+		e = natstransport.NewPublisher(nc, subject, enc, dec).Endpoint()
+		e = contextToNATS()(e)
+		e = opentracing.TraceClient(otTracer, name)(e)
+		e = limiter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    name,
+			Timeout: 30 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return endpoint.EndpointSet{
+		RegisterNodeEndpoint: method("RegisterNode", workermodel.RegisterSubject,
+			natstransport.EncodeJSONRequest, endpoint.RegisterNodeResponse{},
+			encodeGRPCRegisterNodeRequest, &pb.RegisterNodeReply{}, decodeGRPCRegisterNodeResponse),
+		GetAllNodesEndpoint: method("GetAllNodes", GetAllNodesSubject,
+			natstransport.EncodeJSONRequest, endpoint.GetAllNodesResponse{},
+			encodeGRPCGetAllNodesRequest, &pb.GetAllNodesReply{}, decodeGRPCGetAllNodesResponse),
+		NewJobEndpoint: method("NewJob", NewJobSubject,
+			natstransport.EncodeJSONRequest, endpoint.NewJobResponse{},
+			encodeGRPCNewJobRequest, &pb.NewJobReply{}, decodeGRPCNewJobResponse),
+		ExportEndpoint: method("Export", ExportSubject,
+			natstransport.EncodeJSONRequest, endpoint.ExportResponse{},
+			encodeGRPCExportRequest, &pb.ExportReply{}, decodeGRPCExportResponse),
+		ImportEndpoint: method("Import", ImportSubject,
+			natstransport.EncodeJSONRequest, endpoint.ImportResponse{},
+			encodeGRPCImportRequest, &pb.ImportReply{}, decodeGRPCImportResponse),
+		HeartbeatEndpoint: method("Heartbeat", workermodel.HeartbeatSubject,
+			encodeNATSHeartbeatRequest, endpoint.HeartbeatResponse{},
+			encodeGRPCHeartbeatRequest, &pb.HeartbeatReply{}, decodeGRPCHeartbeatResponse),
+
+		GetAvailabilityEndpoint: method("GetAvailability", GetAvailabilitySubject,
+			natstransport.EncodeJSONRequest, endpoint.GetAvailabilityResponse{},
+			encodeGRPCGetAvailabilityRequest, &pb.GetAvailabilityReply{}, decodeGRPCGetAvailabilityResponse),
+		GetNodeAvailabilityEndpoint: method("GetNodeAvailability", GetNodeAvailabilitySubject,
+			natstransport.EncodeJSONRequest, endpoint.GetNodeAvailabilityResponse{},
+			encodeGRPCGetNodeAvailabilityRequest, &pb.GetNodeAvailabilityReply{}, decodeGRPCGetNodeAvailabilityResponse),
+
+		DrainNodeEndpoint: method("DrainNode", workermodel.DrainSubject,
+			natstransport.EncodeJSONRequest, endpoint.DrainNodeResponse{},
+			encodeGRPCDrainNodeRequest, &pb.DrainNodeReply{}, decodeGRPCDrainNodeResponse),
+		DeregisterNodeEndpoint: method("DeregisterNode", workermodel.DeregisterSubject,
+			encodeNATSDeregisterNodeRequest, endpoint.DeregisterNodeResponse{},
+			encodeGRPCDeregisterNodeRequest, &pb.DeregisterNodeReply{}, decodeGRPCDeregisterNodeResponse),
+
+		ListDeadLettersEndpoint: method("ListDeadLetters", ListDeadLettersSubject,
+			natstransport.EncodeJSONRequest, endpoint.ListDeadLettersResponse{},
+			encodeGRPCListDeadLettersRequest, &pb.ListDeadLettersReply{}, decodeGRPCListDeadLettersResponse),
+		ReplayDeadLetterEndpoint: method("ReplayDeadLetter", ReplayDeadLetterSubject,
+			natstransport.EncodeJSONRequest, endpoint.ReplayDeadLetterResponse{},
+			encodeGRPCReplayDeadLetterRequest, &pb.ReplayDeadLetterReply{}, decodeGRPCReplayDeadLetterResponse),
+	}
+}
+
+// NATSToContext returns a transport/nats.RequestFunc which unwraps a message
+// sent in a trace, see natstrace, and puts a span joining that trace into the
+// context. TraceServer of the endpoint names and finishes the span. Plain
+// messages are left as they are.
+func NATSToContext(tracer stdopentracing.Tracer, operationName string) natstransport.RequestFunc {
+	return func(ctx context.Context, msg *nats.Msg) context.Context {
+		data, remote := natstrace.Unwrap(tracer, msg.Data)
+		if remote == nil {
+			return ctx
+		}
+		msg.Data = data
+		span := natstrace.StartServerSpan(tracer, operationName, remote)
+		return stdopentracing.ContextWithSpan(ctx, span)
+	}
+}
+
+// natsTracedRequest is a request with the span of its caller. The publisher
+// doesn't pass the context of the caller to encoders, so the span travels
+// with the request.
+type natsTracedRequest struct {
+	span    stdopentracing.Span
+	request interface{}
+}
+
+// contextToNATS attaches the span of the context to the request, encodeTraced
+// puts it into the message.
+func contextToNATS() kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			return next(ctx, natsTracedRequest{span: stdopentracing.SpanFromContext(ctx), request: request})
+		}
+	}
+}
+
+// encodeTraced encodes a request with enc and wraps it with the span context
+// attached by contextToNATS.
+func encodeTraced(tracer stdopentracing.Tracer, enc natstransport.EncodeRequestFunc) natstransport.EncodeRequestFunc {
+	return func(ctx context.Context, msg *nats.Msg, request interface{}) error {
+		traced, ok := request.(natsTracedRequest)
+		if !ok {
+			return enc(ctx, msg, request)
+		}
+		if err := enc(ctx, msg, traced.request); err != nil {
+			return err
+		}
+		data, err := natstrace.Wrap(tracer, traced.span, msg.Data)
+		if err != nil {
+			return err
+		}
+		msg.Data = data
+		return nil
+	}
+}
+
+// checkPayload returns an encoder which refuses requests larger than the NATS
+// server takes.
+func checkPayload(nc *nats.Conn, method string, enc natstransport.EncodeRequestFunc) natstransport.EncodeRequestFunc {
+	return func(ctx context.Context, msg *nats.Msg, request interface{}) error {
+		if err := enc(ctx, msg, request); err != nil {
+			return err
+		}
+		// The limit is known once the client is connected.
+		if max := nc.MaxPayload(); max > 0 && int64(len(msg.Data)) > max {
+			return PayloadTooLargeError{Method: method, Size: int64(len(msg.Data)), Max: max}
+		}
+		return nil
+	}
+}
+
+// DecodeJSONRequest returns a transport/nats.DecodeRequestFunc that decodes a
+// JSON-encoded request into a new value of the type of sample. An empty
+// message decodes to the zero request. Primarily useful in a server.
+func DecodeJSONRequest(sample interface{}) natstransport.DecodeRequestFunc {
+	t := reflect.TypeOf(sample)
+	return func(_ context.Context, m *nats.Msg) (interface{}, error) {
+		req := reflect.New(t)
+		if len(m.Data) > 0 {
+			if err := json.Unmarshal(m.Data, req.Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return req.Elem().Interface(), nil
+	}
+}
+
+// DecodeJSONResponse returns a transport/nats.DecodeResponseFunc that decodes
+// a reply written by EncodeJSONResponse into a new value of the type of
+// sample. An error in the reply is set as the Err field of the response.
+// Primarily useful in a client.
+func DecodeJSONResponse(sample interface{}) natstransport.DecodeResponseFunc {
+	t := reflect.TypeOf(sample)
+	return func(_ context.Context, m *nats.Msg) (interface{}, error) {
+		var e errorWrapper
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			return nil, err
+		}
+		resp := reflect.New(t)
+		if e.Error != "" {
+			f := resp.Elem().FieldByName("Err")
+			if !f.IsValid() || !f.CanSet() {
+				return nil, errors.New(e.Error)
+			}
+			f.Set(reflect.ValueOf(errors.New(e.Error)))
+			return resp.Elem().Interface(), nil
+		}
+		if err := json.Unmarshal(m.Data, resp.Interface()); err != nil {
+			return nil, err
+		}
+		return resp.Elem().Interface(), nil
+	}
+}
+
+// decodeNATSHeartbeatRequest is a transport/nats.DecodeRequestFunc that decodes
+// a JSON-encoded heartbeat published by a worker.
+func decodeNATSHeartbeatRequest(_ context.Context, m *nats.Msg) (interface{}, error) {
+	var hb workermodel.Heartbeat
+	if err := json.Unmarshal(m.Data, &hb); err != nil {
+		return nil, err
+	}
+
+	return endpoint.HeartbeatRequest{
+		NodeID:    hb.NodeID,
+		JobsCount: hb.JobsCount,
+		Jobs:      jobsFromWorker(hb.Jobs),
+		Full:      hb.Full,
+	}, nil
+}
+
+// encodeNATSHeartbeatRequest is a transport/nats.EncodeRequestFunc that
+// encodes a heartbeat the way a worker publishes it.
+func encodeNATSHeartbeatRequest(_ context.Context, msg *nats.Msg, request interface{}) error {
+	req := request.(endpoint.HeartbeatRequest)
+	b, err := json.Marshal(workermodel.Heartbeat{
+		NodeID:    req.NodeID,
+		JobsCount: req.JobsCount,
+		Full:      req.Full,
+		Jobs:      jobsToWorker(req.Jobs),
+	})
+	if err != nil {
+		return err
+	}
+	msg.Data = b
+	return nil
+}
+
+// jobsFromWorker converts jobs reported by a worker.
+func jobsFromWorker(wjobs []workermodel.Job) []repo.Job {
+	jobs := make([]repo.Job, 0, len(wjobs))
+	for _, j := range wjobs {
+		jobs = append(jobs, repo.Job{
+			ID:         repo.JobID{UUID: j.ID.UUID},
+			Per:        j.Per,
+			Duration:   float32(j.Duration.Seconds()),
+			StartTime:  j.StartTime,
+			FinishTime: j.FinishTime,
+		})
+	}
+	return jobs
+}
+
+// jobsToWorker converts jobs to the format workers report them in.
+func jobsToWorker(jobs []repo.Job) []workermodel.Job {
+	wjobs := make([]workermodel.Job, 0, len(jobs))
+	for _, j := range jobs {
+		wjobs = append(wjobs, workermodel.Job{
+			ID:         workermodel.JobID{UUID: j.ID.UUID},
+			Per:        j.Per,
+			Duration:   time.Duration(float64(j.Duration) * float64(time.Second)),
+			StartTime:  j.StartTime,
+			FinishTime: j.FinishTime,
+		})
+	}
+	return wjobs
+}
+
+// decodeNATSDeregisterNodeRequest is a transport/nats.DecodeRequestFunc that
+// decodes a JSON-encoded deregistration of a worker.
+func decodeNATSDeregisterNodeRequest(_ context.Context, m *nats.Msg) (interface{}, error) {
+	var d workermodel.Deregister
+	if err := json.Unmarshal(m.Data, &d); err != nil {
+		return nil, err
+	}
+	return endpoint.DeregisterNodeRequest{
+		NodeID:     d.NodeID,
+		Unfinished: jobsFromWorker(d.Unfinished),
+	}, nil
+}
+
+// encodeNATSDeregisterNodeRequest is a transport/nats.EncodeRequestFunc that
+// encodes a deregistration the way a worker sends it.
+func encodeNATSDeregisterNodeRequest(_ context.Context, msg *nats.Msg, request interface{}) error {
+	req := request.(endpoint.DeregisterNodeRequest)
+	b, err := json.Marshal(workermodel.Deregister{
+		NodeID:     req.NodeID,
+		Unfinished: jobsToWorker(req.Unfinished),
+	})
+	if err != nil {
+		return err
+	}
+	msg.Data = b
+	return nil
 }
 
 type errorWrapper struct {
@@ -52,17 +448,20 @@ type errorWrapper struct {
 }
 
 // EncodeJSONResponse is a EncodeResponseFunc that serializes the response as a
-// JSON object to the subscriber reply. Many JSON-over services can use it as
-// a sensible default.
+// JSON object to the subscriber reply. A response which failed is replied as
+// an object with the error in its err field. Nothing is replied to messages
+// published without a reply subject.
 func EncodeJSONResponse(_ context.Context, reply string, nc *nats.Conn, response interface{}) error {
+	if reply == "" {
+		return nil
+	}
+
 	var err error
 	var b []byte
-	resp := response.(endpoint.RegisterNodeResponse)
-
-	if resp.Err != nil {
-		b, err = json.Marshal(errorWrapper{Error: resp.Err.Error()})
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		b, err = json.Marshal(errorWrapper{Error: f.Failed().Error()})
 	} else {
-		b, err = json.Marshal(resp)
+		b, err = json.Marshal(response)
 	}
 	if err != nil {
 		return err
@@ -71,67 +470,54 @@ func EncodeJSONResponse(_ context.Context, reply string, nc *nats.Conn, response
 	return nc.Publish(reply, b)
 }
 
+// NATSHandler serves the repository over a NATS connection kept by a
+// natsconn.Manager, which restores the subscriptions after reconnects.
 type NATSHandler struct {
-	nc            *nats.Conn
-	natsAddr      string
-	subscribers   NATSSubscribers
-	subscriptions []*nats.Subscription
-	logger        log.Logger
-}
-
-// New create in memory repository for storing nodes
-func NewNATSHandler(natsAddr string, subs NATSSubscribers, logger log.Logger) func() {
-
-	nh := &NATSHandler{
-		nc:            nil,
-		natsAddr:      natsAddr,
-		subscribers:   subs,
-		subscriptions: make([]*nats.Subscription, 0),
-		logger:        logger,
-	}
-
-	closeCh := make(chan struct{}, 1)
-	go nh.connectToNATS(closeCh)
-	closeFn := func() {
-		closeCh <- struct{}{}
-	}
-
-	return closeFn
-}
-
-func (nh *NATSHandler) connectToNATS(closeCh chan struct{}) {
-	ticker := time.NewTicker(1 * time.Second)
-	go func() {
-		for range ticker.C {
-			if nh.nc == nil {
-				nh.logger.Log("transport", "NATS", "message", "trying connect to the NATS...")
-				nc, err := nats.Connect(nh.natsAddr)
-				if err != nil {
-					nh.logger.Log("transport", "NATS", "message", "got an error", "err", err)
-				} else {
-					nh.logger.Log("transport", "NATS", "message", "connection to NATS is established")
-					nh.nc = nc
-					for key, s := range nh.subscribers {
-						sub, err := nc.QueueSubscribe(key, "Repository", s.ServeMsg(nh.nc))
-						if err != nil {
-							nh.logger.Log("transport", "NATS", "message", err)
-						}
-						nh.subscriptions = append(nh.subscriptions, sub)
-					}
-				}
-
-			} else {
-				if !nh.nc.IsConnected() {
-					nh.logger.Log("transport", "NATS", "message", "lost connection to the NATS")
-					nh.nc = nil
-				}
-			}
-		}
-		for _, s := range nh.subscriptions {
-			s.Unsubscribe()
+	conn   *natsconn.Manager
+	logger log.Logger
+}
+
+// NewNATSHandler returns a handler serving on the connection.
+func NewNATSHandler(conn *natsconn.Manager, logger log.Logger) *NATSHandler {
+	return &NATSHandler{conn: conn, logger: logger}
+}
+
+// Handle serves the subscribers on their subjects, on the current connection
+// and on every connection established later.
+func (nh *NATSHandler) Handle(subs NATSSubscribers) {
+	for key, s := range subs {
+		if err := nh.conn.Subscribe(key, "Repository", s.ServeMsg); err != nil {
+			nh.logger.Log("transport", "NATS", "subject", key, "err", err)
 		}
-		nh.nc.Close()
-	}()
-	<-closeCh
-	ticker.Stop()
+	}
+}
+
+// Publish sends data on the subject. While the client reconnects the data is
+// buffered, it fails before the first connection.
+func (nh *NATSHandler) Publish(subject string, data []byte) error {
+	if err := nh.conn.Publish(subject, data); err != natsconn.ErrNotConnected {
+		return err
+	}
+	return ErrNATSUnavailable
+}
+
+// Request sends data on the subject and waits for a reply for up to timeout,
+// it fails while there is no connection.
+func (nh *NATSHandler) Request(subject string, data []byte, timeout time.Duration) ([]byte, error) {
+	msg, err := nh.conn.Request(subject, data, timeout)
+	if err == natsconn.ErrNotConnected {
+		return nil, ErrNATSUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg.Data, nil
+}
+
+// Check tells whether the handler is connected to NATS.
+func (nh *NATSHandler) Check() error {
+	if nh.conn.Check() != nil {
+		return ErrNATSUnavailable
+	}
+	return nil
 }
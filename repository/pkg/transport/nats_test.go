@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"repository/pkg/endpoint"
+	"repository/pkg/natsserver"
+	workermodel "worker/pkg/model"
+)
+
+// The admin operations are served over NATS only when asked for.
+func TestNATSSubscribersAdmin(t *testing.T) {
+	tests := []struct {
+		admin bool
+		want  map[string]bool
+	}{
+		{false, map[string]bool{
+			workermodel.RegisterSubject:       true,
+			GetAllNodesSubject:                true,
+			ExportSubject:                     false,
+			ImportSubject:                     false,
+			ListDeadLettersSubject:            false,
+			ReplayDeadLetterSubject:           false,
+			ProtoCodec.Subject(NewJobSubject): true,
+			ProtoCodec.Subject(ImportSubject): false,
+		}},
+		{true, map[string]bool{
+			workermodel.RegisterSubject:       true,
+			ExportSubject:                     true,
+			ImportSubject:                     true,
+			ListDeadLettersSubject:            true,
+			ReplayDeadLetterSubject:           true,
+			ProtoCodec.Subject(ImportSubject): true,
+		}},
+	}
+	for _, tt := range tests {
+		subscribers := NewNATSSubscribers(endpoint.EndpointSet{}, nil, tt.admin, stdopentracing.NoopTracer{}, log.NewNopLogger())
+		for subject, want := range tt.want {
+			if _, ok := subscribers[subject]; ok != want {
+				t.Errorf("admin %v: want %s served %v, got %v", tt.admin, subject, want, ok)
+			}
+		}
+	}
+}
+
+// A request larger than the server takes fails before it is sent.
+func TestNATSClientPayloadTooLarge(t *testing.T) {
+	ns, err := natsserver.Start(natsserver.Config{Addr: "127.0.0.1:-1"}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Shutdown()
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	for _, codec := range []Codec{JSONCodec, ProtoCodec} {
+		client := NewNATSClient(nc, codec, stdopentracing.NoopTracer{}, log.NewNopLogger())
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := client.Import(ctx, make([]byte, nc.MaxPayload()+1))
+		cancel()
+		e, ok := err.(PayloadTooLargeError)
+		if !ok {
+			t.Fatalf("%s: want PayloadTooLargeError, got %v", codec, err)
+		}
+		if e.Method != "Import" || e.Max != nc.MaxPayload() || e.Size <= e.Max {
+			t.Errorf("%s: want Import over %d bytes, got %+v", codec, nc.MaxPayload(), e)
+		}
+	}
+}
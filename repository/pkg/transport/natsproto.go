@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/go-kit/kit/log"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	natstransport "github.com/go-kit/kit/transport/nats"
+
+	pb "repository/pb"
+	"worker/pkg/natstrace"
+)
+
+// ProtoSuffix is appended to the subject of an operation for messages in the
+// pb.repo protobuf format. The NATS client has no message headers, so the
+// content type is told by the subject: a request on "repository.NewJob" is
+// JSON, while a request on "repository.NewJob.proto" is a pb.NewJobRequest
+// and gets a pb.NewJobReply.
+const ProtoSuffix = ".proto"
+
+// Codec is the content type of messages over NATS.
+type Codec string
+
+// Codecs of the repository operations over NATS.
+const (
+	// JSONCodec is the default, workers only speak it.
+	JSONCodec Codec = "application/json"
+	// ProtoCodec uses the messages of the gRPC service.
+	ProtoCodec Codec = "application/protobuf"
+)
+
+// ParseCodec returns the codec named by s: "json", "proto" or a content type.
+func ParseCodec(s string) (Codec, error) {
+	switch strings.ToLower(s) {
+	case "", "json", string(JSONCodec):
+		return JSONCodec, nil
+	case "proto", "protobuf", string(ProtoCodec), "application/x-protobuf":
+		return ProtoCodec, nil
+	}
+	return "", fmt.Errorf("unknown NATS codec %q", s)
+}
+
+// Subject returns the subject of the operation for messages in the codec.
+func (c Codec) Subject(subject string) string {
+	if c == ProtoCodec {
+		return subject + ProtoSuffix
+	}
+	return subject
+}
+
+// decodeProtoRequest returns a transport/nats.DecodeRequestFunc that
+// unmarshals a new message of the type of sample and converts it with the
+// gRPC decoder dec. Primarily useful in a server.
+func decodeProtoRequest(sample proto.Message, dec grpctransport.DecodeRequestFunc) natstransport.DecodeRequestFunc {
+	t := reflect.TypeOf(sample).Elem()
+	return func(ctx context.Context, m *nats.Msg) (interface{}, error) {
+		req := reflect.New(t).Interface().(proto.Message)
+		if err := proto.Unmarshal(m.Data, req); err != nil {
+			return nil, err
+		}
+		return dec(ctx, req)
+	}
+}
+
+// encodeProtoResponse returns a transport/nats.EncodeResponseFunc that
+// converts the response with the gRPC encoder enc and replies the marshalled
+// message, the error of a failed response is in its err field. Nothing is
+// replied to messages published without a reply subject. Primarily useful in
+// a server.
+func encodeProtoResponse(enc grpctransport.EncodeResponseFunc) natstransport.EncodeResponseFunc {
+	return func(ctx context.Context, reply string, nc *nats.Conn, response interface{}) error {
+		if reply == "" {
+			return nil
+		}
+		m, err := enc(ctx, response)
+		if err != nil {
+			return err
+		}
+		b, err := proto.Marshal(m.(proto.Message))
+		if err != nil {
+			return err
+		}
+		return nc.Publish(reply, b)
+	}
+}
+
+// encodeProtoError returns a transport/nats.ErrorEncoder that replies a new
+// message of the type of sample with the error in its Err field, for requests
+// which failed before they reached the endpoint.
+func encodeProtoError(sample proto.Message, logger log.Logger) natstransport.ErrorEncoder {
+	t := reflect.TypeOf(sample).Elem()
+	return func(_ context.Context, err error, reply string, nc *nats.Conn) {
+		if reply == "" {
+			return
+		}
+		m := reflect.New(t)
+		if f := m.Elem().FieldByName("Err"); f.IsValid() && f.Kind() == reflect.String {
+			f.SetString(err.Error())
+		}
+		b, merr := proto.Marshal(m.Interface().(proto.Message))
+		if merr == nil {
+			merr = nc.Publish(reply, b)
+		}
+		if merr != nil {
+			logger.Log("err", merr)
+		}
+	}
+}
+
+// encodeProtoRequest returns a transport/nats.EncodeRequestFunc that converts
+// the request with the gRPC encoder enc, marshals it and sends it on the
+// protobuf subject of the operation, in a trace envelope if contextToNATS
+// attached a span. Primarily useful in a client.
+func encodeProtoRequest(tracer stdopentracing.Tracer, enc grpctransport.EncodeRequestFunc) natstransport.EncodeRequestFunc {
+	return func(ctx context.Context, msg *nats.Msg, request interface{}) error {
+		var span stdopentracing.Span
+		if traced, ok := request.(natsTracedRequest); ok {
+			span, request = traced.span, traced.request
+		}
+		m, err := enc(ctx, request)
+		if err != nil {
+			return err
+		}
+		b, err := proto.Marshal(m.(proto.Message))
+		if err != nil {
+			return err
+		}
+		if b, err = wrapProto(tracer, span, b); err != nil {
+			return err
+		}
+		msg.Subject = ProtoCodec.Subject(msg.Subject)
+		msg.Data = b
+		return nil
+	}
+}
+
+// tracedProto starts a pb.TracedMessage on the wire. Field number 0 is
+// invalid, so no protobuf message starts with a zero byte and plain requests
+// are told apart from traced ones.
+const tracedProto byte = 0
+
+// wrapProto returns the protobuf message data in a pb.TracedMessage with the
+// context of the span. Without a span data is returned as it is.
+func wrapProto(tracer stdopentracing.Tracer, span stdopentracing.Span, data []byte) ([]byte, error) {
+	if span == nil {
+		return data, nil
+	}
+	carrier := stdopentracing.TextMapCarrier{}
+	if err := tracer.Inject(span.Context(), stdopentracing.TextMap, carrier); err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(&pb.TracedMessage{Trace: carrier, Payload: data})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tracedProto}, b...), nil
+}
+
+// unwrapProto returns the payload of a pb.TracedMessage and the span context
+// it carries. Other messages are returned as they are, with a nil span
+// context.
+func unwrapProto(tracer stdopentracing.Tracer, data []byte) ([]byte, stdopentracing.SpanContext) {
+	if len(data) == 0 || data[0] != tracedProto {
+		return data, nil
+	}
+	var m pb.TracedMessage
+	if err := proto.Unmarshal(data[1:], &m); err != nil {
+		return data, nil
+	}
+	sc, err := tracer.Extract(stdopentracing.TextMap, stdopentracing.TextMapCarrier(m.Trace))
+	if err != nil {
+		return m.Payload, nil
+	}
+	return m.Payload, sc
+}
+
+// protoNATSToContext is NATSToContext for protobuf messages: it unwraps a
+// pb.TracedMessage and starts a span of the operation joining the trace of
+// the sender. Plain messages are left alone.
+func protoNATSToContext(tracer stdopentracing.Tracer, operationName string) natstransport.RequestFunc {
+	return func(ctx context.Context, msg *nats.Msg) context.Context {
+		data, remote := unwrapProto(tracer, msg.Data)
+		msg.Data = data
+		if remote == nil {
+			return ctx
+		}
+		span := natstrace.StartServerSpan(tracer, operationName, remote)
+		return stdopentracing.ContextWithSpan(ctx, span)
+	}
+}
+
+// decodeProtoResponse returns a transport/nats.DecodeResponseFunc that
+// unmarshals a new message of the type of sample and converts it with the
+// gRPC decoder dec. Primarily useful in a client.
+func decodeProtoResponse(sample proto.Message, dec grpctransport.DecodeResponseFunc) natstransport.DecodeResponseFunc {
+	t := reflect.TypeOf(sample).Elem()
+	return func(ctx context.Context, m *nats.Msg) (interface{}, error) {
+		reply := reflect.New(t).Interface().(proto.Message)
+		if err := proto.Unmarshal(m.Data, reply); err != nil {
+			return nil, err
+		}
+		return dec(ctx, reply)
+	}
+}
@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+
+	pb "repository/pb"
+)
+
+// A traced protobuf request reaches the server as the plain message, within
+// a span joining the trace of the client.
+func TestProtoTrace(t *testing.T) {
+	tracer := mocktracer.New()
+	req, err := proto.Marshal(&pb.DrainNodeRequest{NodeID: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := tracer.StartSpan("DrainNode")
+	data, err := wrapProto(tracer, client, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != tracedProto {
+		t.Fatalf("want a traced message, got %x", data)
+	}
+
+	msg := &nats.Msg{Data: data}
+	ctx := protoNATSToContext(tracer, "repository.DrainNode.proto")(context.Background(), msg)
+	if !bytes.Equal(msg.Data, req) {
+		t.Errorf("want the request %x, got %x", req, msg.Data)
+	}
+	span, ok := stdopentracing.SpanFromContext(ctx).(*mocktracer.MockSpan)
+	if !ok {
+		t.Fatal("want a server span")
+	}
+	if want := client.Context().(mocktracer.MockSpanContext).TraceID; span.SpanContext.TraceID != want {
+		t.Errorf("want trace %d, got %d", want, span.SpanContext.TraceID)
+	}
+}
+
+// Requests of clients which don't trace are left alone.
+func TestProtoTracePlain(t *testing.T) {
+	tracer := mocktracer.New()
+	req, err := proto.Marshal(&pb.DrainNodeRequest{NodeID: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := wrapProto(tracer, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &nats.Msg{Data: data}
+	ctx := protoNATSToContext(tracer, "repository.DrainNode.proto")(context.Background(), msg)
+	if !bytes.Equal(msg.Data, req) {
+		t.Errorf("want the request %x, got %x", req, msg.Data)
+	}
+	if span := stdopentracing.SpanFromContext(ctx); span != nil {
+		t.Errorf("want no span, got %v", span)
+	}
+}
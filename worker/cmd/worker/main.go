@@ -21,14 +21,19 @@ import (
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/go-kit/kit/metrics"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 
+	workerpb "worker/pb"
 	"worker/pkg/endpoint"
+	"worker/pkg/healthcheck"
+	"worker/pkg/natsconn"
 	"worker/pkg/service"
 	"worker/pkg/transport"
-	workerpb "worker/pb"
 )
 
 func main() {
@@ -41,10 +46,19 @@ func main() {
 		extIP      = fs.String("extIP", getOutboundIP().String(), "external IP address")
 		extPort    = fs.String("extPort", ":8082", "external Port address")
 		jaegerURL  = fs.String("jaeger-addr", "jaeger:5775", "Jaeger server address")
+		heartbeat  = fs.Duration("heartbeat-interval", 1*time.Second, "How often jobs are reported to the repository over NATS")
+		healthIntv = fs.Duration("health-check-interval", 1*time.Second, "How often dependencies are checked for the gRPC health service")
+		nodeID     = fs.String("node-id", "", "Stable ID of the worker in the repository, derived from the name and the external address if empty")
+		sessionTTL = fs.Duration("session-timeout", service.DefaultSessionTimeout, "How long the repository may stay silent before the worker registers again")
+		capacity   = fs.Int("capacity", service.DefaultCapacity, "How many jobs run at the same time before the worker stops taking jobs dispatched over NATS")
+		grace      = fs.Duration("shutdown-grace", 20*time.Second, "How long running jobs may finish on shutdown before they are handed back to the repository")
 	)
 
 	fs.Usage = usageFor(fs, os.Args[0]+" [flags]")
 	fs.Parse(os.Args[1:])
+	if *nodeID == "" {
+		*nodeID = service.NodeID(*workerName, *extIP, *extPort)
+	}
 
 	// Create a single logger, which we'll use and give to other components.
 	var logger log.Logger
@@ -113,14 +127,50 @@ func main() {
 			Help:      "Request duration in seconds.",
 		}, []string{"method", "success"})
 	}
+	var natsMetrics natsconn.Metrics
+	{
+		// Connection-level metrics.
+		natsMetrics.Connected = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "transactionApp",
+			Subsystem: "worker",
+			Name:      "nats_connected",
+			Help:      "1 while the worker is connected to NATS.",
+		}, []string{})
+		natsMetrics.Disconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "worker",
+			Name:      "nats_disconnects",
+			Help:      "Total count of connections to NATS lost.",
+		}, []string{})
+		natsMetrics.Reconnects = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "transactionApp",
+			Subsystem: "worker",
+			Name:      "nats_reconnects",
+			Help:      "Total count of connections to NATS restored.",
+		}, []string{})
+	}
 	http.DefaultServeMux.Handle("/metrics", promhttp.Handler())
 
+	// The connection to NATS is made in the background and kept across
+	// restarts of the server.
+	natsConn := natsconn.New(*natsAddr, natsconn.Config{Name: *workerName}, natsMetrics, logger)
+	defer natsConn.Close()
+
 	var (
-		service    = service.New(*workerName, *extIP, *extPort, *natsAddr, logger, pings, newJobs, getJobs)
+		worker     = service.NewWorker(*nodeID, *workerName, *extIP, *extPort, natsConn, *heartbeat, *sessionTTL, *capacity, logger)
+		service    = service.New(worker, logger, pings, newJobs, getJobs)
 		endpoints  = endpoint.New(service, logger, duration, tracer)
 		grpcServer = transport.NewGRPCServer(endpoints, tracer, logger)
 	)
 
+	// grpc.health.v1.Health reports the worker NOT_SERVING while it can't
+	// reach NATS, its jobs don't get to the repository then.
+	healthServer := health.NewServer()
+	healthWatcher := healthcheck.Watch(healthServer, []string{"pb.worker.Worker"}, healthcheck.Checks{
+		"nats": worker.Check,
+	}, *healthIntv, logger)
+	defer healthWatcher.Stop()
+
 	// Now we're to the part of the func main where we want to start actually
 	// running things, like servers bound to listeners to receive connections.
 	//
@@ -161,8 +211,17 @@ func main() {
 			logger.Log("transport", "gRPC", "addr", *grpcAddr)
 			// we add the Go Kit gRPC Interceptor to our gRPC service as it is used by
 			// the here demonstrated zipkin tracing middleware.
-			baseServer := grpc.NewServer(grpc.UnaryInterceptor(grpctransport.Interceptor))
+			// The repository keeps connections open and pings them while
+			// they are idle, such pings must not be treated as abuse.
+			baseServer := grpc.NewServer(
+				grpc.UnaryInterceptor(grpctransport.Interceptor),
+				grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+					MinTime:             10 * time.Second,
+					PermitWithoutStream: true,
+				}),
+			)
 			workerpb.RegisterWorkerServer(baseServer, grpcServer)
+			healthpb.RegisterHealthServer(baseServer, healthServer)
 			return baseServer.Serve(grpcListener)
 		}, func(error) {
 			grpcListener.Close()
@@ -176,6 +235,12 @@ func main() {
 			signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 			select {
 			case sig := <-c:
+				// Load balancers stop sending work as soon as draining
+				// starts, but the worker keeps serving the repository while
+				// it drains, so that progress of running jobs is still
+				// reported.
+				healthWatcher.Shutdown()
+				worker.Shutdown(*grace)
 				return fmt.Errorf("received signal %s", sig)
 			case <-cancelInterrupt:
 				return nil
@@ -138,6 +138,7 @@ type Job struct {
 	Duration             float32              `protobuf:"fixed32,3,opt,name=duration,proto3" json:"duration,omitempty"`
 	StartTime            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=startTime,proto3" json:"startTime,omitempty"`
 	FinishTime           *timestamp.Timestamp `protobuf:"bytes,5,opt,name=finishTime,proto3" json:"finishTime,omitempty"`
+	Rev                  uint64               `protobuf:"varint,6,opt,name=rev,proto3" json:"rev,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
 	XXX_unrecognized     []byte               `json:"-"`
 	XXX_sizecache        int32                `json:"-"`
@@ -203,6 +204,13 @@ func (m *Job) GetFinishTime() *timestamp.Timestamp {
 	return nil
 }
 
+func (m *Job) GetRev() uint64 {
+	if m != nil {
+		return m.Rev
+	}
+	return 0
+}
+
 type GetJobsReply struct {
 	Jobs                 []*Job   `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
 	Err                  string   `protobuf:"bytes,2,opt,name=err,proto3" json:"err,omitempty"`
@@ -328,6 +336,108 @@ func (m *NewJobReply) GetErr() string {
 	return ""
 }
 
+type GetJobsSinceRequest struct {
+	Cursor               string   `protobuf:"bytes,1,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetJobsSinceRequest) Reset()         { *m = GetJobsSinceRequest{} }
+func (m *GetJobsSinceRequest) String() string { return proto.CompactTextString(m) }
+func (*GetJobsSinceRequest) ProtoMessage()    {}
+func (*GetJobsSinceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e4ff6184b07e587a, []int{7}
+}
+
+func (m *GetJobsSinceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetJobsSinceRequest.Unmarshal(m, b)
+}
+func (m *GetJobsSinceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetJobsSinceRequest.Marshal(b, m, deterministic)
+}
+func (m *GetJobsSinceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetJobsSinceRequest.Merge(m, src)
+}
+func (m *GetJobsSinceRequest) XXX_Size() int {
+	return xxx_messageInfo_GetJobsSinceRequest.Size(m)
+}
+func (m *GetJobsSinceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetJobsSinceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetJobsSinceRequest proto.InternalMessageInfo
+
+func (m *GetJobsSinceRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+type GetJobsSinceReply struct {
+	Jobs                 []*Job   `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	Cursor               string   `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Full                 bool     `protobuf:"varint,3,opt,name=full,proto3" json:"full,omitempty"`
+	Err                  string   `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetJobsSinceReply) Reset()         { *m = GetJobsSinceReply{} }
+func (m *GetJobsSinceReply) String() string { return proto.CompactTextString(m) }
+func (*GetJobsSinceReply) ProtoMessage()    {}
+func (*GetJobsSinceReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e4ff6184b07e587a, []int{8}
+}
+
+func (m *GetJobsSinceReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetJobsSinceReply.Unmarshal(m, b)
+}
+func (m *GetJobsSinceReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetJobsSinceReply.Marshal(b, m, deterministic)
+}
+func (m *GetJobsSinceReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetJobsSinceReply.Merge(m, src)
+}
+func (m *GetJobsSinceReply) XXX_Size() int {
+	return xxx_messageInfo_GetJobsSinceReply.Size(m)
+}
+func (m *GetJobsSinceReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetJobsSinceReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetJobsSinceReply proto.InternalMessageInfo
+
+func (m *GetJobsSinceReply) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+func (m *GetJobsSinceReply) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+func (m *GetJobsSinceReply) GetFull() bool {
+	if m != nil {
+		return m.Full
+	}
+	return false
+}
+
+func (m *GetJobsSinceReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*PingRequest)(nil), "pb.worker.PingRequest")
 	proto.RegisterType((*PingReply)(nil), "pb.worker.PingReply")
@@ -336,34 +446,41 @@ func init() {
 	proto.RegisterType((*GetJobsReply)(nil), "pb.worker.GetJobsReply")
 	proto.RegisterType((*NewJobRequest)(nil), "pb.worker.NewJobRequest")
 	proto.RegisterType((*NewJobReply)(nil), "pb.worker.NewJobReply")
+	proto.RegisterType((*GetJobsSinceRequest)(nil), "pb.worker.GetJobsSinceRequest")
+	proto.RegisterType((*GetJobsSinceReply)(nil), "pb.worker.GetJobsSinceReply")
 }
 
 func init() { proto.RegisterFile("worker.proto", fileDescriptor_e4ff6184b07e587a) }
 
 var fileDescriptor_e4ff6184b07e587a = []byte{
-	// 352 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x51, 0x41, 0x4f, 0xf2, 0x40,
-	0x10, 0xfd, 0xda, 0x02, 0x9f, 0x9d, 0x42, 0x25, 0x13, 0x83, 0xb5, 0x17, 0x49, 0x4f, 0x3d, 0x95,
-	0x88, 0x89, 0x31, 0xc6, 0xc4, 0x0b, 0x89, 0x81, 0x83, 0x31, 0x0d, 0x89, 0x67, 0x36, 0x2c, 0xb8,
-	0x0a, 0x6c, 0xdd, 0x2e, 0x21, 0xfc, 0x34, 0x4f, 0xfe, 0x35, 0xb3, 0xbb, 0xb4, 0x96, 0x80, 0xf1,
-	0x36, 0x3b, 0xf3, 0xde, 0x9b, 0x7d, 0x6f, 0xa0, 0xb9, 0xe1, 0xe2, 0x9d, 0x8a, 0x24, 0x13, 0x5c,
-	0x72, 0x74, 0x33, 0x92, 0x98, 0x46, 0x78, 0x39, 0xe7, 0x7c, 0xbe, 0xa0, 0x3d, 0x3d, 0x20, 0xeb,
-	0x59, 0x4f, 0xb2, 0x25, 0xcd, 0xe5, 0x64, 0x99, 0x19, 0x6c, 0xd4, 0x02, 0xef, 0x99, 0xad, 0xe6,
-	0x29, 0xfd, 0x58, 0xd3, 0x5c, 0x46, 0x57, 0xe0, 0x9a, 0x67, 0xb6, 0xd8, 0x22, 0x42, 0xed, 0x8d,
-	0x93, 0x3c, 0xb0, 0xba, 0x56, 0x5c, 0x4f, 0x75, 0x8d, 0x6d, 0x70, 0xa8, 0x10, 0x81, 0xdd, 0xb5,
-	0x62, 0x37, 0x55, 0x65, 0xd4, 0x06, 0xff, 0x91, 0xca, 0x11, 0x27, 0x79, 0x21, 0xf2, 0x69, 0x81,
-	0x33, 0xe2, 0x04, 0x7d, 0xb0, 0x87, 0x03, 0xcd, 0x76, 0x53, 0x7b, 0x38, 0x50, 0xdc, 0x8c, 0x1a,
-	0xae, 0x9d, 0xaa, 0x12, 0x43, 0x38, 0x99, 0xae, 0xc5, 0x44, 0x32, 0xbe, 0x0a, 0x1c, 0xdd, 0x2e,
-	0xdf, 0x78, 0x0b, 0x6e, 0x2e, 0x27, 0x42, 0x8e, 0xd9, 0x92, 0x06, 0xb5, 0xae, 0x15, 0x7b, 0xfd,
-	0x30, 0x31, 0x76, 0x92, 0xc2, 0x4e, 0x32, 0x2e, 0xec, 0xa4, 0x3f, 0x60, 0xbc, 0x03, 0x98, 0xb1,
-	0x15, 0xcb, 0x5f, 0x35, 0xb5, 0xfe, 0x27, 0xb5, 0x82, 0x8e, 0x06, 0xd0, 0x2c, 0xdd, 0xa8, 0x0c,
-	0xa2, 0x32, 0x03, 0x27, 0xf6, 0xfa, 0x7e, 0x52, 0x46, 0x9b, 0x8c, 0x38, 0xf9, 0x35, 0x93, 0x53,
-	0x68, 0x3d, 0xd1, 0x8d, 0x42, 0xec, 0x22, 0xe9, 0x81, 0x57, 0x34, 0x94, 0xaa, 0x0f, 0x36, 0x9b,
-	0x16, 0xc9, 0xb0, 0xe9, 0xa1, 0x42, 0xff, 0xcb, 0x82, 0xc6, 0x8b, 0x5e, 0x84, 0x37, 0x50, 0x53,
-	0x37, 0xc1, 0x4e, 0x65, 0x79, 0xe5, 0x66, 0xe1, 0xd9, 0x41, 0x3f, 0x5b, 0x6c, 0xa3, 0x7f, 0xf8,
-	0x00, 0xff, 0x77, 0x56, 0xf0, 0xa2, 0x02, 0xd9, 0x3f, 0x56, 0x78, 0x7e, 0x6c, 0x64, 0x04, 0xee,
-	0xa1, 0x61, 0x3e, 0x8d, 0x41, 0x05, 0xb4, 0x67, 0x2c, 0xec, 0x1c, 0x99, 0x68, 0x36, 0x69, 0xe8,
-	0xa4, 0xaf, 0xbf, 0x03, 0x00, 0x00, 0xff, 0xff, 0x2a, 0xe3, 0xe5, 0xdd, 0x9c, 0x02, 0x00, 0x00,
+	// 431 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0x4d, 0x8f, 0xd3, 0x30,
+	0x10, 0x25, 0x1f, 0x1b, 0x36, 0x93, 0x6e, 0x58, 0x0c, 0x2a, 0x21, 0x42, 0x10, 0xe5, 0x94, 0x0b,
+	0xa9, 0x28, 0x12, 0x42, 0x08, 0x89, 0x4b, 0x25, 0xb4, 0x3d, 0xac, 0x90, 0x59, 0x89, 0x73, 0xb3,
+	0x75, 0x8b, 0x21, 0x8d, 0x83, 0xe3, 0xb0, 0xea, 0xaf, 0xe0, 0x87, 0xf1, 0xa7, 0x90, 0xed, 0x24,
+	0x75, 0xd5, 0x56, 0xec, 0x6d, 0x3e, 0xde, 0x7b, 0x9e, 0x79, 0x1e, 0x18, 0xdd, 0x31, 0xfe, 0x93,
+	0xf0, 0xbc, 0xe6, 0x4c, 0x30, 0xe4, 0xd7, 0x45, 0xae, 0x0b, 0xf1, 0xab, 0x35, 0x63, 0xeb, 0x92,
+	0x4c, 0x54, 0xa3, 0x68, 0x57, 0x13, 0x41, 0x37, 0xa4, 0x11, 0x8b, 0x4d, 0xad, 0xb1, 0xe9, 0x05,
+	0x04, 0x5f, 0x68, 0xb5, 0xc6, 0xe4, 0x57, 0x4b, 0x1a, 0x91, 0xbe, 0x01, 0x5f, 0xa7, 0x75, 0xb9,
+	0x45, 0x08, 0xdc, 0x1f, 0xac, 0x68, 0x22, 0x2b, 0xb1, 0xb2, 0x33, 0xac, 0x62, 0x74, 0x09, 0x0e,
+	0xe1, 0x3c, 0xb2, 0x13, 0x2b, 0xf3, 0xb1, 0x0c, 0xd3, 0x4b, 0x08, 0x3f, 0x13, 0x31, 0x67, 0x45,
+	0xd3, 0x8b, 0xfc, 0xb5, 0xc0, 0x99, 0xb3, 0x02, 0x85, 0x60, 0x5f, 0xcd, 0x14, 0xdb, 0xc7, 0xf6,
+	0xd5, 0x4c, 0x72, 0x6b, 0xa2, 0xb9, 0x36, 0x96, 0x21, 0x8a, 0xe1, 0x7c, 0xd9, 0xf2, 0x85, 0xa0,
+	0xac, 0x8a, 0x1c, 0x55, 0x1e, 0x72, 0xf4, 0x1e, 0xfc, 0x46, 0x2c, 0xb8, 0xb8, 0xa1, 0x1b, 0x12,
+	0xb9, 0x89, 0x95, 0x05, 0xd3, 0x38, 0xd7, 0xeb, 0xe4, 0xfd, 0x3a, 0xf9, 0x4d, 0xbf, 0x0e, 0xde,
+	0x81, 0xd1, 0x07, 0x80, 0x15, 0xad, 0x68, 0xf3, 0x5d, 0x51, 0xcf, 0xfe, 0x4b, 0x35, 0xd0, 0x72,
+	0x46, 0x4e, 0x7e, 0x47, 0x5e, 0x62, 0x65, 0x2e, 0x96, 0x61, 0x3a, 0x83, 0xd1, 0xb0, 0x9f, 0x74,
+	0x25, 0x1d, 0x5c, 0x71, 0xb2, 0x60, 0x1a, 0xe6, 0x83, 0xd9, 0xf9, 0x9c, 0x15, 0x27, 0x5d, 0x7a,
+	0x04, 0x17, 0xd7, 0xe4, 0x4e, 0x22, 0x3a, 0x93, 0x26, 0x10, 0xf4, 0x05, 0xa9, 0x1a, 0x82, 0x4d,
+	0x97, 0xbd, 0x57, 0x74, 0x79, 0x44, 0xe1, 0x35, 0x3c, 0xe9, 0xe6, 0xf8, 0x4a, 0xab, 0x5b, 0xd2,
+	0xe9, 0xa0, 0x31, 0x78, 0xb7, 0x2d, 0x6f, 0x18, 0xef, 0xc8, 0x5d, 0x96, 0xb6, 0xf0, 0x78, 0x1f,
+	0x7e, 0xdf, 0xd9, 0x77, 0x82, 0xb6, 0x29, 0x28, 0xaf, 0x61, 0xd5, 0x96, 0xa5, 0xfa, 0xa7, 0x73,
+	0xac, 0xe2, 0x7e, 0x4a, 0x77, 0x98, 0x72, 0xfa, 0xc7, 0x06, 0xef, 0x9b, 0x92, 0x44, 0xef, 0xc0,
+	0x95, 0xb7, 0x84, 0xc6, 0xc6, 0x33, 0xc6, 0xad, 0xc5, 0x4f, 0x0f, 0xea, 0x75, 0xb9, 0x4d, 0x1f,
+	0xa0, 0x4f, 0xf0, 0xb0, 0x9b, 0x1c, 0x3d, 0x37, 0x20, 0xfb, 0x47, 0x16, 0x3f, 0x3b, 0xd6, 0xd2,
+	0x02, 0x1f, 0xc1, 0xd3, 0xd6, 0xa2, 0xc8, 0x00, 0xed, 0xd9, 0x1f, 0x8f, 0x8f, 0x74, 0x34, 0xfb,
+	0x1a, 0x46, 0xa6, 0x71, 0xe8, 0xe5, 0xe1, 0x43, 0xe6, 0x07, 0xc4, 0x2f, 0x4e, 0xf6, 0x95, 0x5e,
+	0xe1, 0xa9, 0x8b, 0x7b, 0xfb, 0x6f, 0x00, 0x18, 0x95, 0xa6, 0x5a, 0xa4, 0x03, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -382,6 +499,8 @@ type WorkerClient interface {
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingReply, error)
 	GetJobs(ctx context.Context, in *GetJobsRequest, opts ...grpc.CallOption) (*GetJobsReply, error)
 	NewJob(ctx context.Context, in *NewJobRequest, opts ...grpc.CallOption) (*NewJobReply, error)
+	// GetJobsSince returns only jobs changed after the cursor
+	GetJobsSince(ctx context.Context, in *GetJobsSinceRequest, opts ...grpc.CallOption) (*GetJobsSinceReply, error)
 }
 
 type workerClient struct {
@@ -419,12 +538,23 @@ func (c *workerClient) NewJob(ctx context.Context, in *NewJobRequest, opts ...gr
 	return out, nil
 }
 
+func (c *workerClient) GetJobsSince(ctx context.Context, in *GetJobsSinceRequest, opts ...grpc.CallOption) (*GetJobsSinceReply, error) {
+	out := new(GetJobsSinceReply)
+	err := c.cc.Invoke(ctx, "/pb.worker.Worker/GetJobsSince", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WorkerServer is the server API for Worker service.
 type WorkerServer interface {
 	// PingPong interaction
 	Ping(context.Context, *PingRequest) (*PingReply, error)
 	GetJobs(context.Context, *GetJobsRequest) (*GetJobsReply, error)
 	NewJob(context.Context, *NewJobRequest) (*NewJobReply, error)
+	// GetJobsSince returns only jobs changed after the cursor
+	GetJobsSince(context.Context, *GetJobsSinceRequest) (*GetJobsSinceReply, error)
 }
 
 func RegisterWorkerServer(s *grpc.Server, srv WorkerServer) {
@@ -485,6 +615,24 @@ func _Worker_NewJob_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Worker_GetJobsSince_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobsSinceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).GetJobsSince(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.worker.Worker/GetJobsSince",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).GetJobsSince(ctx, req.(*GetJobsSinceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Worker_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.worker.Worker",
 	HandlerType: (*WorkerServer)(nil),
@@ -501,6 +649,10 @@ var _Worker_serviceDesc = grpc.ServiceDesc{
 			MethodName: "NewJob",
 			Handler:    _Worker_NewJob_Handler,
 		},
+		{
+			MethodName: "GetJobsSince",
+			Handler:    _Worker_GetJobsSince_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "worker.proto",
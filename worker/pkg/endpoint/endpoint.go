@@ -15,8 +15,8 @@ import (
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
 
-	"worker/pkg/service"
 	worker "worker/pkg/model"
+	"worker/pkg/service"
 )
 
 // EndpointSet collects all of the endpoints that compose a repo service. It's meant to
@@ -26,6 +26,8 @@ type EndpointSet struct {
 	PingEndpoint    kitendpoint.Endpoint
 	NewJobEndpoint  kitendpoint.Endpoint
 	GetJobsEndpoint kitendpoint.Endpoint
+
+	GetJobsSinceEndpoint kitendpoint.Endpoint
 }
 
 // New returns a Set that wraps the provided server, and wires in all of the
@@ -61,10 +63,22 @@ func New(svc service.Service, logger log.Logger, duration metrics.Histogram, otT
 		getJobsEndpoint = InstrumentingMiddleware(duration.With("method", "GetJobs"))(getJobsEndpoint)
 	}
 
+	var getJobsSinceEndpoint kitendpoint.Endpoint
+	{
+		getJobsSinceEndpoint = MakeGetJobsSinceEndpoint(svc)
+		getJobsSinceEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Millisecond), 1))(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = opentracing.TraceServer(otTracer, "GetJobsSince")(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = LoggingMiddleware(log.With(logger, "method", "GetJobsSince"))(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = InstrumentingMiddleware(duration.With("method", "GetJobsSince"))(getJobsSinceEndpoint)
+	}
+
 	return EndpointSet{
 		PingEndpoint:    pingEndpoint,
 		NewJobEndpoint:  newJobEndpoint,
 		GetJobsEndpoint: getJobsEndpoint,
+
+		GetJobsSinceEndpoint: getJobsSinceEndpoint,
 	}
 }
 
@@ -163,3 +177,36 @@ func MakeGetJobsEndpoint(s service.Service) kitendpoint.Endpoint {
 		return GetJobsResponse{Jobs: jobs, Err: err}, nil
 	}
 }
+
+// ================ GetJobsSince =============
+
+// GetJobsSince implements the service interface, so EndpointSet may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s EndpointSet) GetJobsSince(ctx context.Context, cursor string) (worker.JobsDelta, error) {
+	resp, err := s.GetJobsSinceEndpoint(ctx, GetJobsSinceRequest{Cursor: cursor})
+	if err != nil {
+		return worker.JobsDelta{}, err
+	}
+	response := resp.(GetJobsSinceResponse)
+	return response.Delta, response.Err
+}
+
+// GetJobsSinceRequest collects the request parameters for the GetJobsSince method.
+type GetJobsSinceRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+// GetJobsSinceResponse collects the response values for the GetJobsSince method.
+type GetJobsSinceResponse struct {
+	Delta worker.JobsDelta `json:"delta"`
+	Err   error            `json:"-"` // should be intercepted by Failed/errorEncoder
+}
+
+// MakeGetJobsSinceEndpoint constructs a GetJobsSince endpoint wrapping the service.
+func MakeGetJobsSinceEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetJobsSinceRequest)
+		delta, err := s.GetJobsSince(ctx, req.Cursor)
+		return GetJobsSinceResponse{Delta: delta, Err: err}, nil
+	}
+}
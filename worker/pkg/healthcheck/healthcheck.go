@@ -0,0 +1,117 @@
+// Package healthcheck drives the standard gRPC health-checking protocol
+// (grpc.health.v1.Health) from checks of the dependencies of a service.
+package healthcheck
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check tells whether a dependency works, it returns nil if it does.
+type Check func() error
+
+// Checks are named checks of dependencies.
+type Checks map[string]Check
+
+// Watcher sets the status of services every interval: SERVING when all
+// checks pass and NOT_SERVING otherwise. The overall status, the empty
+// service name, follows the same rule.
+type Watcher struct {
+	srv      *health.Server
+	services []string
+	checks   Checks
+	logger   log.Logger
+
+	mtx    sync.Mutex
+	status healthpb.HealthCheckResponse_ServingStatus
+	down   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Watch runs the checks once, so the status is known before the gRPC server
+// starts, and then keeps running them in the background until Stop.
+func Watch(srv *health.Server, services []string, checks Checks, interval time.Duration, logger log.Logger) *Watcher {
+	w := &Watcher{
+		srv:      srv,
+		services: append([]string{""}, services...),
+		checks:   checks,
+		logger:   logger,
+		status:   healthpb.HealthCheckResponse_UNKNOWN,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.update()
+	go w.run(interval)
+	return w
+}
+
+// Shutdown reports NOT_SERVING for all services from now on, regardless of
+// the checks. It is called when the service is going away.
+func (w *Watcher) Shutdown() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.down = true
+	w.setLocked(healthpb.HealthCheckResponse_NOT_SERVING, nil)
+}
+
+// Stop stops running the checks.
+func (w *Watcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
+
+func (w *Watcher) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.update()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *Watcher) update() {
+	failed := make([]string, 0)
+	for name, check := range w.checks {
+		if err := check(); err != nil {
+			failed = append(failed, name+": "+err.Error())
+		}
+	}
+	sort.Strings(failed)
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if len(failed) > 0 {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.down {
+		return
+	}
+	w.setLocked(status, failed)
+}
+
+func (w *Watcher) setLocked(status healthpb.HealthCheckResponse_ServingStatus, failed []string) {
+	if status == w.status {
+		return
+	}
+	w.logger.Log("health", status.String(), "was", w.status.String(), "failed", strings.Join(failed, "; "))
+	w.status = status
+	for _, s := range w.services {
+		w.srv.SetServingStatus(s, status)
+	}
+}
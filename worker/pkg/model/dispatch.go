@@ -0,0 +1,25 @@
+package model
+
+// DispatchSubject is where the repository offers jobs when it dispatches them
+// over NATS instead of calling a worker. Workers with free capacity take them
+// in the DispatchQueue queue group, so every job goes to a single worker.
+const DispatchSubject = "DispatchJob"
+
+// DispatchQueue is the queue group of workers taking dispatched jobs.
+const DispatchQueue = "workers"
+
+// Dispatch offers a job to a worker. The worker starts it under the given ID
+// and acknowledges it with a DispatchAck reply. A job which isn't acknowledged
+// in time is offered again, possibly to another worker, with a higher Attempt.
+type Dispatch struct {
+	JobID   string `json:"job"`
+	Attempt int    `json:"attempt"`
+}
+
+// DispatchAck acknowledges a dispatched job, Err is set when the worker
+// refused it and the job should be offered again.
+type DispatchAck struct {
+	JobID  string `json:"job"`
+	NodeID string `json:"node"`
+	Err    string `json:"err,omitempty"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// HeartbeatSubject is a NATS subject workers publish heartbeats to.
+const HeartbeatSubject = RepositorySubjects + "Heartbeat"
+
+// Heartbeat is published by a worker periodically. It carries the number of
+// running jobs and the jobs changed since the previous heartbeat. A full
+// heartbeat carries all jobs of the worker instead, so the repository can
+// resync after a lost message.
+type Heartbeat struct {
+	NodeID    string `json:"node"`
+	Seq       uint64 `json:"seq"`
+	JobsCount int    `json:"jobsCount"`
+	Full      bool   `json:"full"`
+	Jobs      []Job  `json:"jobs"`
+}
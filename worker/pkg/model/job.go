@@ -17,11 +17,21 @@ type Job struct {
 	StartTime  time.Time     `json:"startTime"`
 	Duration   time.Duration `json:"Duration"`
 	FinishTime time.Time     `json:"finishTime"`
+	Rev        uint64        `json:"rev"` // revision of the last change
+}
+
+// JobsDelta is a set of jobs changed after a cursor. Full is set when the
+// cursor is unknown to the worker, then Jobs are all jobs of the worker.
+// Cursor is passed to the next call to get later changes.
+type JobsDelta struct {
+	Jobs   []Job  `json:"jobs"`
+	Cursor string `json:"cursor"`
+	Full   bool   `json:"full"`
 }
 
 func NewJob() *Job {
 	return &Job{
-		ID:        JobID{uuid.New()},
+		ID:        JobID{UUID: uuid.New()},
 		Per:       0,
 		StartTime: time.Now(),
 	}
@@ -0,0 +1,30 @@
+package model
+
+// RepositorySubjects prefixes the NATS subjects of all repository operations,
+// so they don't clash with other systems on the same server and can be
+// granted with a single permission.
+const RepositorySubjects = "repository."
+
+// RegisterSubject is a NATS subject workers register in the repository on.
+const RegisterSubject = RepositorySubjects + "RegisterNode"
+
+// UnknownNode is the error the repository replies with to a worker it
+// doesn't know, the worker has to register again.
+const UnknownNode = "unknown node"
+
+// Registration is a request on RegisterSubject. A worker registers under its
+// own stable ID, so it keeps it when it registers again.
+type Registration struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+	Port string `json:"port"`
+}
+
+// RegistrationReply is the reply on RegisterSubject. Generation counts
+// registrations of the node.
+type RegistrationReply struct {
+	ID         string `json:"id"`
+	Generation uint64 `json:"generation"`
+	Err        string `json:"err,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// DrainSubject is a NATS subject a worker which shuts down asks the
+// repository on to stop scheduling jobs on it.
+const DrainSubject = RepositorySubjects + "DrainNode"
+
+// DeregisterSubject is a NATS subject a worker asks the repository on to
+// forget it at the end of a shutdown.
+const DeregisterSubject = RepositorySubjects + "DeregisterNode"
+
+// Drain is a request on DrainSubject.
+type Drain struct {
+	NodeID string `json:"node"`
+}
+
+// Deregister is a request on DeregisterSubject. Unfinished jobs are handed
+// back to the repository, which starts them again on other nodes.
+type Deregister struct {
+	NodeID     string `json:"node"`
+	Unfinished []Job  `json:"unfinished"`
+}
+
+// DeregisterReply is the reply on DeregisterSubject, Rescheduled is how many
+// of the unfinished jobs are started again on other nodes. They are started
+// after the reply.
+type DeregisterReply struct {
+	Rescheduled int    `json:"rescheduled"`
+	Err         string `json:"err,omitempty"`
+}
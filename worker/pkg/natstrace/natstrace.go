@@ -0,0 +1,68 @@
+// Package natstrace carries trace context over NATS. The NATS client has no
+// message headers, so a traced message is a JSON envelope holding the span
+// context of the sender, in the TextMap format of the tracer, next to the
+// original message. Receivers accept plain messages as well, they just don't
+// join a trace then.
+package natstrace
+
+import (
+	"encoding/json"
+
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// Envelope is a JSON message sent within a span.
+type Envelope struct {
+	Trace   map[string]string `json:"trace"`
+	Payload json.RawMessage   `json:"payload"`
+}
+
+// Wrap returns the JSON message data in an envelope with the context of the
+// span. Without a span data is returned as it is.
+func Wrap(tracer stdopentracing.Tracer, span stdopentracing.Span, data []byte) ([]byte, error) {
+	if span == nil {
+		return data, nil
+	}
+	carrier := stdopentracing.TextMapCarrier{}
+	if err := tracer.Inject(span.Context(), stdopentracing.TextMap, carrier); err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Trace: carrier, Payload: data})
+}
+
+// Unwrap returns the payload of an envelope and the span context it carries.
+// Other messages are returned as they are, with a nil span context.
+func Unwrap(tracer stdopentracing.Tracer, data []byte) ([]byte, stdopentracing.SpanContext) {
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil || e.Trace == nil || len(e.Payload) == 0 {
+		return data, nil
+	}
+	sc, err := tracer.Extract(stdopentracing.TextMap, stdopentracing.TextMapCarrier(e.Trace))
+	if err != nil {
+		return e.Payload, nil
+	}
+	return e.Payload, sc
+}
+
+// StartClientSpan starts a span of a request sent over NATS, a child of the
+// parent context if it isn't nil.
+func StartClientSpan(tracer stdopentracing.Tracer, operationName string, parent stdopentracing.SpanContext) stdopentracing.Span {
+	opts := []stdopentracing.StartSpanOption{ext.SpanKindRPCClient, stdopentracing.Tag{Key: "transport", Value: "NATS"}}
+	if parent != nil {
+		opts = append(opts, stdopentracing.ChildOf(parent))
+	}
+	return tracer.StartSpan(operationName, opts...)
+}
+
+// StartServerSpan starts a span of a message received over NATS, which joins
+// the trace of the sender if the message carried one.
+func StartServerSpan(tracer stdopentracing.Tracer, operationName string, remote stdopentracing.SpanContext) stdopentracing.Span {
+	opts := []stdopentracing.StartSpanOption{stdopentracing.Tag{Key: "transport", Value: "NATS"}}
+	if remote != nil {
+		opts = append(opts, ext.RPCServerOption(remote))
+	} else {
+		opts = append(opts, ext.SpanKindRPCServer)
+	}
+	return tracer.StartSpan(operationName, opts...)
+}
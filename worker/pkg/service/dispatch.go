@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"worker/pkg/model"
+	"worker/pkg/natstrace"
+)
+
+// DefaultCapacity is how many jobs a worker runs at the same time before it
+// stops taking dispatched jobs.
+const DefaultCapacity = 4
+
+// pullJobs takes jobs dispatched over NATS while the worker has free
+// capacity. The worker joins the dispatch queue group with a subscription
+// which the server ends after as many messages as there are free slots, so a
+// busy worker isn't offered jobs. It rejoins once jobs finish.
+func (w Worker) pullJobs() {
+	ticker := time.NewTicker(tickerPeriod)
+	defer ticker.Stop()
+
+	var (
+		sub       *nats.Subscription
+		allowance int    // messages the subscription may still deliver
+		delivered *int32 // messages delivered to the subscription
+	)
+	leave := func() {
+		if sub != nil && sub.IsValid() {
+			sub.Unsubscribe()
+		}
+		sub = nil
+	}
+	defer leave()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+
+		if atomic.LoadInt32(w.draining) == 1 {
+			leave()
+			continue
+		}
+		free := w.capacity - w.running()
+		if sub != nil && sub.IsValid() {
+			left := allowance - int(atomic.LoadInt32(delivered))
+			if left >= free {
+				continue
+			}
+			// More slots are free than the subscription allows, join again
+			// with the new allowance.
+			leave()
+		}
+		if free <= 0 {
+			continue
+		}
+
+		// The subscription is gone with a connection the client gave up on,
+		// the next tick joins on the new one.
+		nc := w.conn.Conn()
+		if nc == nil {
+			continue
+		}
+		count := new(int32)
+		s, err := nc.QueueSubscribe(model.DispatchSubject, model.DispatchQueue, func(msg *nats.Msg) {
+			atomic.AddInt32(count, 1)
+			w.takeJob(msg)
+		})
+		if err == nil {
+			err = s.AutoUnsubscribe(free)
+		}
+		if err != nil {
+			w.logger.Log("method", "pullJobs", "err", err)
+			continue
+		}
+		sub, allowance, delivered = s, free, count
+	}
+}
+
+// takeJob starts a dispatched job and acknowledges it. A job the worker has
+// already started, because an acknowledgement got lost, is acknowledged again.
+func (w Worker) takeJob(msg *nats.Msg) {
+	tracer := stdopentracing.GlobalTracer()
+	data, remote := natstrace.Unwrap(tracer, msg.Data)
+	span := natstrace.StartServerSpan(tracer, "takeJob", remote)
+	defer span.Finish()
+
+	var d model.Dispatch
+	ack := model.DispatchAck{NodeID: w.nodeID}
+	err := json.Unmarshal(data, &d)
+	if err == nil {
+		ack.JobID = d.JobID
+		err = w.startJob(d.JobID)
+	}
+	if err != nil {
+		ack.Err = err.Error()
+		span.LogKV("err", err)
+	}
+	span.SetTag("job", d.JobID)
+	w.logger.Log("method", "takeJob", "job", d.JobID, "attempt", d.Attempt, "err", err)
+
+	data, err = json.Marshal(ack)
+	if err != nil {
+		w.logger.Log("method", "takeJob", "err", err)
+		return
+	}
+	if err := w.conn.Publish(msg.Reply, data); err != nil {
+		w.logger.Log("method", "takeJob", "err", err)
+	}
+}
+
+// startJob starts a job under the ID given by the repository.
+func (w Worker) startJob(id string) error {
+	if atomic.LoadInt32(w.draining) == 1 {
+		return ErrDraining
+	}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	jobID := model.JobID{UUID: uid}
+	if _, ok := w.jobs[jobID]; ok {
+		return nil
+	}
+	if w.activeJobsLen() >= w.capacity {
+		return ErrNoCapacity
+	}
+	job := model.NewJob()
+	job.ID = jobID
+	job.Rev = w.nextRev()
+	w.jobs[job.ID] = job
+	return nil
+}
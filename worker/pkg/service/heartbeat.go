@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"worker/pkg/model"
+)
+
+// fullHeartbeatEvery is how often a heartbeat carries all jobs instead of a delta.
+const fullHeartbeatEvery = 30
+
+// sendHeartbeats publishes heartbeats to the repository until the worker stops.
+func (w Worker) sendHeartbeats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cursor := "" // the cursor the repository is in sync with, all jobs if empty
+	var seq uint64
+	sinceFull := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+
+		seq++
+		// A new registration or a failed heartbeat makes the repository
+		// miss jobs, they are all sent again.
+		if sinceFull >= fullHeartbeatEvery || w.session.takeResync() {
+			cursor = ""
+		}
+		hb, next := w.heartbeat(cursor)
+		hb.Seq = seq
+		data, err := json.Marshal(hb)
+		if err != nil {
+			w.logger.Log("method", "sendHeartbeats", "err", err)
+			continue
+		}
+		// Replies tell whether the repository still knows the worker.
+		if err := w.conn.PublishRequest(model.HeartbeatSubject, w.inbox, data); err != nil {
+			w.logger.Log("method", "sendHeartbeats", "err", err)
+			// The delta is lost, the next heartbeat has to be a full one.
+			cursor = ""
+			continue
+		}
+		if hb.Full {
+			sinceFull = 0
+		}
+		sinceFull++
+		cursor = next
+	}
+}
+
+// heartbeat returns a heartbeat with jobs changed after the cursor, or with
+// all jobs if the cursor is empty, and the cursor of the next heartbeat.
+func (w Worker) heartbeat(cursor string) (model.Heartbeat, string) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	delta := w.jobsSince(cursor)
+	hb := model.Heartbeat{
+		NodeID:    w.nodeID,
+		JobsCount: w.activeJobsLen(),
+		Full:      delta.Full,
+		Jobs:      delta.Jobs,
+	}
+	return hb, delta.Cursor
+}
@@ -2,7 +2,7 @@ package service
 
 import (
 	"context"
-	
+
 	"github.com/go-kit/kit/metrics"
 
 	"worker/pkg/model"
@@ -49,3 +49,9 @@ func (mw instrumentingMiddleware) GetJobs(ctx context.Context) ([]model.Job, err
 	mw.getJobs.Add(1)
 	return jobs, err
 }
+
+func (mw instrumentingMiddleware) GetJobsSince(ctx context.Context, cursor string) (model.JobsDelta, error) {
+	delta, err := mw.next.GetJobsSince(ctx, cursor)
+	mw.getJobs.Add(1)
+	return delta, err
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"worker/pkg/model"
+)
+
+// GetJobsSince returns jobs changed after the cursor returned by a previous
+// call. An empty cursor, a malformed one or a cursor of another run of the
+// worker gets all jobs.
+func (w Worker) GetJobsSince(ctx context.Context, cursor string) (model.JobsDelta, error) {
+	w.session.touch()
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	return w.jobsSince(cursor), nil
+}
+
+// jobsSince must be called with the lock held.
+func (w Worker) jobsSince(cursor string) model.JobsDelta {
+	since, ok := w.parseCursor(cursor)
+	delta := model.JobsDelta{
+		Jobs:   make([]model.Job, 0),
+		Cursor: w.cursor(*w.rev),
+		Full:   !ok,
+	}
+	for _, j := range w.jobs {
+		if !ok || j.Rev > since {
+			delta.Jobs = append(delta.Jobs, *j)
+		}
+	}
+	return delta
+}
+
+// nextRev must be called with the write lock held.
+func (w Worker) nextRev() uint64 {
+	*w.rev++
+	return *w.rev
+}
+
+// cursor is the epoch of the worker and a revision of jobs.
+func (w Worker) cursor(rev uint64) string {
+	return fmt.Sprintf("%s.%d", w.epoch, rev)
+}
+
+func (w Worker) parseCursor(cursor string) (uint64, bool) {
+	i := strings.LastIndex(cursor, ".")
+	if i < 0 || cursor[:i] != w.epoch {
+		return 0, false
+	}
+	rev, err := strconv.ParseUint(cursor[i+1:], 10, 64)
+	if err != nil || rev > *w.rev {
+		return 0, false
+	}
+	return rev, true
+}
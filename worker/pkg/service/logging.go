@@ -2,9 +2,9 @@ package service
 
 import (
 	"context"
-	
+
 	"github.com/go-kit/kit/log"
-	
+
 	"worker/pkg/model"
 )
 
@@ -41,3 +41,10 @@ func (mw loggingMiddleware) GetJobs(ctx context.Context) (jobs []model.Job, err
 	}()
 	return mw.next.GetJobs(ctx)
 }
+
+func (mw loggingMiddleware) GetJobsSince(ctx context.Context, cursor string) (delta model.JobsDelta, err error) {
+	defer func() {
+		mw.logger.Log("method", "GetJobsSince", "cursor", cursor, "len(jobs)", len(delta.Jobs), "full", delta.Full, "err", err)
+	}()
+	return mw.next.GetJobsSince(ctx, cursor)
+}
@@ -15,13 +15,14 @@ type Service interface {
 	Ping(ctx context.Context) (int, error)
 	NewJob(ctx context.Context) (string, error)
 	GetJobs(ctx context.Context) ([]model.Job, error)
+	GetJobsSince(ctx context.Context, cursor string) (model.JobsDelta, error)
 }
 
 // New returns a basic Service with all of the expected middlewares wired in.
-func New(name string, IP string, port string, natsAddr string, logger log.Logger, pings, newJobs, getJobs metrics.Counter) Service {
+func New(w Worker, logger log.Logger, pings, newJobs, getJobs metrics.Counter) Service {
 	var svc Service
 	{
-		svc = NewWorker(name, IP, port, natsAddr, logger)
+		svc = w
 		svc = LoggingMiddleware(logger)(svc)
 		svc = InstrumentingMiddleware(pings, newJobs, getJobs)(svc)
 	}
@@ -31,4 +32,12 @@ func New(name string, IP string, port string, natsAddr string, logger log.Logger
 var (
 	// ErrWorkerUnevailable allows say that something wrong happens a worker
 	ErrWorkerUnevailable = errors.New("can't connect to a local repository with jobs")
+	// ErrNATSUnavailable is reported by the health check while the worker
+	// can't reach the repository over NATS.
+	ErrNATSUnavailable = errors.New("no connection to NATS")
+	// ErrDraining is returned by NewJob while the worker shuts down.
+	ErrDraining = errors.New("worker is shutting down")
+	// ErrNoCapacity refuses a dispatched job while the worker runs as many
+	// jobs as its capacity.
+	ErrNoCapacity = errors.New("worker has no free capacity")
 )
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/go-nats"
+
+	"worker/pkg/model"
+)
+
+// DefaultSessionTimeout is how long the repository may stay silent before a
+// worker registers again.
+const DefaultSessionTimeout = 30 * time.Second
+
+// NodeID derives a stable ID of a worker from its name and address, the
+// worker registers under the same ID after a restart.
+func NodeID(name, IP, port string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("worker://"+name+"@"+IP+port)).String()
+}
+
+// session is the registration of the worker in the repository. The worker
+// keeps its ID across registrations, the repository counts them as
+// generations.
+type session struct {
+	mtx         sync.Mutex
+	generation  uint64
+	lastContact time.Time
+	resync      bool          // the next heartbeat has to carry all jobs
+	lost        chan struct{} // the repository doesn't know the worker
+}
+
+func newSession() *session {
+	return &session{lost: make(chan struct{}, 1)}
+}
+
+// start begins a new generation of the registration.
+func (s *session) start(generation uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.generation = generation
+	s.lastContact = time.Now()
+	s.resync = true
+}
+
+// touch records that the repository has just talked to the worker.
+func (s *session) touch() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.lastContact = time.Now()
+}
+
+// silence returns how long the repository hasn't talked to the worker.
+func (s *session) silence() time.Duration {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return time.Since(s.lastContact)
+}
+
+// forceResync makes the next heartbeat carry all jobs.
+func (s *session) forceResync() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.resync = true
+}
+
+// takeResync tells whether the next heartbeat has to carry all jobs.
+func (s *session) takeResync() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r := s.resync
+	s.resync = false
+	return r
+}
+
+// lose asks for a new registration.
+func (s *session) lose() {
+	select {
+	case s.lost <- struct{}{}:
+	default:
+	}
+}
+
+// register registers the worker in the repository over NATS and returns the
+// generation of the registration.
+func (w Worker) register() (uint64, error) {
+	msg, err := tracedRequest(w.conn, model.RegisterSubject, model.Registration{ID: w.nodeID, Name: w.name, IP: w.IP, Port: w.port})
+	if err != nil {
+		return 0, err
+	}
+	var reply model.RegistrationReply
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return 0, err
+	}
+	if reply.Err != "" {
+		return 0, errors.New(reply.Err)
+	}
+	return reply.Generation, nil
+}
+
+// keepSession registers the worker again when the repository forgets it:
+// a heartbeat is answered with UnknownNode, or neither pings nor heartbeat
+// replies came for the timeout.
+func (w Worker) keepSession(timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.session.lost:
+			w.reregister("unknown node")
+		case <-ticker.C:
+			if w.session.silence() > timeout {
+				w.reregister("no contact")
+			}
+		}
+	}
+}
+
+func (w Worker) reregister(reason string) {
+	if atomic.LoadInt32(w.draining) == 1 {
+		return
+	}
+	generation, err := w.register()
+	if err != nil {
+		w.logger.Log("method", "keepSession", "reason", reason, "err", err)
+		return
+	}
+	w.session.start(generation)
+	w.logger.Log("method", "keepSession", "reason", reason, "message", "Node registered again", "id", w.nodeID, "generation", generation)
+}
+
+// heartbeatReply handles replies to heartbeats. A heartbeat the repository
+// accepted keeps the session alive. The jobs of a heartbeat it failed may
+// not be stored, the next heartbeat carries all jobs.
+func (w Worker) heartbeatReply(msg *nats.Msg) {
+	var reply struct {
+		Err string `json:"err"`
+	}
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		w.logger.Log("method", "heartbeatReply", "err", err)
+		w.session.forceResync()
+		return
+	}
+	switch reply.Err {
+	case "":
+		w.session.touch()
+	case model.UnknownNode:
+		w.session.lose()
+	default:
+		w.logger.Log("method", "heartbeatReply", "err", reply.Err)
+		w.session.forceResync()
+	}
+}
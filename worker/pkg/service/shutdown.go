@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/go-nats"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"worker/pkg/model"
+	"worker/pkg/natsconn"
+	"worker/pkg/natstrace"
+)
+
+// requestTimeout is how long a request to the repository over NATS waits for
+// a reply.
+const requestTimeout = 10 * time.Second
+
+// Shutdown drains the worker and deregisters it from the repository. NewJob
+// is refused from now on and the repository stops scheduling jobs on the
+// node. Running jobs get up to grace to finish, the ones still running then
+// are handed back to the repository, which starts them on other nodes.
+func (w Worker) Shutdown(grace time.Duration) {
+	atomic.StoreInt32(w.draining, 1)
+	w.logger.Log("method", "Shutdown", "action", "draining", "grace", grace)
+
+	if err := w.request(model.DrainSubject, model.Drain{NodeID: w.nodeID}, nil); err != nil {
+		w.logger.Log("method", "Shutdown", "action", "DrainNode call", "err", err)
+	}
+
+	deadline := time.Now().Add(grace)
+	ticker := time.NewTicker(tickerPeriod)
+	for w.running() > 0 && time.Now().Before(deadline) {
+		<-ticker.C
+	}
+	ticker.Stop()
+
+	// Jobs and heartbeats stop before the node is forgotten, a late
+	// heartbeat would find it unknown.
+	w.Stop()
+	unfinished := w.unfinishedJobs()
+	var reply model.DeregisterReply
+	err := w.request(model.DeregisterSubject, model.Deregister{NodeID: w.nodeID, Unfinished: unfinished}, &reply)
+	if err != nil {
+		w.logger.Log("method", "Shutdown", "action", "DeregisterNode call", "unfinished", len(unfinished), "err", err)
+	} else {
+		w.logger.Log("method", "Shutdown", "action", "deregistered", "unfinished", len(unfinished), "rescheduled", reply.Rescheduled)
+	}
+
+	w.conn.Close()
+}
+
+// running returns the number of jobs which haven't finished yet.
+func (w Worker) running() int {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	return w.activeJobsLen()
+}
+
+// unfinishedJobs returns copies of the jobs which haven't finished yet.
+func (w Worker) unfinishedJobs() []model.Job {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	jobs := make([]model.Job, 0)
+	for _, j := range w.jobs {
+		if j.Per < 100 {
+			jobs = append(jobs, *j)
+		}
+	}
+	return jobs
+}
+
+// request sends a JSON request to the repository over NATS and decodes the
+// reply into resp unless it is nil. An error in the reply is returned.
+func (w Worker) request(subject string, req interface{}, resp interface{}) error {
+	msg, err := tracedRequest(w.conn, subject, req)
+	if err != nil {
+		return err
+	}
+
+	var e struct {
+		Err string `json:"err"`
+	}
+	if err := json.Unmarshal(msg.Data, &e); err != nil {
+		return err
+	}
+	if e.Err != "" {
+		return errors.New(e.Err)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.Data, resp)
+}
+
+// tracedRequest sends a JSON request in a new client span named after the
+// subject, the repository continues the trace, see natstrace.
+func tracedRequest(conn *natsconn.Manager, subject string, req interface{}) (*nats.Msg, error) {
+	tracer := stdopentracing.GlobalTracer()
+	span := natstrace.StartClientSpan(tracer, subject, nil)
+	defer span.Finish()
+
+	data, err := json.Marshal(req)
+	if err == nil {
+		data, err = natstrace.Wrap(tracer, span, data)
+	}
+	if err != nil {
+		ext.Error.Set(span, true)
+		return nil, err
+	}
+	msg, err := conn.Request(subject, data, requestTimeout)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("err", err)
+	}
+	return msg, err
+}
@@ -1,19 +1,21 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
-	"context"
 
 	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
 	"github.com/nats-io/go-nats"
 	"github.com/shirou/gopsutil/cpu"
 
 	"worker/pkg/model"
+	"worker/pkg/natsconn"
 )
 
 const (
@@ -25,13 +27,20 @@ const (
 
 // Worker implements Repo interface
 type Worker struct {
-	mtx      sync.RWMutex
+	mtx      *sync.RWMutex
 	jobs     map[model.JobID]*model.Job
+	rev      *uint64 // revision of the last change of jobs
+	epoch    string  // changes when the worker restarts, see GetJobsSince
 	stop     chan struct{}
+	draining *int32 // 1 once Shutdown started, NewJob is refused then
 	name     string
 	IP       string
 	port     string
-	natsAddr string
+	conn     *natsconn.Manager
+	inbox    string // heartbeat replies come here
+	nodeID   string // stable across registrations
+	session  *session
+	capacity int // jobs running at the same time, see pullJobs
 	logger   log.Logger
 	CPUCount int
 	CPUModel string
@@ -40,7 +49,12 @@ type Worker struct {
 }
 
 // NewWorker create new repository of nodes which stored in object behind the Storage interface
-func NewWorker(name string, IP string, port string, natsAddr string, logger log.Logger) Worker {
+// The worker reports its jobs to the repository with heartbeats every heartbeatInterval.
+// It registers under the ID and registers again if the repository doesn't talk
+// to it for the session timeout. Jobs dispatched over NATS are taken while
+// fewer than capacity jobs run. The worker talks to the repository over the
+// NATS connection conn and closes it on Shutdown.
+func NewWorker(id string, name string, IP string, port string, conn *natsconn.Manager, heartbeatInterval, sessionTimeout time.Duration, capacity int, logger log.Logger) Worker {
 
 	is, _ := cpu.Info()
 
@@ -57,12 +71,19 @@ func NewWorker(name string, IP string, port string, natsAddr string, logger log.
 	logger.Log("worker", "New", "tCPUMhz", tCPUMhz)
 
 	w := Worker{
+		mtx:      &sync.RWMutex{},
 		jobs:     make(map[model.JobID]*model.Job),
+		rev:      new(uint64),
+		epoch:    uuid.New().String(),
 		stop:     make(chan struct{}),
+		draining: new(int32),
 		name:     name,
 		IP:       IP,
 		port:     port,
-		natsAddr: natsAddr,
+		conn:     conn,
+		nodeID:   id,
+		session:  newSession(),
+		capacity: capacity,
 		logger:   logger,
 		CPUCount: len(is),
 		CPUModel: is[0].ModelName,
@@ -80,31 +101,49 @@ func NewWorker(name string, IP string, port string, natsAddr string, logger log.
 		os.Exit(1)
 	}
 	go w.updateJobsStatus()
+	go w.sendHeartbeats(heartbeatInterval)
+	go w.keepSession(sessionTimeout)
+	go w.pullJobs()
 
 	return w
 }
 
 func (w *Worker) Stop() {
-	w.stop <- struct{}{}
+	close(w.stop)
+}
+
+// Check is the health check of the worker. Without NATS its jobs are not
+// reported to the repository.
+func (w Worker) Check() error {
+	if err := w.conn.Check(); err != nil {
+		return ErrNATSUnavailable
+	}
+	return nil
 }
 
 func (w Worker) Ping(ctx context.Context) (int, error) {
+	w.session.touch()
 	return w.activeJobsLen(), nil
 }
 
 func (w Worker) NewJob(ctx context.Context) (string, error) {
+	if atomic.LoadInt32(w.draining) == 1 {
+		return "", ErrDraining
+	}
 
 	job := model.NewJob()
 
 	w.mtx.Lock()
 	defer w.mtx.Unlock()
 
+	job.Rev = w.nextRev()
 	w.jobs[job.ID] = job
 
 	return job.ID.String(), nil
 }
 
 func (w Worker) GetJobs(ctx context.Context) ([]model.Job, error) {
+	w.session.touch()
 	w.mtx.RLock()
 	defer w.mtx.RUnlock()
 	jobs := make([]model.Job, 0)
@@ -121,6 +160,7 @@ func (w Worker) updateJobsStatus() {
 	for {
 		select {
 		case <-ticker.C:
+			w.mtx.Lock()
 			for _, j := range w.jobs {
 				if j.Per < 100 {
 					mgzForJob := int(w.tCPUMhz) / w.activeJobsLen() // number of Mgz for performing current job
@@ -129,8 +169,10 @@ func (w Worker) updateJobsStatus() {
 					if j.Per >= 100 {
 						j.Finish()
 					}
+					j.Rev = w.nextRev()
 				}
 			}
+			w.mtx.Unlock()
 		case <-w.stop:
 			return
 		}
@@ -147,48 +189,29 @@ func (w *Worker) activeJobsLen() int {
 	return len
 }
 
+// registerItself registers the worker, it retries until the repository
+// answers. Heartbeat replies come to an inbox subscribed on the connection,
+// the subscription outlives reconnects.
 func (w *Worker) registerItself() error {
-
-	var waitG sync.WaitGroup
-	waitG.Add(1)
+	w.inbox = nats.NewInbox()
+	if err := w.conn.Subscribe(w.inbox, "", natsconn.Serve(w.heartbeatReply)); err != nil {
+		return err
+	}
 
 	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
 
-	go func() {
-		for range ticker.C {
-			// tryint to connect to NATS
-			nc, err := nats.Connect(w.natsAddr)
-			if err != nil {
-				w.logger.Log("method", "registerItself", "natsAddr", w.natsAddr, "err", err)
-			} else {
-				// prepering the message to a RegisterNode method
-				str := `{"name":"` + w.name + `", "ip":"` + w.IP + `", "port":"` + w.port + `"}`
-				// tryint to call a method RegisterNode
-				r, err := nc.Request("RegisterNode", []byte(str), 10*time.Second)
-				if err != nil {
-					w.logger.Log("method", "registerItself", "action", "RegisterNode call", "err", err)
-				} else {
-					// creating a structure for parsing a responce
-					var resp struct {
-						String string `json:"str"`
-						Err    string `json:"err"`
-					}
-					// encoding the response
-					switch err = json.Unmarshal(r.Data, &resp); {
-					case err != nil:
-						w.logger.Log("method", "registerItself", "action", "Unmarshal a RegisterNode responce", "err", err)
-					case resp.Err != "":
-						w.logger.Log("method", "registerItself", "action", "Something wrong on the repository site", "err", resp.Err)
-					default:
-						w.logger.Log("method", "registerItself", "message", "Node registered succesfully", "id", string(r.Data))
-						ticker.Stop()
-						waitG.Done()
-					}
-				}
-			}
+	for range ticker.C {
+		// tryint to call a method RegisterNode
+		generation, err := w.register()
+		if err != nil {
+			w.logger.Log("method", "registerItself", "action", "RegisterNode call", "err", err)
+			continue
 		}
-	}()
 
-	waitG.Wait()
+		w.logger.Log("method", "registerItself", "message", "Node registered succesfully", "id", w.nodeID, "generation", generation)
+		w.session.start(generation)
+		return nil
+	}
 	return nil
 }
@@ -23,16 +23,18 @@ import (
 
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 
+	pb "worker/pb"
 	"worker/pkg/endpoint"
-	"worker/pkg/service"
 	worker "worker/pkg/model"
-	pb "worker/pb"
+	"worker/pkg/service"
 )
 
 type grpcServer struct {
 	ping    grpctransport.Handler
 	getJobs grpctransport.Handler
 	newJob  grpctransport.Handler
+
+	getJobsSince grpctransport.Handler
 }
 
 // NewGRPCServer makes a set of endpoints available as a gRPC AddServer.
@@ -61,6 +63,12 @@ func NewGRPCServer(endpoints endpoint.EndpointSet, otTracer stdopentracing.Trace
 			encodeGRPCNewJobResponse,
 			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "NewJob", logger)))...,
 		),
+		getJobsSince: grpctransport.NewServer(
+			endpoints.GetJobsSinceEndpoint,
+			decodeGRPCGetJobsSinceRequest,
+			encodeGRPCGetJobsSinceResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "GetJobsSince", logger)))...,
+		),
 	}
 }
 
@@ -88,6 +96,14 @@ func (s *grpcServer) NewJob(ctx context.Context, req *pb.NewJobRequest) (*pb.New
 	return rep.(*pb.NewJobReply), nil
 }
 
+func (s *grpcServer) GetJobsSince(ctx context.Context, req *pb.GetJobsSinceRequest) (*pb.GetJobsSinceReply, error) {
+	_, rep, err := s.getJobsSince.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.GetJobsSinceReply), nil
+}
+
 // NewGRPCClient returns an WorkerService backed by a gRPC server at the other end
 // of the conn. The caller is responsible for constructing the conn, and
 // eventually closing the underlying transport. We bake-in certain middlewares,
@@ -164,6 +180,25 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, logger
 		}))(newJobEndpoint)
 	}
 
+	var getJobsSinceEndpoint kitendpoint.Endpoint
+	{
+		getJobsSinceEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.worker.Worker",
+			"GetJobsSince",
+			encodeGRPCGetJobsSinceRequest,
+			decodeGRPCGetJobsSinceResponse,
+			pb.GetJobsSinceReply{},
+			append(options, grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)))...,
+		).Endpoint()
+		getJobsSinceEndpoint = opentracing.TraceClient(otTracer, "GetJobsSince")(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = limiter(getJobsSinceEndpoint)
+		getJobsSinceEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "GetJobsSince",
+			Timeout: 30 * time.Second,
+		}))(getJobsSinceEndpoint)
+	}
+
 	// Returning the endpoint.EndpointSet as a service.Service relies on the
 	// endpoint.EndpointSet implementing the Service methods. That's just a simple bit
 	// of glue code.
@@ -171,6 +206,8 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, logger
 		PingEndpoint:    pingEndpoint,
 		GetJobsEndpoint: getJobsEndpoint,
 		NewJobEndpoint:  newJobEndpoint,
+
+		GetJobsSinceEndpoint: getJobsSinceEndpoint,
 	}
 }
 
@@ -242,10 +279,60 @@ func decodeGRPCGetJobsRequest(_ context.Context, grpcReq interface{}) (interface
 // user-domain GetJobs response to a gRPC GetJobs reply. Primarily useful in a server.
 func encodeGRPCGetJobsResponse(_ context.Context, response interface{}) (interface{}, error) {
 	resp := response.(endpoint.GetJobsResponse)
+	return &pb.GetJobsReply{Jobs: jobsToPB(resp.Jobs), Err: err2str(resp.Err)}, nil
+}
 
-	// conver []worker.Job to []*pb.Job
-	pbJobs := make([]*pb.Job, 0)
-	for _, j := range resp.Jobs {
+// decodeGRPCGetJobsResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC GetJobs reply to a user-domain GetJobs response. Primarily useful in a client.
+func decodeGRPCGetJobsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetJobsReply)
+	return endpoint.GetJobsResponse{Jobs: jobsFromPB(reply.Jobs), Err: str2err(reply.Err)}, nil
+}
+
+// ********** GetJobsSince **********
+
+// encodeGRPCGetJobsSinceRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain GetJobsSince request to a gRPC GetJobsSince request. Primarily useful in a client.
+func encodeGRPCGetJobsSinceRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.GetJobsSinceRequest)
+	return &pb.GetJobsSinceRequest{Cursor: req.Cursor}, nil
+}
+
+// decodeGRPCGetJobsSinceRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC GetJobsSince request to a user-domain GetJobsSince request. Primarily useful in a server.
+func decodeGRPCGetJobsSinceRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetJobsSinceRequest)
+	return endpoint.GetJobsSinceRequest{Cursor: req.Cursor}, nil
+}
+
+// encodeGRPCGetJobsSinceResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain GetJobsSince response to a gRPC GetJobsSince reply. Primarily useful in a server.
+func encodeGRPCGetJobsSinceResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.GetJobsSinceResponse)
+	return &pb.GetJobsSinceReply{
+		Jobs:   jobsToPB(resp.Delta.Jobs),
+		Cursor: resp.Delta.Cursor,
+		Full:   resp.Delta.Full,
+		Err:    err2str(resp.Err),
+	}, nil
+}
+
+// decodeGRPCGetJobsSinceResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC GetJobsSince reply to a user-domain GetJobsSince response. Primarily useful in a client.
+func decodeGRPCGetJobsSinceResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetJobsSinceReply)
+	delta := worker.JobsDelta{
+		Jobs:   jobsFromPB(reply.Jobs),
+		Cursor: reply.Cursor,
+		Full:   reply.Full,
+	}
+	return endpoint.GetJobsSinceResponse{Delta: delta, Err: str2err(reply.Err)}, nil
+}
+
+// jobsToPB converts []worker.Job to []*pb.Job.
+func jobsToPB(jobs []worker.Job) []*pb.Job {
+	pbJobs := make([]*pb.Job, 0, len(jobs))
+	for _, j := range jobs {
 		st, _ := timestamp.TimestampProto(j.StartTime)
 		ft, _ := timestamp.TimestampProto(j.FinishTime)
 		pbJob := &pb.Job{
@@ -254,35 +341,32 @@ func encodeGRPCGetJobsResponse(_ context.Context, response interface{}) (interfa
 			Duration:   float32(j.Duration.Seconds()),
 			StartTime:  st,
 			FinishTime: ft,
+			Rev:        j.Rev,
 		}
 		pbJobs = append(pbJobs, pbJob)
 	}
-	return &pb.GetJobsReply{Jobs: pbJobs, Err: err2str(resp.Err)}, nil
+	return pbJobs
 }
 
-// decodeGRPCGetJobsResponse is a transport/grpc.DecodeResponseFunc that converts a
-// gRPC GetJobs reply to a user-domain GetJobs response. Primarily useful in a client.
-func decodeGRPCGetJobsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
-	reply := grpcReply.(*pb.GetJobsReply)
-
-	// conver []*pb.Job to []worker.Job
-	jobs := make([]worker.Job, 0)
-	for _, j := range reply.Jobs {
+// jobsFromPB converts []*pb.Job to []worker.Job.
+func jobsFromPB(pbJobs []*pb.Job) []worker.Job {
+	jobs := make([]worker.Job, 0, len(pbJobs))
+	for _, j := range pbJobs {
 		id, _ := uuid.Parse(j.ID)
 		dur, _ := time.ParseDuration(fmt.Sprintf("%f", j.Duration) + "s")
 		st, _ := timestamp.Timestamp(j.StartTime)
 		ft, _ := timestamp.Timestamp(j.FinishTime)
 		job := worker.Job{
-			ID:         worker.JobID{id},
+			ID:         worker.JobID{UUID: id},
 			Per:        j.Per,
 			Duration:   dur,
 			StartTime:  st,
 			FinishTime: ft,
+			Rev:        j.Rev,
 		}
 		jobs = append(jobs, job)
 	}
-
-	return endpoint.GetJobsResponse{Jobs: jobs, Err: str2err(reply.Err)}, nil
+	return jobs
 }
 
 // ********** NewJob **********